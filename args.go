@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alxarch/red/resp"
@@ -34,6 +35,7 @@ const (
 	argFalse
 	argScore
 	argLex
+	argPattern
 )
 
 // Arg is a command argument
@@ -48,7 +50,7 @@ func (a *Arg) Value() interface{} {
 	switch a.typ {
 	case argString:
 		return a.str
-	case argKey:
+	case argKey, argPattern:
 		return a.str
 	case argInt:
 		return int64(a.num)
@@ -71,6 +73,32 @@ func (a *Arg) Value() interface{} {
 	}
 }
 
+// text formats an arg as it is written to the server
+func (a *Arg) text(keyPrefix string) string {
+	switch a.typ {
+	case argKey:
+		return keyPrefix + a.str
+	case argPattern:
+		return escapeGlob(keyPrefix) + a.str
+	}
+	switch v := a.Value().(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
 // Equal checks if two args a are equal
 func (a Arg) Equal(other Arg) bool {
 	return a == other
@@ -86,6 +114,30 @@ func Key(s string) Arg {
 	return Arg{typ: argKey, str: s}
 }
 
+// matchPattern creates a glob-style pattern argument to match keys
+//
+// The KeyPrefix is escaped so that only keys with the prefix match.
+func matchPattern(s string) Arg {
+	return Arg{typ: argPattern, str: s}
+}
+
+// escapeGlob escapes glob-style pattern special characters
+func escapeGlob(s string) string {
+	if !strings.ContainsAny(s, `*?[]\`) {
+		return s
+	}
+	out := make([]byte, 0, 2*len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*', '?', '[', ']', '\\':
+			out = append(out, '\\', c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
+
 // String createa a string argument.
 func String(s string) Arg {
 	return Arg{typ: argString, str: s}
@@ -435,6 +487,8 @@ func (w *PipelineWriter) writeArgs(args ...Arg) (err error) {
 			err = w.writeBulkStringPrefix("", arg.str)
 		case argKey:
 			err = w.writeBulkStringPrefix(w.KeyPrefix, arg.str)
+		case argPattern:
+			err = w.writeBulkStringPrefix(escapeGlob(w.KeyPrefix), arg.str)
 		case argInt:
 			w.extra = strconv.AppendInt(w.extra[:0], int64(arg.num), 10)
 			w.dest.WriteByte(byte(resp.TypeBulkString))
@@ -1,6 +1,7 @@
 package red
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -125,6 +126,7 @@ type batchAPI struct {
 	args    ArgBuilder
 	w       batchWriter
 	replies []*batchReply
+	scripts []batchScript
 }
 
 func (b *batchAPI) Do(cmd string, args ...Arg) *ReplyAny {
@@ -143,10 +145,14 @@ func (b *batchAPI) reset() {
 	for i := range b.replies {
 		b.replies[i] = nil
 	}
+	for i := range b.scripts {
+		b.scripts[i] = batchScript{}
+	}
 	*b = batchAPI{
 		args:    b.args,
 		w:       b.w,
 		replies: b.replies[:0],
+		scripts: b.scripts[:0],
 	}
 }
 
@@ -201,6 +207,24 @@ func (b *batchAPI) doFloat(cmd string) *ReplyFloat {
 	b.do(cmd, &reply.batchReply)
 	return &reply
 }
+func (b *batchAPI) doBoolArray(cmd string) *ReplyBoolArray {
+	reply := ReplyBoolArray{}
+	reply.Bind(&reply.values)
+	b.do(cmd, &reply.batchReply)
+	return &reply
+}
+func (b *batchAPI) doIntegerArray(cmd string) *ReplyIntegerArray {
+	reply := ReplyIntegerArray{}
+	reply.Bind(&reply.values)
+	b.do(cmd, &reply.batchReply)
+	return &reply
+}
+func (b *batchAPI) doFloatArray(cmd string) *ReplyFloatArray {
+	reply := ReplyFloatArray{}
+	reply.Bind(&reply.values)
+	b.do(cmd, &reply.batchReply)
+	return &reply
+}
 func (b *batchAPI) doBool(cmd string) *ReplyBool {
 	reply := ReplyBool{}
 	reply.Bind(&reply.n)
@@ -214,6 +238,15 @@ func (c *Conn) DoBatch(b *Batch) error {
 	return c.doBatch(&b.batchAPI)
 }
 
+// DoBatchContext executes a batch using ctx for deadlines and cancellation
+//
+// If ctx is done before all replies are read the connection is closed.
+func (c *Conn) DoBatchContext(ctx context.Context, b *Batch) error {
+	return c.withContext(ctx, func() error {
+		return c.doBatch(&b.batchAPI)
+	})
+}
+
 // ErrReplyPending is the error of a reply until a `Client.Sync` is called
 var ErrReplyPending = errors.New("Reply pending")
 
@@ -236,7 +269,11 @@ func (c *Conn) doBatch(b *batchAPI) error {
 			return err
 		}
 	}
-	return c.scanBatch(b.replies)
+	if err := c.scanBatch(b.replies); err != nil {
+		return err
+	}
+	c.forgetScripts(b.scripts)
+	return nil
 }
 
 type batchExec []*batchReply
@@ -0,0 +1,288 @@
+package red
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alxarch/red/resp"
+)
+
+// Bitmaps
+
+// BitUnit is the unit of BITCOUNT and BITPOS ranges
+type BitUnit string
+
+// Range units, available since 7.0.0
+const (
+	UnitByte BitUnit = "BYTE"
+	UnitBit  BitUnit = "BIT"
+)
+
+// SetBit sets or clears the bit at offset in the string value stored at key
+//
+// The reply is the original bit value stored at offset.
+func (b *batchAPI) SetBit(key string, offset int64, value bool) *ReplyInteger {
+	b.args.Key(key)
+	b.args.Int(offset)
+	b.args.Int(bitValue(value))
+	return b.doInteger("SETBIT")
+}
+
+// GetBit returns the bit value at offset in the string value stored at key
+func (b *batchAPI) GetBit(key string, offset int64) *ReplyInteger {
+	b.args.Key(key)
+	b.args.Int(offset)
+	return b.doInteger("GETBIT")
+}
+
+// BitCount counts the number of set bits in the string value stored at key
+func (b *batchAPI) BitCount(key string) *ReplyInteger {
+	b.args.Key(key)
+	return b.doInteger("BITCOUNT")
+}
+
+// BitCountRange counts the number of set bits between start and end
+//
+//     BITCOUNT key start end [BYTE|BIT]
+//
+// If unit is empty the range is in bytes and no unit is sent to the server.
+func (b *batchAPI) BitCountRange(key string, start, end int64, unit BitUnit) *ReplyInteger {
+	b.args.Key(key)
+	b.args.Int(start)
+	b.args.Int(end)
+	if unit != "" {
+		b.args.String(string(unit))
+	}
+	return b.doInteger("BITCOUNT")
+}
+
+// BitPos returns the position of the first bit set to 1 or 0 starting at byte start
+func (b *batchAPI) BitPos(key string, bit bool, start int64) *ReplyInteger {
+	b.args.Key(key)
+	b.args.Int(bitValue(bit))
+	b.args.Int(start)
+	return b.doInteger("BITPOS")
+}
+
+// BitPosRange returns the position of the first bit set to 1 or 0 between start and end
+//
+//     BITPOS key bit start end [BYTE|BIT]
+//
+// If unit is empty the range is in bytes and no unit is sent to the server.
+func (b *batchAPI) BitPosRange(key string, bit bool, start, end int64, unit BitUnit) *ReplyInteger {
+	b.args.Key(key)
+	b.args.Int(bitValue(bit))
+	b.args.Int(start)
+	b.args.Int(end)
+	if unit != "" {
+		b.args.String(string(unit))
+	}
+	return b.doInteger("BITPOS")
+}
+
+func bitValue(bit bool) int64 {
+	if bit {
+		return 1
+	}
+	return 0
+}
+
+// BitOperation is a bitwise operation for BITOP
+type BitOperation string
+
+// Bitwise operations
+const (
+	BitAnd BitOperation = "AND"
+	BitOr  BitOperation = "OR"
+	BitXor BitOperation = "XOR"
+	BitNot BitOperation = "NOT"
+)
+
+// BitOp performs a bitwise operation between keys and stores the result at dest
+//
+// NOT accepts a single key. The reply is the size of the string stored at dest.
+func (b *batchAPI) BitOp(op BitOperation, dest, key string, keys ...string) *ReplyInteger {
+	b.args.String(string(op))
+	b.args.Key(dest)
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doInteger("BITOP")
+}
+
+// BitFieldType is the type of a BITFIELD integer
+//
+// Signed types are prefixed with 'i' and unsigned with 'u' ie "i32" or "u8".
+type BitFieldType string
+
+// Signed returns a signed integer type of size bits (up to 64)
+func Signed(bits uint) BitFieldType {
+	return BitFieldType("i" + strconv.FormatUint(uint64(bits), 10))
+}
+
+// Unsigned returns an unsigned integer type of size bits (up to 63)
+func Unsigned(bits uint) BitFieldType {
+	return BitFieldType("u" + strconv.FormatUint(uint64(bits), 10))
+}
+
+// BitFieldOverflow controls the behavior of BITFIELD SET and INCRBY on overflow
+type BitFieldOverflow string
+
+// Overflow behaviors
+const (
+	OverflowWrap BitFieldOverflow = "WRAP" // Wrap around, the default
+	OverflowSat  BitFieldOverflow = "SAT"  // Saturate to the minimum or maximum value
+	OverflowFail BitFieldOverflow = "FAIL" // Do nothing and reply with null
+)
+
+// BitField treats a string as an array of bits and operates on integer fields of arbitrary size
+//
+//     BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL]
+//
+// Available since 3.2.0.
+//
+// Operations are executed in the order they are added.
+// If ReadOnly is set the command is sent as BITFIELD_RO which only accepts GET operations (available since 6.0.0).
+//
+// Offsets are in bits. Offsets prefixed with '#' are multiplied by the size of the type (see GetAt, SetAt, IncrByAt).
+type BitField struct {
+	Key      string
+	ReadOnly bool
+	ops      []bitFieldOp
+}
+
+type bitFieldOp struct {
+	op     string
+	typ    BitFieldType
+	offset string
+	value  int64
+}
+
+// Get adds a GET operation returning the field of type typ at bit offset
+func (cmd *BitField) Get(typ BitFieldType, offset int64) *BitField {
+	return cmd.add("GET", typ, strconv.FormatInt(offset, 10), 0)
+}
+
+// GetAt adds a GET operation returning the index-th field of type typ
+func (cmd *BitField) GetAt(typ BitFieldType, index int64) *BitField {
+	return cmd.add("GET", typ, "#"+strconv.FormatInt(index, 10), 0)
+}
+
+// Set adds a SET operation setting the field of type typ at bit offset and returning its old value
+func (cmd *BitField) Set(typ BitFieldType, offset, value int64) *BitField {
+	return cmd.add("SET", typ, strconv.FormatInt(offset, 10), value)
+}
+
+// SetAt adds a SET operation setting the index-th field of type typ and returning its old value
+func (cmd *BitField) SetAt(typ BitFieldType, index, value int64) *BitField {
+	return cmd.add("SET", typ, "#"+strconv.FormatInt(index, 10), value)
+}
+
+// IncrBy adds an INCRBY operation incrementing the field of type typ at bit offset and returning its new value
+func (cmd *BitField) IncrBy(typ BitFieldType, offset, incr int64) *BitField {
+	return cmd.add("INCRBY", typ, strconv.FormatInt(offset, 10), incr)
+}
+
+// IncrByAt adds an INCRBY operation incrementing the index-th field of type typ and returning its new value
+func (cmd *BitField) IncrByAt(typ BitFieldType, index, incr int64) *BitField {
+	return cmd.add("INCRBY", typ, "#"+strconv.FormatInt(index, 10), incr)
+}
+
+// Overflow sets the overflow behavior of all subsequent SET and INCRBY operations
+func (cmd *BitField) Overflow(overflow BitFieldOverflow) *BitField {
+	cmd.ops = append(cmd.ops, bitFieldOp{
+		op:  "OVERFLOW",
+		typ: BitFieldType(overflow),
+	})
+	return cmd
+}
+
+func (cmd *BitField) add(op string, typ BitFieldType, offset string, value int64) *BitField {
+	cmd.ops = append(cmd.ops, bitFieldOp{
+		op:     op,
+		typ:    typ,
+		offset: offset,
+		value:  value,
+	})
+	return cmd
+}
+
+// BuildCommand implements CommandBuilder interface
+func (cmd *BitField) BuildCommand(args *ArgBuilder) string {
+	//  BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL]
+	args.Key(cmd.Key)
+	for i := range cmd.ops {
+		op := &cmd.ops[i]
+		args.String(op.op)
+		args.String(string(op.typ))
+		switch op.op {
+		case "GET":
+			args.String(op.offset)
+		case "SET", "INCRBY":
+			args.String(op.offset)
+			args.Int(op.value)
+		}
+	}
+	if cmd.ReadOnly {
+		return "BITFIELD_RO"
+	}
+	return "BITFIELD"
+}
+
+// BitField executes a BITFIELD or BITFIELD_RO command
+//
+// The reply has one value for each GET, SET and INCRBY operation.
+func (b *batchAPI) BitField(cmd *BitField) *ReplyBitField {
+	name := cmd.BuildCommand(&b.args)
+	reply := ReplyBitField{}
+	reply.Bind(&reply.values)
+	b.do(name, &reply.batchReply)
+	return &reply
+}
+
+// BitFieldValue is the result of a BITFIELD operation
+//
+// Valid is false if the operation was not performed because of OVERFLOW FAIL.
+type BitFieldValue struct {
+	Value int64
+	Valid bool
+}
+
+// ReplyBitField is the reply of a BITFIELD command
+type ReplyBitField struct {
+	values bitFieldValues
+	batchReply
+}
+
+// Reply returns the results of all BITFIELD operations
+func (r *ReplyBitField) Reply() ([]BitFieldValue, error) {
+	return r.values, r.err
+}
+
+type bitFieldValues []BitFieldValue
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (values *bitFieldValues) UnmarshalRESP(v resp.Value) error {
+	if err := v.Err(); err != nil {
+		return err
+	}
+	if v.Len() < 0 {
+		return fmt.Errorf("Invalid BITFIELD reply %v", v.Any())
+	}
+	results := (*values)[:0]
+	iter := v.Iter()
+	defer iter.Close()
+	for ; iter.More(); iter.Next() {
+		item := iter.Value()
+		if item.Null() {
+			results = append(results, BitFieldValue{})
+			continue
+		}
+		n, ok := item.Integer()
+		if !ok {
+			return fmt.Errorf("Invalid BITFIELD value %v", item.Any())
+		}
+		results = append(results, BitFieldValue{Value: n, Valid: true})
+	}
+	*values = results
+	return nil
+}
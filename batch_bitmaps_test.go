@@ -0,0 +1,92 @@
+package red_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestAPI_Bitmaps(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		switch strings.ToUpper(args[0]) {
+		case "BITFIELD":
+			return resp.Array{resp.Integer(0), resp.Integer(255), &resp.BulkString{}}
+		case "BITFIELD_RO":
+			return resp.Array{resp.Integer(255)}
+		default:
+			return resp.Integer(1)
+		}
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		KeyPrefix: "app:",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	b := new(red.Batch)
+	b.SetBit("foo", 7, true)
+	b.GetBit("foo", 7)
+	b.BitCount("foo")
+	b.BitCountRange("foo", 0, 15, red.UnitBit)
+	b.BitPos("foo", false, 1)
+	b.BitPosRange("foo", true, 0, -1, "")
+	b.BitOp(red.BitAnd, "dest", "foo", "bar")
+	cmd := red.BitField{Key: "foo"}
+	cmd.Set(red.Unsigned(8), 0, 255).
+		IncrBy(red.Unsigned(8), 0, 1).
+		Overflow(red.OverflowFail).
+		IncrByAt(red.Signed(32), 1, 1<<31)
+	bitfield := b.BitField(&cmd)
+	b.BitField((&red.BitField{Key: "foo"}).GetAt(red.Unsigned(8), 0))
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	values, err := bitfield.Reply()
+	if err != nil {
+		t.Fatalf("BITFIELD failed %s", err)
+	}
+	expect := []red.BitFieldValue{
+		{Value: 0, Valid: true},
+		{Value: 255, Valid: true},
+		{},
+	}
+	if !reflect.DeepEqual(values, expect) {
+		t.Errorf("Invalid BITFIELD reply %v", values)
+	}
+
+	cmd = red.BitField{Key: "foo", ReadOnly: true}
+	b.BitField(cmd.Get(red.Unsigned(8), 0))
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		{"SETBIT", "app:foo", "7", "1"},
+		{"GETBIT", "app:foo", "7"},
+		{"BITCOUNT", "app:foo"},
+		{"BITCOUNT", "app:foo", "0", "15", "BIT"},
+		{"BITPOS", "app:foo", "0", "1"},
+		{"BITPOS", "app:foo", "1", "0", "-1"},
+		{"BITOP", "AND", "app:dest", "app:foo", "app:bar"},
+		{"BITFIELD", "app:foo", "SET", "u8", "0", "255", "INCRBY", "u8", "0", "1", "OVERFLOW", "FAIL", "INCRBY", "i32", "#1", "2147483648"},
+		{"BITFIELD", "app:foo", "GET", "u8", "#0"},
+		{"BITFIELD_RO", "app:foo", "GET", "u8", "0"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%v\n%v", commands, expectCommands)
+	}
+}
@@ -0,0 +1,363 @@
+package red
+
+import (
+	"fmt"
+
+	"github.com/alxarch/red/resp"
+)
+
+// Geospatial indexes
+
+// GeoUnit is the unit of geospatial distances
+type GeoUnit string
+
+// Distance units
+const (
+	Meters     GeoUnit = "m"
+	Kilometers GeoUnit = "km"
+	Miles      GeoUnit = "mi"
+	Feet       GeoUnit = "ft"
+)
+
+func (u GeoUnit) String() string {
+	if u == "" {
+		return string(Meters)
+	}
+	return string(u)
+}
+
+// GeoMember is a member of a geospatial index
+type GeoMember struct {
+	Member    string
+	Longitude float64
+	Latitude  float64
+}
+
+// Geo creates a GeoMember
+func Geo(member string, longitude, latitude float64) GeoMember {
+	return GeoMember{
+		Member:    member,
+		Longitude: longitude,
+		Latitude:  latitude,
+	}
+}
+
+// GeoAdd adds members to the geospatial index stored at key
+//
+//     GEOADD key [NX|XX] [CH] longitude latitude member [longitude latitude member ...]
+//
+// NX, XX and CH modes are available since 6.2.0.
+// The reply is the number of added members or the number of changed members in CH mode.
+// Other modes are ignored.
+func (b *batchAPI) GeoAdd(key string, mode Mode, members ...GeoMember) *ReplyInteger {
+	b.args.Key(key)
+	b.argZAdd(mode &^ (INCR | GT | LT))
+	for i := range members {
+		m := &members[i]
+		b.args.Float(m.Longitude)
+		b.args.Float(m.Latitude)
+		b.args.String(m.Member)
+	}
+	return b.doInteger("GEOADD")
+}
+
+// GeoDist returns the distance between two members of the geospatial index stored at key
+//
+// If any of the members is missing the distance is NaN.
+func (b *batchAPI) GeoDist(key, member, other string, unit GeoUnit) *ReplyFloat {
+	b.args.Key(key)
+	b.args.String(member)
+	b.args.String(other)
+	b.args.String(unit.String())
+	return b.doFloat("GEODIST")
+}
+
+// GeoHash returns the geohash strings of members of the geospatial index stored at key
+func (b *batchAPI) GeoHash(key string, members ...string) *ReplyGeoHash {
+	b.args.Key(key)
+	b.args.Strings(members...)
+	reply := ReplyGeoHash{}
+	reply.Bind(&reply.hashes)
+	b.do("GEOHASH", &reply.batchReply)
+	return &reply
+}
+
+// ReplyGeoHash is the reply of a GEOHASH command
+type ReplyGeoHash struct {
+	hashes []resp.BulkString
+	batchReply
+}
+
+// Reply returns the geohash of each member
+//
+// Missing members have an empty geohash.
+func (r *ReplyGeoHash) Reply() ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	hashes := make([]string, len(r.hashes))
+	for i := range r.hashes {
+		hashes[i] = r.hashes[i].String
+	}
+	return hashes, nil
+}
+
+// GeoPos is the position of a member of a geospatial index
+//
+// Valid is false if the member does not exist.
+type GeoPos struct {
+	Longitude float64
+	Latitude  float64
+	Valid     bool
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (pos *GeoPos) UnmarshalRESP(v resp.Value) error {
+	if v.Null() {
+		*pos = GeoPos{}
+		return nil
+	}
+	p := GeoPos{Valid: true}
+	if err := v.Decode([]interface{}{
+		&p.Longitude,
+		&p.Latitude,
+	}); err != nil {
+		return err
+	}
+	*pos = p
+	return nil
+}
+
+// GeoPos returns the positions of members of the geospatial index stored at key
+func (b *batchAPI) GeoPos(key string, members ...string) *ReplyGeoPos {
+	b.args.Key(key)
+	b.args.Strings(members...)
+	reply := ReplyGeoPos{}
+	reply.Bind(&reply.positions)
+	b.do("GEOPOS", &reply.batchReply)
+	return &reply
+}
+
+// ReplyGeoPos is the reply of a GEOPOS command
+type ReplyGeoPos struct {
+	positions []GeoPos
+	batchReply
+}
+
+// Reply returns the position of each member
+func (r *ReplyGeoPos) Reply() ([]GeoPos, error) {
+	return r.positions, r.err
+}
+
+// GeoSearch queries a geospatial index for members inside an area
+//
+//     GEOSEARCH key FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius unit|BYBOX width height unit
+//       [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+//
+// Available since 6.2.0.
+//
+// The search starts from FromMember if set, otherwise from Longitude and Latitude.
+// If Width and Height are set the area is a box, otherwise it is a circle of Radius.
+//
+// The same query is used for the legacy GEORADIUS and GEORADIUSBYMEMBER commands which only support circles.
+type GeoSearch struct {
+	Key        string
+	FromMember string
+	Longitude  float64
+	Latitude   float64
+	Radius     float64
+	Width      float64
+	Height     float64
+	Unit       GeoUnit
+	Order      SortOrder
+	Count      int64
+	Any        bool
+	WithCoord  bool
+	WithDist   bool
+	WithHash   bool
+}
+
+// BuildCommand implements CommandBuilder interface
+func (cmd *GeoSearch) BuildCommand(args *ArgBuilder) string {
+	args.Key(cmd.Key)
+	cmd.buildArgs(args)
+	cmd.buildWith(args)
+	return "GEOSEARCH"
+}
+
+func (cmd *GeoSearch) buildArgs(args *ArgBuilder) {
+	if cmd.FromMember != "" {
+		args.String("FROMMEMBER")
+		args.String(cmd.FromMember)
+	} else {
+		args.String("FROMLONLAT")
+		args.Float(cmd.Longitude)
+		args.Float(cmd.Latitude)
+	}
+	if cmd.Width > 0 && cmd.Height > 0 {
+		args.String("BYBOX")
+		args.Float(cmd.Width)
+		args.Float(cmd.Height)
+	} else {
+		args.String("BYRADIUS")
+		args.Float(cmd.Radius)
+	}
+	args.String(cmd.Unit.String())
+	cmd.buildOptions(args)
+}
+
+func (cmd *GeoSearch) buildOptions(args *ArgBuilder) {
+	if order := cmd.Order.String(); order != "" {
+		args.String(order)
+	}
+	if cmd.Count > 0 {
+		args.String("COUNT")
+		args.Int(cmd.Count)
+		args.Flag("ANY", cmd.Any)
+	}
+}
+
+func (cmd *GeoSearch) buildWith(args *ArgBuilder) {
+	args.Flag("WITHCOORD", cmd.WithCoord)
+	args.Flag("WITHDIST", cmd.WithDist)
+	args.Flag("WITHHASH", cmd.WithHash)
+}
+
+// buildRadius adds GEORADIUS and GEORADIUSBYMEMBER arguments
+func (cmd *GeoSearch) buildRadius(args *ArgBuilder) string {
+	args.Key(cmd.Key)
+	name := "GEORADIUS"
+	if cmd.FromMember != "" {
+		name = "GEORADIUSBYMEMBER"
+		args.String(cmd.FromMember)
+	} else {
+		args.Float(cmd.Longitude)
+		args.Float(cmd.Latitude)
+	}
+	args.Float(cmd.Radius)
+	args.String(cmd.Unit.String())
+	return name
+}
+
+// GeoSearch queries a geospatial index for members inside an area
+func (b *batchAPI) GeoSearch(cmd *GeoSearch) *ReplyGeoSearch {
+	return b.doGeoSearch(cmd.BuildCommand(&b.args))
+}
+
+// GeoSearchStore stores the members of a geospatial index inside an area at dest
+//
+//     GEOSEARCHSTORE destination source FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius unit|BYBOX width height unit
+//       [ASC|DESC] [COUNT count [ANY]] [STOREDIST]
+//
+// If storeDist is set the members are stored in a sorted set with their distances as scores.
+// The reply is the number of stored members.
+func (b *batchAPI) GeoSearchStore(dest string, storeDist bool, cmd *GeoSearch) *ReplyInteger {
+	b.args.Key(dest)
+	b.args.Key(cmd.Key)
+	cmd.buildArgs(&b.args)
+	b.args.Flag("STOREDIST", storeDist)
+	return b.doInteger("GEOSEARCHSTORE")
+}
+
+// GeoRadius queries a geospatial index for members inside a circle using the legacy GEORADIUS_RO and GEORADIUSBYMEMBER_RO commands
+//
+//     GEORADIUS_RO key longitude latitude radius unit [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count [ANY]] [ASC|DESC]
+//     GEORADIUSBYMEMBER_RO key member radius unit [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count [ANY]] [ASC|DESC]
+//
+// Width and Height are ignored. Use GeoSearch on servers since 6.2.0.
+func (b *batchAPI) GeoRadius(cmd *GeoSearch) *ReplyGeoSearch {
+	name := cmd.buildRadius(&b.args) + "_RO"
+	cmd.buildWith(&b.args)
+	cmd.buildOptions(&b.args)
+	return b.doGeoSearch(name)
+}
+
+// GeoRadiusStore stores the members of a geospatial index inside a circle at dest using the legacy GEORADIUS and GEORADIUSBYMEMBER commands
+//
+//     GEORADIUS key longitude latitude radius unit [COUNT count [ANY]] [ASC|DESC] [STORE key] [STOREDIST key]
+//
+// If storeDist is set the members are stored in a sorted set with their distances as scores.
+// The reply is the number of stored members.
+func (b *batchAPI) GeoRadiusStore(dest string, storeDist bool, cmd *GeoSearch) *ReplyInteger {
+	name := cmd.buildRadius(&b.args)
+	cmd.buildOptions(&b.args)
+	if storeDist {
+		b.args.String("STOREDIST")
+	} else {
+		b.args.String("STORE")
+	}
+	b.args.Key(dest)
+	return b.doInteger(name)
+}
+
+func (b *batchAPI) doGeoSearch(cmd string) *ReplyGeoSearch {
+	reply := ReplyGeoSearch{}
+	reply.Bind(&reply.locations)
+	b.do(cmd, &reply.batchReply)
+	return &reply
+}
+
+// GeoLocation is a member found by a geospatial query
+//
+// Distance, Hash and position are only set if requested with WithDist, WithHash and WithCoord.
+type GeoLocation struct {
+	Member    string
+	Distance  float64
+	Hash      int64
+	Longitude float64
+	Latitude  float64
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (loc *GeoLocation) UnmarshalRESP(v resp.Value) error {
+	if !v.Type().Aggregate() {
+		var member string
+		if err := v.Decode(&member); err != nil {
+			return err
+		}
+		*loc = GeoLocation{Member: member}
+		return nil
+	}
+	// Optional fields are always in the order distance, hash, coordinates
+	// and can be told apart by their type.
+	l := GeoLocation{}
+	iter := v.Iter()
+	defer iter.Close()
+	if !iter.More() {
+		return fmt.Errorf("Invalid geo location %v", v.Any())
+	}
+	if err := iter.Value().Decode(&l.Member); err != nil {
+		return err
+	}
+	for iter.Next(); iter.More(); iter.Next() {
+		v := iter.Value()
+		switch typ := v.Type(); {
+		case typ == resp.TypeInteger:
+			l.Hash, _ = v.Integer()
+		case typ.Aggregate():
+			if err := v.Decode([]interface{}{
+				&l.Longitude,
+				&l.Latitude,
+			}); err != nil {
+				return err
+			}
+		default:
+			if err := v.Decode(&l.Distance); err != nil {
+				return err
+			}
+		}
+	}
+	*loc = l
+	return nil
+}
+
+// ReplyGeoSearch is the reply of a geospatial query
+type ReplyGeoSearch struct {
+	locations []GeoLocation
+	batchReply
+}
+
+// Reply returns the members found
+func (r *ReplyGeoSearch) Reply() ([]GeoLocation, error) {
+	return r.locations, r.err
+}
@@ -0,0 +1,150 @@
+package red_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestAPI_Geo(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		switch strings.ToUpper(args[0]) {
+		case "SELECT":
+			return resp.SimpleString("OK")
+		case "GEOPOS":
+			return resp.Array{
+				resp.Array{bulk("13.361389"), bulk("38.115556")},
+				resp.Array(nil),
+			}
+		case "GEOHASH":
+			return resp.Array{bulk("sqc8b49rny0"), &resp.BulkString{}}
+		case "GEODIST":
+			return &resp.BulkString{}
+		case "GEOSEARCH":
+			return resp.Array{
+				resp.Array{
+					bulk("Palermo"),
+					bulk("190.4424"),
+					resp.Integer(3479099956230698),
+					resp.Array{bulk("13.361389"), bulk("38.115556")},
+				},
+				resp.Array{
+					bulk("Catania"),
+					bulk("56.4413"),
+					resp.Integer(3479447370796909),
+					resp.Array{bulk("15.087269"), bulk("37.502669")},
+				},
+			}
+		case "GEORADIUSBYMEMBER_RO":
+			return resp.Array{bulk("Palermo"), bulk("Catania")}
+		default:
+			return resp.Integer(2)
+		}
+	})
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	b := new(red.Batch)
+	b.GeoAdd("Sicily", red.NX|red.CH,
+		red.Geo("Palermo", 13.361389, 38.115556),
+		red.Geo("Catania", 15.087269, 37.502669),
+	)
+	// GEOADD does not support GT, LT and INCR
+	b.GeoAdd("Sicily", red.XX|red.GT|red.LT|red.INCR|red.CH, red.Geo("Palermo", 13.361389, 38.115556))
+	pos := b.GeoPos("Sicily", "Palermo", "Nowhere")
+	hash := b.GeoHash("Sicily", "Palermo", "Nowhere")
+	dist := b.GeoDist("Sicily", "Palermo", "Nowhere", red.Kilometers)
+	search := b.GeoSearch(&red.GeoSearch{
+		Key:       "Sicily",
+		Longitude: 15,
+		Latitude:  37,
+		Width:     400,
+		Height:    400,
+		Unit:      red.Kilometers,
+		Order:     red.SortAscending,
+		Count:     2,
+		Any:       true,
+		WithCoord: true,
+		WithDist:  true,
+		WithHash:  true,
+	})
+	b.GeoSearchStore("dest", true, &red.GeoSearch{
+		Key:        "Sicily",
+		FromMember: "Palermo",
+		Radius:     200,
+	})
+	radius := b.GeoRadius(&red.GeoSearch{
+		Key:        "Sicily",
+		FromMember: "Palermo",
+		Radius:     200,
+		Unit:       red.Kilometers,
+		Order:      red.SortDescending,
+	})
+	b.GeoRadiusStore("dest", false, &red.GeoSearch{
+		Key:       "Sicily",
+		Longitude: 15,
+		Latitude:  37,
+		Radius:    200,
+		Count:     1,
+	})
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+
+	if p, err := pos.Reply(); err != nil {
+		t.Errorf("GEOPOS failed %s", err)
+	} else if expect := []red.GeoPos{{13.361389, 38.115556, true}, {}}; !reflect.DeepEqual(p, expect) {
+		t.Errorf("Invalid GEOPOS reply %v", p)
+	}
+	if h, err := hash.Reply(); err != nil || !reflect.DeepEqual(h, []string{"sqc8b49rny0", ""}) {
+		t.Errorf("Invalid GEOHASH reply %v %v", h, err)
+	}
+	if d, err := dist.Reply(); err != nil || !math.IsNaN(d) {
+		t.Errorf("Invalid GEODIST reply %f %v", d, err)
+	}
+	locations, err := search.Reply()
+	if err != nil {
+		t.Fatalf("GEOSEARCH failed %s", err)
+	}
+	expect := []red.GeoLocation{
+		{"Palermo", 190.4424, 3479099956230698, 13.361389, 38.115556},
+		{"Catania", 56.4413, 3479447370796909, 15.087269, 37.502669},
+	}
+	if !reflect.DeepEqual(locations, expect) {
+		t.Errorf("Invalid GEOSEARCH reply %v", locations)
+	}
+	if locations, err := radius.Reply(); err != nil || !reflect.DeepEqual(locations, []red.GeoLocation{{Member: "Palermo"}, {Member: "Catania"}}) {
+		t.Errorf("Invalid GEORADIUSBYMEMBER_RO reply %v %v", locations, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		{"GEOADD", "Sicily", "NX", "CH", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"},
+		{"GEOADD", "Sicily", "XX", "CH", "13.361389", "38.115556", "Palermo"},
+		{"GEOPOS", "Sicily", "Palermo", "Nowhere"},
+		{"GEOHASH", "Sicily", "Palermo", "Nowhere"},
+		{"GEODIST", "Sicily", "Palermo", "Nowhere", "km"},
+		{"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC", "COUNT", "2", "ANY", "WITHCOORD", "WITHDIST", "WITHHASH"},
+		{"GEOSEARCHSTORE", "dest", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "200", "m", "STOREDIST"},
+		{"GEORADIUSBYMEMBER_RO", "Sicily", "Palermo", "200", "km", "DESC"},
+		{"GEORADIUS", "Sicily", "15", "37", "200", "m", "COUNT", "1", "STORE", "dest"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%v\n%v", commands, expectCommands)
+	}
+}
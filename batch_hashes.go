@@ -1,5 +1,7 @@
 package red
 
+import "github.com/alxarch/red/resp"
+
 // Hashes
 
 // HDel deletes fields from a map
@@ -93,6 +95,21 @@ func H(name string, value Arg) HArg {
 // HSet is a collection of field-value pairs
 type HSet []HArg
 
+// UnmarshalRESP implements resp.Unmarshaler interface
+//
+// Values are decoded as String args.
+func (h *HSet) UnmarshalRESP(v resp.Value) error {
+	fields := (*h)[:0]
+	if err := v.EachKV(func(k, v string) error {
+		fields = append(fields, H(k, String(v)))
+		return nil
+	}); err != nil {
+		return err
+	}
+	*h = fields
+	return nil
+}
+
 // HSet adds an HSet command to the pipeline
 func (b *batchAPI) HSet(key, field, value string, entries ...string) *ReplyInteger {
 	b.args.Key(key)
@@ -148,3 +165,57 @@ func (b *batchAPI) HVals(key string) *ReplyBulkStringArray {
 	b.args.Key(key)
 	return b.doBulkStringArray("HVALS")
 }
+
+// HRandField returns a random field of the hash stored at key
+//
+// Available since 6.2.0.
+func (b *batchAPI) HRandField(key string) *ReplyBulkString {
+	b.args.Key(key)
+	return b.doBulkString("HRANDFIELD")
+}
+
+// HRandFieldN returns count random fields of the hash stored at key
+//
+// If count is negative the same field may be returned multiple times.
+func (b *batchAPI) HRandFieldN(key string, count int64) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Int(count)
+	return b.doBulkStringArray("HRANDFIELD")
+}
+
+// HRandFieldWithValues returns count random field/value pairs of the hash stored at key
+//
+// The reply is a flat array of consecutive field/value pairs like HGetAll.
+func (b *batchAPI) HRandFieldWithValues(key string, count int64) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Int(count)
+	b.args.String("WITHVALUES")
+	reply := ReplyBulkStringArray{}
+	reply.Bind((*fieldValuePairs)(&reply.values))
+	b.do("HRANDFIELD", &reply.batchReply)
+	return &reply
+}
+
+// fieldValuePairs flattens RESP3 arrays of [field, value] pairs
+type fieldValuePairs []string
+
+func (pairs *fieldValuePairs) UnmarshalRESP(v resp.Value) error {
+	iter := v.Iter()
+	defer iter.Close()
+	if !iter.More() || !iter.Value().Type().Aggregate() {
+		return (*resp.BulkStringArray)(pairs).UnmarshalRESP(v)
+	}
+	values := (*pairs)[:0]
+	for ; iter.More(); iter.Next() {
+		var field, value string
+		if err := iter.Value().Decode([]interface{}{
+			&field,
+			&value,
+		}); err != nil {
+			return err
+		}
+		values = append(values, field, value)
+	}
+	*pairs = values
+	return nil
+}
@@ -0,0 +1,88 @@
+package red
+
+import (
+	"strconv"
+	"time"
+)
+
+// HyperLogLog
+
+// PFAdd adds elements to the HyperLogLog stored at key
+//
+// The reply is true if the approximated cardinality changed.
+// PFADD with no elements is valid, it creates an empty HyperLogLog if key does not exist.
+func (b *batchAPI) PFAdd(key string, elements ...string) *ReplyBool {
+	b.args.Key(key)
+	b.args.Strings(elements...)
+	return b.doBool("PFADD")
+}
+
+// PFCount returns the approximated cardinality of the union of the HyperLogLogs stored at keys
+func (b *batchAPI) PFCount(key string, keys ...string) *ReplyInteger {
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doInteger("PFCOUNT")
+}
+
+// PFMerge merges the HyperLogLogs stored at keys into dest
+func (b *batchAPI) PFMerge(dest string, keys ...string) *ReplyOK {
+	b.args.Key(dest)
+	b.args.Keys(keys...)
+	return b.doSimpleStringOK("PFMERGE", 0)
+}
+
+// PFCountWindow counts the unique elements in the last n periods of w ending with the period containing end
+//
+// It queues a single PFCOUNT over the period keys so no merged key is stored.
+// If n < 1 only the period containing end is counted.
+func (b *batchAPI) PFCountWindow(w *PFWindow, end time.Time, n int) *ReplyInteger {
+	if n < 1 {
+		n = 1
+	}
+	b.args.Keys(w.Keys(end, n)...)
+	return b.doInteger("PFCOUNT")
+}
+
+// PFWindow names per-period HyperLogLog keys for rolling window unique counts
+//
+// Elements are added to the key of the current period and counts over a window use the keys of all periods in it.
+//
+//     w := PFWindow{Key: "visitors", Period: time.Hour}
+//     b.PFAdd(w.PeriodKey(time.Now()), visitorID)
+//     daily := b.PFCountWindow(&w, time.Now(), 24)
+type PFWindow struct {
+	Key    string        // Base name of period keys
+	Period time.Duration // Duration of each period, defaults to time.Hour
+	Layout string        // Time layout of the period start in key names, defaults to unix seconds
+}
+
+// PeriodKey returns the key of the period containing tm
+//
+// Periods are aligned to UTC.
+func (w *PFWindow) PeriodKey(tm time.Time) string {
+	start := tm.UTC().Truncate(w.period())
+	if w.Layout == "" {
+		return w.Key + ":" + strconv.FormatInt(start.Unix(), 10)
+	}
+	return w.Key + ":" + start.Format(w.Layout)
+}
+
+// Keys returns the keys of n periods ending with the period containing end, oldest first
+func (w *PFWindow) Keys(end time.Time, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	period := w.period()
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = w.PeriodKey(end.Add(-time.Duration(n-1-i) * period))
+	}
+	return keys
+}
+
+func (w *PFWindow) period() time.Duration {
+	if w.Period > 0 {
+		return w.Period
+	}
+	return time.Hour
+}
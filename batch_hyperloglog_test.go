@@ -0,0 +1,140 @@
+package red_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestAPI_HyperLogLog(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		switch strings.ToUpper(args[0]) {
+		case "PFMERGE", "SELECT":
+			return resp.SimpleString("OK")
+		case "PFCOUNT":
+			return resp.Integer(42)
+		default:
+			return resp.Integer(1)
+		}
+	})
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	w := red.PFWindow{Key: "visitors", Period: time.Hour}
+	now := time.Date(2020, 1, 2, 3, 45, 0, 0, time.UTC)
+	if key := w.PeriodKey(now); key != "visitors:1577934000" {
+		t.Errorf("Invalid period key %q", key)
+	}
+	w.Layout = "2006010215"
+	keys := w.Keys(now, 3)
+	if expect := []string{"visitors:2020010201", "visitors:2020010202", "visitors:2020010203"}; !reflect.DeepEqual(keys, expect) {
+		t.Errorf("Invalid window keys %v", keys)
+	}
+
+	b := new(red.Batch)
+	added := b.PFAdd(w.PeriodKey(now), "foo", "bar")
+	merged := b.PFMerge("dest", keys[0], keys[1])
+	window := b.PFCountWindow(&w, now, 3)
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	if ok, err := added.Reply(); !ok || err != nil {
+		t.Errorf("Invalid PFADD reply %t %v", ok, err)
+	}
+	if ok, err := merged.Reply(); !ok || err != nil {
+		t.Errorf("Invalid PFMERGE reply %t %v", ok, err)
+	}
+	if n, err := window.Reply(); n != 42 || err != nil {
+		t.Errorf("Invalid window reply %d %v", n, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expect := [][]string{
+		{"SELECT", "0"},
+		{"PFADD", "visitors:2020010203", "foo", "bar"},
+		{"PFMERGE", "dest", "visitors:2020010201", "visitors:2020010202"},
+		{"PFCOUNT", "visitors:2020010201", "visitors:2020010202", "visitors:2020010203"},
+	}
+	if !reflect.DeepEqual(commands, expect) {
+		t.Errorf("Invalid commands\n%v\n%v", commands, expect)
+	}
+}
+
+func TestAPI_PFCountWindowExpired(t *testing.T) {
+	// HyperLogLogs are emulated with sets
+	var mu sync.Mutex
+	hll := map[string]map[string]bool{}
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "PFADD":
+			set := hll[args[1]]
+			if set == nil {
+				set = map[string]bool{}
+				hll[args[1]] = set
+			}
+			for _, el := range args[2:] {
+				set[el] = true
+			}
+		case "PFCOUNT":
+			union := map[string]bool{}
+			for _, key := range args[1:] {
+				for el := range hll[key] {
+					union[el] = true
+				}
+			}
+			return resp.Integer(len(union))
+		case "SELECT":
+			return resp.SimpleString("OK")
+		}
+		return resp.Integer(1)
+	})
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	w := red.PFWindow{Key: "visitors", Period: time.Hour}
+	now := time.Date(2020, 1, 2, 3, 45, 0, 0, time.UTC)
+	count := func(now time.Time) int64 {
+		b := new(red.Batch)
+		window := b.PFCountWindow(&w, now, 2)
+		if err := conn.DoBatch(b); err != nil {
+			t.Fatalf("DoBatch failed %s", err)
+		}
+		n, err := window.Reply()
+		if err != nil {
+			t.Fatalf("Invalid window reply %s", err)
+		}
+		return n
+	}
+	b := new(red.Batch)
+	b.PFAdd(w.PeriodKey(now), "foo")
+	b.PFAdd(w.PeriodKey(now.Add(time.Hour)), "bar")
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	if n := count(now.Add(time.Hour)); n != 2 {
+		t.Errorf("Invalid window count %d", n)
+	}
+	// The period of foo left the window
+	if n := count(now.Add(2 * time.Hour)); n != 1 {
+		t.Errorf("Expired period was counted %d", n)
+	}
+}
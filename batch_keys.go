@@ -6,6 +6,29 @@ import (
 
 // Keys
 
+// Copy copies the value stored at src to dest
+//
+// If replace is set dest is overwritten if it exists.
+// Available since 6.2.0.
+func (b *batchAPI) Copy(src, dest string, replace bool) *ReplyBool {
+	b.args.Key(src)
+	b.args.Key(dest)
+	b.args.Flag("REPLACE", replace)
+	return b.doBool("COPY")
+}
+
+// CopyDB copies the value stored at src to dest in database db
+//
+// Available since 6.2.0.
+func (b *batchAPI) CopyDB(src, dest string, db int, replace bool) *ReplyBool {
+	b.args.Key(src)
+	b.args.Key(dest)
+	b.args.String("DB")
+	b.args.Int(int64(db))
+	b.args.Flag("REPLACE", replace)
+	return b.doBool("COPY")
+}
+
 // Del adds a DEL command to the pipeline
 func (b *batchAPI) Del(key string, keys ...string) *ReplyInteger {
 	b.args.Key(key)
@@ -32,6 +55,31 @@ func (b *batchAPI) Expire(key string, ttl time.Duration) *ReplyInteger {
 	return b.doInteger("EXPIRE")
 }
 
+// ExpireMode sets a TTL to a key in seconds if the mode condition is met
+//
+// Mode can be NX, XX, GT or LT. Available since 7.0.0.
+func (b *batchAPI) ExpireMode(key string, ttl time.Duration, mode Mode) *ReplyBool {
+	b.args.Key(key)
+	b.args.Seconds(ttl)
+	b.argExpire(mode)
+	return b.doBool("EXPIRE")
+}
+
+func (b *batchAPI) argExpire(mode Mode) {
+	switch {
+	case mode.NX():
+		b.args.String("NX")
+	case mode.XX():
+		b.args.String("XX")
+	}
+	switch {
+	case mode.GT():
+		b.args.String("GT")
+	case mode.LT():
+		b.args.String("LT")
+	}
+}
+
 // ExpireAt is redis EXPIREAT command
 func (b *batchAPI) ExpireAt(key string, tm time.Time) *ReplyInteger {
 	b.args.Key(key)
@@ -39,13 +87,24 @@ func (b *batchAPI) ExpireAt(key string, tm time.Time) *ReplyInteger {
 	return b.doInteger("EXPIREAT")
 }
 
+// ExpireTime returns the unix time in seconds at which a key will expire
+//
+// The reply is -1 if the key has no expiration and -2 if the key does not exist.
+// Available since 7.0.0.
+func (b *batchAPI) ExpireTime(key string) *ReplyInteger {
+	b.args.Key(key)
+	return b.doInteger("EXPIRETIME")
+}
+
 // Keys returns all keys matching a pattern
+//
+// KEYS blocks the server while scanning the whole keyspace, use Scan on large databases.
+// The KeyPrefix is escaped and applied to the pattern.
 func (b *batchAPI) Keys(pattern string) *ReplyBulkStringArray {
 	if pattern == "" {
 		pattern = "*"
 	}
-	b.args.String("MATCH")
-	b.args.String(pattern)
+	b.args.Arg(matchPattern(pattern))
 	return b.doBulkStringArray("KEYS")
 }
 
@@ -131,6 +190,16 @@ func (b *batchAPI) PExpire(key string, ttl time.Duration) *ReplyInteger {
 	return b.doInteger("PEXPIRE")
 }
 
+// PExpireMode sets a TTL to a key in milliseconds if the mode condition is met
+//
+// Mode can be NX, XX, GT or LT. Available since 7.0.0.
+func (b *batchAPI) PExpireMode(key string, ttl time.Duration, mode Mode) *ReplyBool {
+	b.args.Key(key)
+	b.args.Milliseconds(ttl)
+	b.argExpire(mode)
+	return b.doBool("PEXPIRE")
+}
+
 // PExpireAt is redis PEXPIREAT command
 func (b *batchAPI) PExpireAt(key string, tm time.Time) *ReplyInteger {
 	b.args.Key(key)
@@ -138,6 +207,15 @@ func (b *batchAPI) PExpireAt(key string, tm time.Time) *ReplyInteger {
 	return b.doInteger("PEXPIREAT")
 }
 
+// PExpireTime returns the unix time in milliseconds at which a key will expire
+//
+// The reply is -1 if the key has no expiration and -2 if the key does not exist.
+// Available since 7.0.0.
+func (b *batchAPI) PExpireTime(key string) *ReplyInteger {
+	b.args.Key(key)
+	return b.doInteger("PEXPIRETIME")
+}
+
 // PTTL gets the TTL of a key in milliseconds
 func (b *batchAPI) PTTL(key string) *ReplyInteger {
 	b.args.Key(key)
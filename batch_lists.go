@@ -18,6 +18,38 @@ func (p *Pop) UnmarshalRESP(v resp.Value) error {
 	})
 }
 
+// PopN is the reply of LMPOP, BLMPOP
+type PopN struct {
+	Key    string
+	Values []string
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (p *PopN) UnmarshalRESP(v resp.Value) error {
+	if v.Null() {
+		*p = PopN{}
+		return nil
+	}
+	pop := PopN{}
+	if err := v.Decode([]interface{}{
+		&pop.Key,
+		&pop.Values,
+	}); err != nil {
+		return err
+	}
+	*p = pop
+	return nil
+}
+
+// ListSide is the side of a list to pop or push elements
+type ListSide string
+
+// List sides
+const (
+	Left  ListSide = "LEFT"
+	Right ListSide = "RIGHT"
+)
+
 // BLPop is the blocking variant of LPop
 func (c *Conn) BLPop(timeout time.Duration, key string, keys ...string) (pop Pop, err error) {
 	args := ArgBuilder{}
@@ -42,6 +74,38 @@ func (c *Conn) BRPopLPush(src, dst string, timeout time.Duration) (el string, er
 	return
 }
 
+// BLMove is the blocking variant of LMove
+//
+// Available since 6.2.0.
+func (c *Conn) BLMove(src, dest string, from, to ListSide, timeout time.Duration) (el string, err error) {
+	args := ArgBuilder{}
+	args.Key(src)
+	args.Key(dest)
+	args.String(string(from))
+	args.String(string(to))
+	args.Float(timeout.Seconds())
+	err = c.DoCommand(&el, "BLMOVE", args.Args()...)
+	return
+}
+
+// BLMPop is the blocking variant of LMPop
+//
+// Available since 7.0.0.
+func (c *Conn) BLMPop(timeout time.Duration, side ListSide, count int64, key string, keys ...string) (pop PopN, err error) {
+	args := ArgBuilder{}
+	args.Float(timeout.Seconds())
+	args.Int(int64(len(keys) + 1))
+	args.Key(key)
+	args.Keys(keys...)
+	args.String(string(side))
+	if count > 0 {
+		args.String("COUNT")
+		args.Int(count)
+	}
+	err = c.DoCommand(&pop, "BLMPOP", args.Args()...)
+	return
+}
+
 // LIndex returns the element at index index in the list stored at key.
 func (c *batchAPI) LIndex(key string, index int64) *ReplyInteger {
 	c.args.Key(key)
@@ -75,12 +139,104 @@ func (c *batchAPI) LLen(key string) *ReplyInteger {
 	return c.doInteger("LLEN")
 }
 
+// LMove atomically pops an element from one side of src and pushes it to one side of dest
+//
+// Available since 6.2.0.
+func (c *batchAPI) LMove(src, dest string, from, to ListSide) *ReplyBulkString {
+	c.args.Key(src)
+	c.args.Key(dest)
+	c.args.String(string(from))
+	c.args.String(string(to))
+	return c.doBulkString("LMOVE")
+}
+
+// LMPop pops up to count elements from one side of the first non-empty list
+//
+// If all lists are empty the reply is an empty PopN.
+// Available since 7.0.0.
+func (c *batchAPI) LMPop(side ListSide, count int64, key string, keys ...string) *ReplyPopN {
+	c.args.Int(int64(len(keys) + 1))
+	c.args.Key(key)
+	c.args.Keys(keys...)
+	c.args.String(string(side))
+	if count > 0 {
+		c.args.String("COUNT")
+		c.args.Int(count)
+	}
+	reply := ReplyPopN{}
+	reply.Bind(&reply.pop)
+	c.do("LMPOP", &reply.batchReply)
+	return &reply
+}
+
+// ReplyPopN is the reply of an LMPOP command
+type ReplyPopN struct {
+	pop PopN
+	batchReply
+}
+
+// Reply returns the popped elements and the key of their list
+func (r *ReplyPopN) Reply() (PopN, error) {
+	return r.pop, r.err
+}
+
 // LPop removes and returns the first element of the list stored at key.
 func (c *batchAPI) LPop(key string) *ReplyBulkString {
 	c.args.Key(key)
 	return c.doBulkString("LPOP")
 }
 
+// LPopN removes and returns up to count elements from the head of the list stored at key
+//
+// Available since 6.2.0.
+func (c *batchAPI) LPopN(key string, count int64) *ReplyBulkStringArray {
+	c.args.Key(key)
+	c.args.Int(count)
+	return c.doBulkStringArray("LPOP")
+}
+
+// LPosOptions are the options of an LPOS command
+type LPosOptions struct {
+	Rank   int64 // Skip the first matches, negative ranks search from the tail
+	MaxLen int64 // Compare at most MaxLen elements
+}
+
+func (c *batchAPI) argLPos(key, element string, options LPosOptions) {
+	c.args.Key(key)
+	c.args.String(element)
+	if options.Rank != 0 {
+		c.args.String("RANK")
+		c.args.Int(options.Rank)
+	}
+	if options.MaxLen > 0 {
+		c.args.String("MAXLEN")
+		c.args.Int(options.MaxLen)
+	}
+}
+
+// LPos returns the index of the first element matching element in the list stored at key
+//
+// The reply error is resp.ErrNull if no element matches.
+// Available since 6.0.6.
+func (c *batchAPI) LPos(key, element string, options LPosOptions) *ReplyInteger {
+	c.argLPos(key, element, options)
+	reply := ReplyInteger{}
+	reply.Bind((*nullInteger)(&reply.n))
+	c.do("LPOS", &reply.batchReply)
+	return &reply
+}
+
+// LPosN returns the indexes of up to count elements matching element in the list stored at key
+//
+// If count is 0 all matches are returned.
+// Available since 6.0.6.
+func (c *batchAPI) LPosN(key, element string, count int64, options LPosOptions) *ReplyIntegerArray {
+	c.argLPos(key, element, options)
+	c.args.String("COUNT")
+	c.args.Int(count)
+	return c.doIntegerArray("LPOS")
+}
+
 // LPush inserts specified values at the head of the list stored at key.
 func (c *batchAPI) LPush(key string, values ...Arg) *ReplyInteger {
 	c.args.Key(key)
@@ -133,6 +289,15 @@ func (c *batchAPI) RPop(key string) *ReplyBulkString {
 	return c.doBulkString("RPOP")
 }
 
+// RPopN removes and returns up to count elements from the tail of the list stored at key
+//
+// Available since 6.2.0.
+func (c *batchAPI) RPopN(key string, count int64) *ReplyBulkStringArray {
+	c.args.Key(key)
+	c.args.Int(count)
+	return c.doBulkStringArray("RPOP")
+}
+
 // RPopLPush atomically returns and removes the last element (tail) of the list stored at source, and pushes the element at the first element (head) of the list stored at destination.
 func (c *batchAPI) RPopLPush(src, dest string, timeout time.Duration) *ReplyBulkString {
 	c.args.Key(src)
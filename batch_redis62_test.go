@@ -0,0 +1,186 @@
+package red_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestAPI_Redis62(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		switch strings.ToUpper(args[0]) {
+		case "SELECT":
+			return resp.SimpleString("OK")
+		case "SET", "GETDEL", "GETEX", "LMOVE", "BLMOVE", "HRANDFIELD":
+			if len(args) == 4 {
+				return resp.Array{bulk("foo"), bulk("1"), bulk("bar"), bulk("2")}
+			}
+			return bulk("foo")
+		case "LMPOP", "BLMPOP":
+			return resp.Array{bulk("app:list"), resp.Array{bulk("a"), bulk("b")}}
+		case "LPOS":
+			if args[len(args)-2] == "COUNT" {
+				return resp.Array{resp.Integer(1), resp.Integer(4)}
+			}
+			return &resp.BulkString{}
+		case "ZMSCORE":
+			return resp.Array{bulk("1.5"), &resp.BulkString{}}
+		case "ZRANGE", "ZUNION":
+			return resp.Array{bulk("a"), bulk("1"), bulk("b"), bulk("2")}
+		case "ZMPOP", "BZMPOP":
+			return resp.Array{bulk("app:zset"), resp.Array{resp.Array{bulk("a"), bulk("1")}}}
+		case "XAUTOCLAIM":
+			return resp.Array{
+				bulk("0-0"),
+				resp.Array{
+					resp.Array{bulk("1-0"), resp.Array{bulk("foo"), bulk("bar")}},
+					resp.Array(nil),
+				},
+				resp.Array{bulk("2-0")},
+			}
+		default:
+			return resp.Integer(1)
+		}
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		KeyPrefix: "app:",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	b := new(red.Batch)
+	old := b.SetGet(red.XX, "foo", "bar", time.Second)
+	b.GetDel("foo")
+	b.GetEx("foo", -1)
+	b.GetEx("foo", 1500*time.Millisecond)
+	b.GetEx("foo", red.KeepTTL)
+	b.Copy("foo", "bar", true)
+	b.CopyDB("foo", "bar", 2, false)
+	b.ExpireMode("foo", time.Minute, red.NX|red.GT)
+	b.PExpireMode("foo", time.Second, red.LT)
+	b.ExpireTime("foo")
+	b.LMove("src", "dst", red.Left, red.Right)
+	lmpop := b.LMPop(red.Right, 2, "list", "other")
+	b.LPopN("list", 2)
+	lpos := b.LPos("list", "a", red.LPosOptions{Rank: -1})
+	lposN := b.LPosN("list", "a", 0, red.LPosOptions{MaxLen: 10})
+	zmscore := b.ZMScore("zset", "a", "b")
+	b.ZRandMemberN("zset", -2)
+	zrange := b.ZRangeQueryWithScores(red.ZRangeQuery{
+		Key:   "zset",
+		Start: red.MaxScore(),
+		Stop:  red.Score(1, true),
+		By:    red.ZByScore,
+		Rev:   true,
+		Count: 2,
+	})
+	b.ZRangeStore("dest", red.ZRangeQuery{Key: "zset", Start: red.Int(0), Stop: red.Int(-1)})
+	b.ZUnionWithScores(red.ZStore{Keys: []string{"a", "b"}})
+	zmpop := b.ZMPopMin(1, "zset")
+	hrand := b.HRandFieldWithValues("hash", 2)
+	claim := b.XAutoClaim(&red.XAutoClaim{
+		Key:         "stream",
+		Group:       "group",
+		Consumer:    "consumer",
+		MinIdleTime: time.Second,
+		Count:       10,
+	})
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+
+	if v, err := old.Reply(); err != nil || v != "foo" {
+		t.Errorf("Invalid SET GET reply %q %v", v, err)
+	}
+	if pop, err := lmpop.Reply(); err != nil || !reflect.DeepEqual(pop, red.PopN{Key: "app:list", Values: []string{"a", "b"}}) {
+		t.Errorf("Invalid LMPOP reply %v %v", pop, err)
+	}
+	if _, err := lpos.Reply(); err != resp.ErrNull {
+		t.Errorf("Invalid LPOS reply %v", err)
+	}
+	if pos, err := lposN.Reply(); err != nil || !reflect.DeepEqual(pos, []int64{1, 4}) {
+		t.Errorf("Invalid LPOS COUNT reply %v %v", pos, err)
+	}
+	if scores, err := zmscore.Reply(); err != nil || len(scores) != 2 || scores[0] != 1.5 || !math.IsNaN(scores[1]) {
+		t.Errorf("Invalid ZMSCORE reply %v %v", scores, err)
+	}
+	if entries, err := zrange.Reply(); err != nil || !reflect.DeepEqual(entries, []red.ZEntry{{Member: "a", Score: 1}, {Member: "b", Score: 2}}) {
+		t.Errorf("Invalid ZRANGE reply %v %v", entries, err)
+	}
+	if pop, err := zmpop.Reply(); err != nil || !reflect.DeepEqual(pop, red.ZPopN{Key: "app:zset", Members: []red.ZEntry{{Member: "a", Score: 1}}}) {
+		t.Errorf("Invalid ZMPOP reply %v %v", pop, err)
+	}
+	if pairs, err := hrand.Reply(); err != nil || !reflect.DeepEqual(pairs, []string{"foo", "1", "bar", "2"}) {
+		t.Errorf("Invalid HRANDFIELD reply %v %v", pairs, err)
+	}
+	result, err := claim.Reply()
+	if err != nil {
+		t.Fatalf("XAUTOCLAIM failed %s", err)
+	}
+	expectClaim := red.XAutoClaimResult{
+		Next:    "0-0",
+		Records: []red.StreamRecord{{ID: "1-0", Record: []red.HArg{red.H("foo", red.String("bar"))}}},
+		Deleted: []string{"2-0"},
+	}
+	if !reflect.DeepEqual(result, expectClaim) {
+		t.Errorf("Invalid XAUTOCLAIM reply %v", result)
+	}
+
+	if el, err := conn.BLMove("src", "dst", red.Right, red.Left, 1500*time.Millisecond); err != nil || el != "foo" {
+		t.Errorf("Invalid BLMOVE reply %q %v", el, err)
+	}
+	if pop, err := conn.BLMPop(time.Second, red.Left, 0, "list"); err != nil || pop.Key != "app:list" {
+		t.Errorf("Invalid BLMPOP reply %v %v", pop, err)
+	}
+	if pop, err := conn.BZMPopMax(time.Second, 0, "zset"); err != nil || pop.Key != "app:zset" {
+		t.Errorf("Invalid BZMPOP reply %v %v", pop, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		{"SET", "app:foo", "bar", "EX", "1", "XX", "GET"},
+		{"GETDEL", "app:foo"},
+		{"GETEX", "app:foo", "PERSIST"},
+		{"GETEX", "app:foo", "PX", "1500"},
+		{"GETEX", "app:foo"},
+		{"COPY", "app:foo", "app:bar", "REPLACE"},
+		{"COPY", "app:foo", "app:bar", "DB", "2"},
+		{"EXPIRE", "app:foo", "60", "NX", "GT"},
+		{"PEXPIRE", "app:foo", "1000", "LT"},
+		{"EXPIRETIME", "app:foo"},
+		{"LMOVE", "app:src", "app:dst", "LEFT", "RIGHT"},
+		{"LMPOP", "2", "app:list", "app:other", "RIGHT", "COUNT", "2"},
+		{"LPOP", "app:list", "2"},
+		{"LPOS", "app:list", "a", "RANK", "-1"},
+		{"LPOS", "app:list", "a", "MAXLEN", "10", "COUNT", "0"},
+		{"ZMSCORE", "app:zset", "a", "b"},
+		{"ZRANDMEMBER", "app:zset", "-2"},
+		{"ZRANGE", "app:zset", "+inf", "1", "BYSCORE", "REV", "LIMIT", "0", "2", "WITHSCORES"},
+		{"ZRANGESTORE", "app:dest", "app:zset", "0", "-1"},
+		{"ZUNION", "2", "app:a", "app:b", "WITHSCORES"},
+		{"ZMPOP", "1", "app:zset", "MIN", "COUNT", "1"},
+		{"HRANDFIELD", "app:hash", "2", "WITHVALUES"},
+		{"XAUTOCLAIM", "app:stream", "group", "consumer", "1000", "0-0", "COUNT", "10"},
+		{"BLMOVE", "app:src", "app:dst", "RIGHT", "LEFT", "1.5"},
+		{"BLMPOP", "1", "1", "app:list", "LEFT"},
+		{"BZMPOP", "1", "1", "app:zset", "MAX"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%v\n%v", commands, expectCommands)
+	}
+}
@@ -1,7 +1,632 @@
 package red
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alxarch/red/resp"
+)
+
 // FlushDB writes a redis FLUSHDB command
 func (b *batchAPI) FlushDB(async bool) *ReplyOK {
 	b.args.Flag("ASYNC", async)
 	return b.doSimpleStringOK("FLUSHDB", 0)
 }
+
+// FlushAll removes all keys from all databases
+func (b *batchAPI) FlushAll(async bool) *ReplyOK {
+	b.args.Flag("ASYNC", async)
+	return b.doSimpleStringOK("FLUSHALL", 0)
+}
+
+// DBSize returns the number of keys in the selected database
+func (b *batchAPI) DBSize() *ReplyInteger {
+	return b.doInteger("DBSIZE")
+}
+
+// Info returns information and statistics about the server
+//
+// If no sections are given the default sections are returned.
+func (b *batchAPI) Info(sections ...string) *ReplyInfo {
+	b.args.Strings(sections...)
+	reply := ReplyInfo{}
+	reply.Bind(&reply.info)
+	b.do("INFO", &reply.batchReply)
+	return &reply
+}
+
+// ReplyInfo is the reply of an INFO command
+type ReplyInfo struct {
+	info Info
+	batchReply
+}
+
+// Reply returns the parsed server information
+func (r *ReplyInfo) Reply() (Info, error) {
+	return r.info, r.err
+}
+
+// ConfigGet returns the values of configuration parameters matching the glob-style patterns
+//
+// Multiple patterns are available since 7.0.0.
+func (b *batchAPI) ConfigGet(pattern string, patterns ...string) *ReplyConfig {
+	b.args.String("GET")
+	b.args.String(pattern)
+	b.args.Strings(patterns...)
+	reply := ReplyConfig{}
+	reply.Bind(&reply.config)
+	b.do("CONFIG", &reply.batchReply)
+	return &reply
+}
+
+// ReplyConfig is the reply of a CONFIG GET command
+type ReplyConfig struct {
+	config configValues
+	batchReply
+}
+
+// Reply returns the configuration parameters and their values
+func (r *ReplyConfig) Reply() (map[string]string, error) {
+	return r.config, r.err
+}
+
+type configValues map[string]string
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (c *configValues) UnmarshalRESP(v resp.Value) error {
+	values := make(map[string]string)
+	if err := v.EachKV(func(k, v string) error {
+		values[k] = v
+		return nil
+	}); err != nil {
+		return err
+	}
+	*c = values
+	return nil
+}
+
+// ConfigSet sets a configuration parameter at runtime
+func (b *batchAPI) ConfigSet(parameter, value string) *ReplyOK {
+	b.args.String("SET")
+	b.args.String(parameter)
+	b.args.String(value)
+	return b.doSimpleStringOK("CONFIG", 0)
+}
+
+// ConfigRewrite rewrites the configuration file with the current configuration
+func (b *batchAPI) ConfigRewrite() *ReplyOK {
+	b.args.String("REWRITE")
+	return b.doSimpleStringOK("CONFIG", 0)
+}
+
+// ConfigResetStat resets the statistics reported by INFO
+func (b *batchAPI) ConfigResetStat() *ReplyOK {
+	b.args.String("RESETSTAT")
+	return b.doSimpleStringOK("CONFIG", 0)
+}
+
+// SlowlogEntry is an entry of the slow queries log
+//
+// ClientAddr and ClientName are available since 4.0.0.
+type SlowlogEntry struct {
+	ID         int64
+	Time       time.Time
+	Duration   time.Duration
+	Args       []string
+	ClientAddr string
+	ClientName string
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (e *SlowlogEntry) UnmarshalRESP(v resp.Value) error {
+	entry := SlowlogEntry{}
+	var timestamp, micros int64
+	targets := []interface{}{
+		&entry.ID,
+		&timestamp,
+		&micros,
+		&entry.Args,
+		&entry.ClientAddr,
+		&entry.ClientName,
+	}
+	if n := v.Len(); n < 4 || n > int64(len(targets)) {
+		return fmt.Errorf("Invalid slowlog entry %v", v.Any())
+	}
+	iter := v.Iter()
+	defer iter.Close()
+	for i := 0; iter.More(); iter.Next() {
+		if err := iter.Value().Decode(targets[i]); err != nil {
+			return err
+		}
+		i++
+	}
+	entry.Time = time.Unix(timestamp, 0)
+	entry.Duration = time.Duration(micros) * time.Microsecond
+	*e = entry
+	return nil
+}
+
+// SlowlogGet returns up to count entries of the slow queries log, newest first
+//
+// If count is 0 the server default (10) is used, if count is -1 all entries are returned.
+func (b *batchAPI) SlowlogGet(count int64) *ReplySlowlog {
+	b.args.String("GET")
+	if count != 0 {
+		b.args.Int(count)
+	}
+	reply := ReplySlowlog{}
+	reply.Bind(&reply.entries)
+	b.do("SLOWLOG", &reply.batchReply)
+	return &reply
+}
+
+// ReplySlowlog is the reply of a SLOWLOG GET command
+type ReplySlowlog struct {
+	entries []SlowlogEntry
+	batchReply
+}
+
+// Reply returns the slow queries log entries
+func (r *ReplySlowlog) Reply() ([]SlowlogEntry, error) {
+	return r.entries, r.err
+}
+
+// SlowlogLen returns the number of entries in the slow queries log
+func (b *batchAPI) SlowlogLen() *ReplyInteger {
+	b.args.String("LEN")
+	return b.doInteger("SLOWLOG")
+}
+
+// SlowlogReset clears the slow queries log
+func (b *batchAPI) SlowlogReset() *ReplyOK {
+	b.args.String("RESET")
+	return b.doSimpleStringOK("SLOWLOG", 0)
+}
+
+// ClientInfo is the information of a client connection reported by CLIENT LIST and CLIENT INFO
+//
+// All reported fields are available in Fields.
+type ClientInfo struct {
+	ID        int64
+	Addr      string
+	LocalAddr string
+	Name      string
+	Age       time.Duration
+	Idle      time.Duration
+	Flags     string
+	DB        int64
+	Cmd       string
+	User      string
+	Fields    map[string]string
+}
+
+// ParseClientInfo parses a line of CLIENT LIST or CLIENT INFO output
+func ParseClientInfo(line string) ClientInfo {
+	info := ClientInfo{
+		Fields: make(map[string]string),
+	}
+	for _, field := range strings.Fields(line) {
+		pos := strings.IndexByte(field, '=')
+		if pos == -1 {
+			continue
+		}
+		k, v := field[:pos], field[pos+1:]
+		info.Fields[k] = v
+		switch k {
+		case "id":
+			info.ID, _ = strconv.ParseInt(v, 10, 64)
+		case "addr":
+			info.Addr = v
+		case "laddr":
+			info.LocalAddr = v
+		case "name":
+			info.Name = v
+		case "age":
+			n, _ := strconv.ParseInt(v, 10, 64)
+			info.Age = time.Duration(n) * time.Second
+		case "idle":
+			n, _ := strconv.ParseInt(v, 10, 64)
+			info.Idle = time.Duration(n) * time.Second
+		case "flags":
+			info.Flags = v
+		case "db":
+			info.DB, _ = strconv.ParseInt(v, 10, 64)
+		case "cmd":
+			info.Cmd = v
+		case "user":
+			info.User = v
+		}
+	}
+	return info
+}
+
+type clientList []ClientInfo
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (list *clientList) UnmarshalRESP(v resp.Value) error {
+	var text string
+	if err := v.Decode(&text); err != nil {
+		return err
+	}
+	var clients []ClientInfo
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			clients = append(clients, ParseClientInfo(line))
+		}
+	}
+	*list = clients
+	return nil
+}
+
+// ClientList returns the client connections to the server
+//
+// If typ is not empty only clients of this type (normal, master, replica, pubsub) are returned.
+// If ids are given only clients with these ids are returned (available since 6.2.0).
+func (b *batchAPI) ClientList(typ string, ids ...int64) *ReplyClientList {
+	b.args.String("LIST")
+	if typ != "" {
+		b.args.String("TYPE")
+		b.args.String(typ)
+	}
+	if len(ids) > 0 {
+		b.args.String("ID")
+		for _, id := range ids {
+			b.args.Int(id)
+		}
+	}
+	reply := ReplyClientList{}
+	reply.Bind(&reply.clients)
+	b.do("CLIENT", &reply.batchReply)
+	return &reply
+}
+
+// ReplyClientList is the reply of a CLIENT LIST command
+type ReplyClientList struct {
+	clients clientList
+	batchReply
+}
+
+// Reply returns the client connections
+func (r *ReplyClientList) Reply() ([]ClientInfo, error) {
+	return r.clients, r.err
+}
+
+// ClientInfo returns the information of the current connection
+//
+// Available since 6.2.0.
+func (b *batchAPI) ClientInfo() *ReplyClientInfo {
+	b.args.String("INFO")
+	reply := ReplyClientInfo{}
+	reply.Bind(&reply.clients)
+	b.do("CLIENT", &reply.batchReply)
+	return &reply
+}
+
+// ReplyClientInfo is the reply of a CLIENT INFO command
+type ReplyClientInfo struct {
+	clients clientList
+	batchReply
+}
+
+// Reply returns the information of the connection
+func (r *ReplyClientInfo) Reply() (ClientInfo, error) {
+	if r.err != nil {
+		return ClientInfo{}, r.err
+	}
+	if len(r.clients) != 1 {
+		return ClientInfo{}, fmt.Errorf("Invalid CLIENT INFO reply")
+	}
+	return r.clients[0], nil
+}
+
+// ClientKill is a filter of client connections to close
+//
+//     CLIENT KILL [ID client-id] [TYPE normal|master|replica|pubsub] [USER username] [ADDR ip:port] [LADDR ip:port] [SKIPME yes/no] [MAXAGE maxage]
+//
+// Connections matching all filters are closed.
+// The calling connection is skipped unless KillSelf is set.
+type ClientKill struct {
+	ID        int64
+	Type      string
+	User      string
+	Addr      string
+	LocalAddr string
+	MaxAge    time.Duration // Available since 7.4.0
+	KillSelf  bool
+}
+
+// BuildCommand implements CommandBuilder interface
+func (cmd *ClientKill) BuildCommand(args *ArgBuilder) string {
+	args.String("KILL")
+	if cmd.ID > 0 {
+		args.String("ID")
+		args.Int(cmd.ID)
+	}
+	if cmd.Type != "" {
+		args.String("TYPE")
+		args.String(cmd.Type)
+	}
+	if cmd.User != "" {
+		args.String("USER")
+		args.String(cmd.User)
+	}
+	if cmd.Addr != "" {
+		args.String("ADDR")
+		args.String(cmd.Addr)
+	}
+	if cmd.LocalAddr != "" {
+		args.String("LADDR")
+		args.String(cmd.LocalAddr)
+	}
+	if cmd.KillSelf {
+		args.String("SKIPME")
+		args.String("no")
+	}
+	if cmd.MaxAge > 0 {
+		args.String("MAXAGE")
+		args.Seconds(cmd.MaxAge)
+	}
+	return "CLIENT"
+}
+
+// ClientKill closes client connections matching a filter
+//
+// The reply is the number of closed connections.
+func (b *batchAPI) ClientKill(filter *ClientKill) *ReplyInteger {
+	return b.doInteger(filter.BuildCommand(&b.args))
+}
+
+// MemoryUsage returns the number of bytes a key and its value require to be stored in RAM
+//
+// If samples > 0 it sets the number of sampled nested values.
+// The reply error is resp.ErrNull if the key does not exist.
+func (b *batchAPI) MemoryUsage(key string, samples int64) *ReplyInteger {
+	b.args.String("USAGE")
+	b.args.Key(key)
+	if samples > 0 {
+		b.args.String("SAMPLES")
+		b.args.Int(samples)
+	}
+	reply := ReplyInteger{}
+	reply.Bind((*nullInteger)(&reply.n))
+	b.do("MEMORY", &reply.batchReply)
+	return &reply
+}
+
+// MemoryStats is the memory usage of the server reported by MEMORY STATS
+//
+// All reported values are available in Values.
+type MemoryStats struct {
+	PeakAllocated      int64
+	TotalAllocated     int64
+	StartupAllocated   int64
+	ReplicationBacklog int64
+	ClientsNormal      int64
+	ClientsReplicas    int64
+	OverheadTotal      int64
+	KeysCount          int64
+	DatasetBytes       int64
+	Fragmentation      float64
+	Values             map[string]resp.Any
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (s *MemoryStats) UnmarshalRESP(v resp.Value) error {
+	stats := MemoryStats{
+		Values: make(map[string]resp.Any),
+	}
+	if err := v.EachPair(func(k string, v resp.Value) error {
+		stats.Values[k] = v.Any()
+		var n *int64
+		switch k {
+		case "peak.allocated":
+			n = &stats.PeakAllocated
+		case "total.allocated":
+			n = &stats.TotalAllocated
+		case "startup.allocated":
+			n = &stats.StartupAllocated
+		case "replication.backlog":
+			n = &stats.ReplicationBacklog
+		case "clients.normal":
+			n = &stats.ClientsNormal
+		case "clients.slaves":
+			n = &stats.ClientsReplicas
+		case "overhead.total":
+			n = &stats.OverheadTotal
+		case "keys.count":
+			n = &stats.KeysCount
+		case "dataset.bytes":
+			n = &stats.DatasetBytes
+		case "fragmentation":
+			return v.Decode(&stats.Fragmentation)
+		default:
+			return nil
+		}
+		return v.Decode(n)
+	}); err != nil {
+		return err
+	}
+	*s = stats
+	return nil
+}
+
+// MemoryStats returns the memory usage of the server
+func (b *batchAPI) MemoryStats() *ReplyMemoryStats {
+	b.args.String("STATS")
+	reply := ReplyMemoryStats{}
+	reply.Bind(&reply.stats)
+	b.do("MEMORY", &reply.batchReply)
+	return &reply
+}
+
+// ReplyMemoryStats is the reply of a MEMORY STATS command
+type ReplyMemoryStats struct {
+	stats MemoryStats
+	batchReply
+}
+
+// Reply returns the memory usage stats
+func (r *ReplyMemoryStats) Reply() (MemoryStats, error) {
+	return r.stats, r.err
+}
+
+// LatencyEvent is the latest latency spike of an event reported by LATENCY LATEST
+type LatencyEvent struct {
+	Name   string
+	Time   time.Time
+	Latest time.Duration
+	Max    time.Duration
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (e *LatencyEvent) UnmarshalRESP(v resp.Value) error {
+	var timestamp, latest, max int64
+	var name string
+	if err := v.Decode([]interface{}{
+		&name,
+		&timestamp,
+		&latest,
+		&max,
+	}); err != nil {
+		return err
+	}
+	*e = LatencyEvent{
+		Name:   name,
+		Time:   time.Unix(timestamp, 0),
+		Latest: time.Duration(latest) * time.Millisecond,
+		Max:    time.Duration(max) * time.Millisecond,
+	}
+	return nil
+}
+
+// LatencyLatest returns the latest latency spikes of all events
+func (b *batchAPI) LatencyLatest() *ReplyLatencyLatest {
+	b.args.String("LATEST")
+	reply := ReplyLatencyLatest{}
+	reply.Bind(&reply.events)
+	b.do("LATENCY", &reply.batchReply)
+	return &reply
+}
+
+// ReplyLatencyLatest is the reply of a LATENCY LATEST command
+type ReplyLatencyLatest struct {
+	events []LatencyEvent
+	batchReply
+}
+
+// Reply returns the latest latency spikes
+func (r *ReplyLatencyLatest) Reply() ([]LatencyEvent, error) {
+	return r.events, r.err
+}
+
+// LatencySample is a latency spike of an event reported by LATENCY HISTORY
+type LatencySample struct {
+	Time    time.Time
+	Latency time.Duration
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (s *LatencySample) UnmarshalRESP(v resp.Value) error {
+	var timestamp, latency int64
+	if err := v.Decode([]interface{}{
+		&timestamp,
+		&latency,
+	}); err != nil {
+		return err
+	}
+	*s = LatencySample{
+		Time:    time.Unix(timestamp, 0),
+		Latency: time.Duration(latency) * time.Millisecond,
+	}
+	return nil
+}
+
+// LatencyHistory returns the latency spikes of an event
+func (b *batchAPI) LatencyHistory(event string) *ReplyLatencyHistory {
+	b.args.String("HISTORY")
+	b.args.String(event)
+	reply := ReplyLatencyHistory{}
+	reply.Bind(&reply.samples)
+	b.do("LATENCY", &reply.batchReply)
+	return &reply
+}
+
+// ReplyLatencyHistory is the reply of a LATENCY HISTORY command
+type ReplyLatencyHistory struct {
+	samples []LatencySample
+	batchReply
+}
+
+// Reply returns the latency spikes of the event
+func (r *ReplyLatencyHistory) Reply() ([]LatencySample, error) {
+	return r.samples, r.err
+}
+
+// Time returns the server time
+func (b *batchAPI) Time() *ReplyTime {
+	reply := ReplyTime{}
+	reply.Bind((*serverTime)(&reply.tm))
+	b.do("TIME", &reply.batchReply)
+	return &reply
+}
+
+// LastSave returns the time of the last successful save to disk
+func (b *batchAPI) LastSave() *ReplyTime {
+	reply := ReplyTime{}
+	reply.Bind((*unixTime)(&reply.tm))
+	b.do("LASTSAVE", &reply.batchReply)
+	return &reply
+}
+
+// ReplyTime is a time reply
+type ReplyTime struct {
+	tm time.Time
+	batchReply
+}
+
+// Reply returns the time
+func (r *ReplyTime) Reply() (time.Time, error) {
+	return r.tm, r.err
+}
+
+type serverTime time.Time
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (t *serverTime) UnmarshalRESP(v resp.Value) error {
+	var sec, usec int64
+	if err := v.Decode([]interface{}{
+		&sec,
+		&usec,
+	}); err != nil {
+		return err
+	}
+	*t = serverTime(time.Unix(sec, usec*int64(time.Microsecond)))
+	return nil
+}
+
+type unixTime time.Time
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (t *unixTime) UnmarshalRESP(v resp.Value) error {
+	var sec resp.Integer
+	if err := sec.UnmarshalRESP(v); err != nil {
+		return err
+	}
+	*t = unixTime(time.Unix(int64(sec), 0))
+	return nil
+}
+
+// BgSave saves the dataset to disk in the background
+//
+// If schedule is set and an AOF rewrite is in progress the save is scheduled to run after it.
+func (b *batchAPI) BgSave(schedule bool) *ReplySimpleString {
+	b.args.Flag("SCHEDULE", schedule)
+	return b.doSimpleString("BGSAVE")
+}
+
+// BgRewriteAOF rewrites the append only file in the background
+func (b *batchAPI) BgRewriteAOF() *ReplySimpleString {
+	return b.doSimpleString("BGREWRITEAOF")
+}
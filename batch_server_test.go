@@ -0,0 +1,233 @@
+package red_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestAPI_Server(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		cmd := strings.ToUpper(args[0])
+		if len(args) > 1 {
+			cmd += " " + strings.ToUpper(args[1])
+		}
+		switch cmd {
+		case "DBSIZE":
+			return resp.Integer(42)
+		case "CONFIG GET":
+			return resp.Array{bulk("maxmemory"), bulk("0"), bulk("maxclients"), bulk("10000")}
+		case "SLOWLOG GET":
+			return resp.Array{
+				resp.Array{
+					resp.Integer(14),
+					resp.Integer(1309448221),
+					resp.Integer(15),
+					resp.Array{bulk("ping")},
+					bulk("127.0.0.1:58217"),
+					bulk("worker-123"),
+				},
+				resp.Array{
+					resp.Integer(13),
+					resp.Integer(1309448128),
+					resp.Integer(30),
+					resp.Array{bulk("slowlog"), bulk("get"), bulk("100")},
+				},
+			}
+		case "SLOWLOG LEN":
+			return resp.Integer(2)
+		case "CLIENT LIST":
+			return bulk("id=3 addr=127.0.0.1:6379 laddr=127.0.0.1:6379 fd=8 name=worker age=10 idle=2 flags=N db=1 cmd=client|list user=default\n" +
+				"id=4 addr=127.0.0.1:6380 laddr=127.0.0.1:6379 fd=9 name= age=1 idle=0 flags=P db=0 cmd=subscribe user=default\n")
+		case "CLIENT INFO":
+			return bulk("id=3 addr=127.0.0.1:6379 laddr=127.0.0.1:6379 fd=8 name=worker age=10 idle=2 flags=N db=1 cmd=client|info user=default\n")
+		case "CLIENT KILL":
+			return resp.Integer(1)
+		case "MEMORY USAGE":
+			if args[2] == "app:missing" {
+				return &resp.BulkString{}
+			}
+			return resp.Integer(72)
+		case "MEMORY STATS":
+			return resp.Array{
+				bulk("peak.allocated"), resp.Integer(1000),
+				bulk("total.allocated"), resp.Integer(900),
+				bulk("db.0"), resp.Array{bulk("overhead.hashtable.main"), resp.Integer(72)},
+				bulk("keys.count"), resp.Integer(3),
+				bulk("fragmentation"), bulk("1.5"),
+			}
+		case "LATENCY LATEST":
+			return resp.Array{
+				resp.Array{bulk("command"), resp.Integer(1405067976), resp.Integer(251), resp.Integer(1001)},
+			}
+		case "LATENCY HISTORY":
+			return resp.Array{
+				resp.Array{resp.Integer(1405067822), resp.Integer(251)},
+				resp.Array{resp.Integer(1405067941), resp.Integer(1001)},
+			}
+		case "TIME":
+			return resp.Array{bulk("1714000000"), bulk("250000")}
+		case "LASTSAVE":
+			return resp.Integer(1714000000)
+		case "BGSAVE SCHEDULE":
+			return resp.SimpleString("Background saving scheduled")
+		case "BGREWRITEAOF":
+			return resp.SimpleString("Background append only file rewriting started")
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		KeyPrefix: "app:",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	b := new(red.Batch)
+	flushAll := b.FlushAll(true)
+	dbSize := b.DBSize()
+	config := b.ConfigGet("max*")
+	b.ConfigSet("maxmemory", "100mb")
+	b.ConfigRewrite()
+	b.ConfigResetStat()
+	slowlog := b.SlowlogGet(-1)
+	slowlogLen := b.SlowlogLen()
+	b.SlowlogReset()
+	clients := b.ClientList("normal")
+	client := b.ClientInfo()
+	kill := b.ClientKill(&red.ClientKill{Type: "pubsub", Addr: "127.0.0.1:6380", KillSelf: true, MaxAge: time.Minute})
+	usage := b.MemoryUsage("foo", 5)
+	missing := b.MemoryUsage("missing", 0)
+	memory := b.MemoryStats()
+	latest := b.LatencyLatest()
+	history := b.LatencyHistory("command")
+	tm := b.Time()
+	lastSave := b.LastSave()
+	bgSave := b.BgSave(true)
+	bgRewrite := b.BgRewriteAOF()
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+
+	if ok, err := flushAll.Reply(); !ok {
+		t.Errorf("FLUSHALL failed %s", err)
+	}
+	if n, err := dbSize.Reply(); n != 42 || err != nil {
+		t.Errorf("Invalid DBSIZE reply %d %v", n, err)
+	}
+	if c, err := config.Reply(); err != nil || !reflect.DeepEqual(c, map[string]string{"maxmemory": "0", "maxclients": "10000"}) {
+		t.Errorf("Invalid CONFIG GET reply %v %v", c, err)
+	}
+	entries, err := slowlog.Reply()
+	if err != nil {
+		t.Fatalf("SLOWLOG GET failed %s", err)
+	}
+	expectEntries := []red.SlowlogEntry{
+		{14, time.Unix(1309448221, 0), 15 * time.Microsecond, []string{"ping"}, "127.0.0.1:58217", "worker-123"},
+		{13, time.Unix(1309448128, 0), 30 * time.Microsecond, []string{"slowlog", "get", "100"}, "", ""},
+	}
+	if !reflect.DeepEqual(entries, expectEntries) {
+		t.Errorf("Invalid SLOWLOG GET reply %v", entries)
+	}
+	if n, _ := slowlogLen.Reply(); n != 2 {
+		t.Errorf("Invalid SLOWLOG LEN reply %d", n)
+	}
+	list, err := clients.Reply()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("Invalid CLIENT LIST reply %v %v", list, err)
+	}
+	if c := list[0]; c.ID != 3 || c.Name != "worker" || c.Age != 10*time.Second || c.Idle != 2*time.Second ||
+		c.DB != 1 || c.Cmd != "client|list" || c.User != "default" || c.LocalAddr != "127.0.0.1:6379" || c.Fields["fd"] != "8" {
+		t.Errorf("Invalid client info %v", c)
+	}
+	if c := list[1]; c.ID != 4 || c.Name != "" || c.Flags != "P" {
+		t.Errorf("Invalid client info %v", c)
+	}
+	if c, err := client.Reply(); err != nil || c.ID != 3 || c.Cmd != "client|info" {
+		t.Errorf("Invalid CLIENT INFO reply %v %v", c, err)
+	}
+	if n, err := kill.Reply(); n != 1 || err != nil {
+		t.Errorf("Invalid CLIENT KILL reply %d %v", n, err)
+	}
+	if n, err := usage.Reply(); n != 72 || err != nil {
+		t.Errorf("Invalid MEMORY USAGE reply %d %v", n, err)
+	}
+	if _, err := missing.Reply(); err != resp.ErrNull {
+		t.Errorf("Invalid MEMORY USAGE reply for missing key %v", err)
+	}
+	stats, err := memory.Reply()
+	if err != nil {
+		t.Fatalf("MEMORY STATS failed %s", err)
+	}
+	if stats.PeakAllocated != 1000 || stats.TotalAllocated != 900 || stats.KeysCount != 3 || stats.Fragmentation != 1.5 {
+		t.Errorf("Invalid MEMORY STATS reply %v", stats)
+	}
+	if _, ok := stats.Values["db.0"].(resp.Array); !ok {
+		t.Errorf("Invalid MEMORY STATS values %v", stats.Values)
+	}
+	if events, err := latest.Reply(); err != nil || !reflect.DeepEqual(events, []red.LatencyEvent{
+		{"command", time.Unix(1405067976, 0), 251 * time.Millisecond, 1001 * time.Millisecond},
+	}) {
+		t.Errorf("Invalid LATENCY LATEST reply %v %v", events, err)
+	}
+	if samples, err := history.Reply(); err != nil || !reflect.DeepEqual(samples, []red.LatencySample{
+		{time.Unix(1405067822, 0), 251 * time.Millisecond},
+		{time.Unix(1405067941, 0), 1001 * time.Millisecond},
+	}) {
+		t.Errorf("Invalid LATENCY HISTORY reply %v %v", samples, err)
+	}
+	if now, err := tm.Reply(); err != nil || !now.Equal(time.Unix(1714000000, 250000000)) {
+		t.Errorf("Invalid TIME reply %v %v", now, err)
+	}
+	if last, err := lastSave.Reply(); err != nil || !last.Equal(time.Unix(1714000000, 0)) {
+		t.Errorf("Invalid LASTSAVE reply %v %v", last, err)
+	}
+	if status, err := bgSave.Reply(); status != "Background saving scheduled" {
+		t.Errorf("Invalid BGSAVE reply %q %v", status, err)
+	}
+	if _, err := bgRewrite.Reply(); err != nil {
+		t.Errorf("BGREWRITEAOF failed %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expect := [][]string{
+		{"SELECT", "0"},
+		{"FLUSHALL", "ASYNC"},
+		{"DBSIZE"},
+		{"CONFIG", "GET", "max*"},
+		{"CONFIG", "SET", "maxmemory", "100mb"},
+		{"CONFIG", "REWRITE"},
+		{"CONFIG", "RESETSTAT"},
+		{"SLOWLOG", "GET", "-1"},
+		{"SLOWLOG", "LEN"},
+		{"SLOWLOG", "RESET"},
+		{"CLIENT", "LIST", "TYPE", "normal"},
+		{"CLIENT", "INFO"},
+		{"CLIENT", "KILL", "TYPE", "pubsub", "ADDR", "127.0.0.1:6380", "SKIPME", "no", "MAXAGE", "60"},
+		{"MEMORY", "USAGE", "app:foo", "SAMPLES", "5"},
+		{"MEMORY", "USAGE", "app:missing"},
+		{"MEMORY", "STATS"},
+		{"LATENCY", "LATEST"},
+		{"LATENCY", "HISTORY", "command"},
+		{"TIME"},
+		{"LASTSAVE"},
+		{"BGSAVE", "SCHEDULE"},
+		{"BGREWRITEAOF"},
+	}
+	if !reflect.DeepEqual(commands, expect) {
+		t.Errorf("Invalid commands\n%v\n%v", commands, expect)
+	}
+}
@@ -0,0 +1,143 @@
+package red
+
+// Sets
+
+// SAdd adds members to the set stored at key
+func (b *batchAPI) SAdd(key, member string, members ...string) *ReplyInteger {
+	b.args.Key(key)
+	b.args.String(member)
+	b.args.Strings(members...)
+	return b.doInteger("SADD")
+}
+
+// SCard returns the number of members of the set stored at key
+func (b *batchAPI) SCard(key string) *ReplyInteger {
+	b.args.Key(key)
+	return b.doInteger("SCARD")
+}
+
+// SDiff returns the members of the difference between the first set and all successive sets
+func (b *batchAPI) SDiff(key string, keys ...string) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doBulkStringArray("SDIFF")
+}
+
+// SDiffStore stores the difference between the first set and all successive sets at dest
+func (b *batchAPI) SDiffStore(dest, key string, keys ...string) *ReplyInteger {
+	b.args.Key(dest)
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doInteger("SDIFFSTORE")
+}
+
+// SInter returns the members of the intersection of all sets
+func (b *batchAPI) SInter(key string, keys ...string) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doBulkStringArray("SINTER")
+}
+
+// SInterCard returns the number of members of the intersection of all sets
+//
+// If limit > 0 the computation stops when the cardinality reaches limit.
+// Available since 7.0.0.
+func (b *batchAPI) SInterCard(limit int64, key string, keys ...string) *ReplyInteger {
+	b.args.Int(int64(len(keys) + 1))
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	if limit > 0 {
+		b.args.String("LIMIT")
+		b.args.Int(limit)
+	}
+	return b.doInteger("SINTERCARD")
+}
+
+// SInterStore stores the intersection of all sets at dest
+func (b *batchAPI) SInterStore(dest, key string, keys ...string) *ReplyInteger {
+	b.args.Key(dest)
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doInteger("SINTERSTORE")
+}
+
+// SIsMember checks if member is a member of the set stored at key
+func (b *batchAPI) SIsMember(key, member string) *ReplyBool {
+	b.args.Key(key)
+	b.args.String(member)
+	return b.doBool("SISMEMBER")
+}
+
+// SMIsMember checks if each member is a member of the set stored at key
+//
+// Available since 6.2.0.
+func (b *batchAPI) SMIsMember(key string, members ...string) *ReplyBoolArray {
+	b.args.Key(key)
+	b.args.Strings(members...)
+	return b.doBoolArray("SMISMEMBER")
+}
+
+// SMembers returns all members of the set stored at key
+func (b *batchAPI) SMembers(key string) *ReplyBulkStringArray {
+	b.args.Key(key)
+	return b.doBulkStringArray("SMEMBERS")
+}
+
+// SMove moves member from the set at src to the set at dest
+func (b *batchAPI) SMove(src, dest, member string) *ReplyBool {
+	b.args.Key(src)
+	b.args.Key(dest)
+	b.args.String(member)
+	return b.doBool("SMOVE")
+}
+
+// SPop removes and returns a random member of the set stored at key
+func (b *batchAPI) SPop(key string) *ReplyBulkString {
+	b.args.Key(key)
+	return b.doBulkString("SPOP")
+}
+
+// SPopN removes and returns up to count random members of the set stored at key
+func (b *batchAPI) SPopN(key string, count int64) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Int(count)
+	return b.doBulkStringArray("SPOP")
+}
+
+// SRandMember returns a random member of the set stored at key
+func (b *batchAPI) SRandMember(key string) *ReplyBulkString {
+	b.args.Key(key)
+	return b.doBulkString("SRANDMEMBER")
+}
+
+// SRandMemberN returns up to count distinct random members of the set stored at key
+//
+// If count is negative the same member may be returned multiple times.
+func (b *batchAPI) SRandMemberN(key string, count int64) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Int(count)
+	return b.doBulkStringArray("SRANDMEMBER")
+}
+
+// SRem removes members from the set stored at key
+func (b *batchAPI) SRem(key, member string, members ...string) *ReplyInteger {
+	b.args.Key(key)
+	b.args.String(member)
+	b.args.Strings(members...)
+	return b.doInteger("SREM")
+}
+
+// SUnion returns the members of the union of all sets
+func (b *batchAPI) SUnion(key string, keys ...string) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doBulkStringArray("SUNION")
+}
+
+// SUnionStore stores the union of all sets at dest
+func (b *batchAPI) SUnionStore(dest, key string, keys ...string) *ReplyInteger {
+	b.args.Key(dest)
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doInteger("SUNIONSTORE")
+}
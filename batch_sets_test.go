@@ -0,0 +1,96 @@
+package red_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/alxarch/red"
+)
+
+func TestAPI_Sets(t *testing.T) {
+	dial := dialer()
+	conn, err := dial()
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	b := new(red.Batch)
+	b.FlushDB(false)
+	sadd := b.SAdd("foo", "a", "b", "c", "d")
+	b.SAdd("bar", "c", "d", "e")
+	scard := b.SCard("foo")
+	ismember := b.SIsMember("foo", "a")
+	mismember := b.SMIsMember("foo", "a", "e")
+	inter := b.SInter("foo", "bar")
+	union := b.SUnion("foo", "bar")
+	diff := b.SDiff("foo", "bar")
+	intercard := b.SInterCard(1, "foo", "bar")
+	interstore := b.SInterStore("baz", "foo", "bar")
+	unionstore := b.SUnionStore("baz", "foo", "bar")
+	diffstore := b.SDiffStore("baz", "foo", "bar")
+	smove := b.SMove("foo", "bar", "a")
+	srem := b.SRem("foo", "b", "x")
+	members := b.SMembers("foo")
+	spop := b.SPopN("baz", 2)
+	srand := b.SRandMemberN("bar", -5)
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	sorted := func(reply *red.ReplyBulkStringArray) []string {
+		values, err := reply.Reply()
+		if err != nil {
+			t.Errorf("Reply failed %s", err)
+		}
+		sort.Strings(values)
+		return values
+	}
+	if n, err := sadd.Reply(); n != 4 || err != nil {
+		t.Errorf("Invalid SADD reply %d %v", n, err)
+	}
+	if n, err := scard.Reply(); n != 4 || err != nil {
+		t.Errorf("Invalid SCARD reply %d %v", n, err)
+	}
+	if ok, err := ismember.Reply(); !ok || err != nil {
+		t.Errorf("Invalid SISMEMBER reply %t %v", ok, err)
+	}
+	if values, err := mismember.Reply(); !reflect.DeepEqual(values, []bool{true, false}) || err != nil {
+		t.Errorf("Invalid SMISMEMBER reply %v %v", values, err)
+	}
+	if values := sorted(inter); !reflect.DeepEqual(values, []string{"c", "d"}) {
+		t.Errorf("Invalid SINTER reply %v", values)
+	}
+	if values := sorted(union); !reflect.DeepEqual(values, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("Invalid SUNION reply %v", values)
+	}
+	if values := sorted(diff); !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("Invalid SDIFF reply %v", values)
+	}
+	if n, err := intercard.Reply(); n != 1 || err != nil {
+		t.Errorf("Invalid SINTERCARD reply %d %v", n, err)
+	}
+	if n, err := interstore.Reply(); n != 2 || err != nil {
+		t.Errorf("Invalid SINTERSTORE reply %d %v", n, err)
+	}
+	if n, err := unionstore.Reply(); n != 5 || err != nil {
+		t.Errorf("Invalid SUNIONSTORE reply %d %v", n, err)
+	}
+	if n, err := diffstore.Reply(); n != 2 || err != nil {
+		t.Errorf("Invalid SDIFFSTORE reply %d %v", n, err)
+	}
+	if ok, err := smove.Reply(); !ok || err != nil {
+		t.Errorf("Invalid SMOVE reply %t %v", ok, err)
+	}
+	if n, err := srem.Reply(); n != 1 || err != nil {
+		t.Errorf("Invalid SREM reply %d %v", n, err)
+	}
+	if values := sorted(members); !reflect.DeepEqual(values, []string{"c", "d"}) {
+		t.Errorf("Invalid SMEMBERS reply %v", values)
+	}
+	if values := sorted(spop); !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("Invalid SPOP reply %v", values)
+	}
+	if values := sorted(srand); len(values) != 5 {
+		t.Errorf("Invalid SRANDMEMBER reply %v", values)
+	}
+}
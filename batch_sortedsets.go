@@ -29,6 +29,29 @@ func (conn *Conn) BZPopMin(timeout time.Duration, key string, keys ...string) (z
 	return
 }
 
+// BZMPopMax is the blocking version of ZMPopMax
+//
+// Available since 7.0.0.
+func (conn *Conn) BZMPopMax(timeout time.Duration, count int64, key string, keys ...string) (z ZPopN, err error) {
+	err = conn.doBZMPop(timeout, &z, "MAX", count, key, keys)
+	return
+}
+
+// BZMPopMin is the blocking version of ZMPopMin
+//
+// Available since 7.0.0.
+func (conn *Conn) BZMPopMin(timeout time.Duration, count int64, key string, keys ...string) (z ZPopN, err error) {
+	err = conn.doBZMPop(timeout, &z, "MIN", count, key, keys)
+	return
+}
+
+func (conn *Conn) doBZMPop(timeout time.Duration, z *ZPopN, where string, count int64, key string, keys []string) error {
+	args := ArgBuilder{}
+	args.Float(timeout.Seconds())
+	argZMPop(&args, where, count, key, keys)
+	return conn.DoCommand(z, "BZMPOP", args.Args()...)
+}
+
 // ZAdd adds or modifies the a member of a sorted set
 func (b *batchAPI) ZAdd(key string, mode Mode, members ...ZEntry) *ReplyInteger {
 	b.args.Key(key)
@@ -62,6 +85,11 @@ func (b *batchAPI) argZAdd(mode Mode) {
 	} else if mode.XX() {
 		b.args.String("XX")
 	}
+	if mode.GT() {
+		b.args.String("GT")
+	} else if mode.LT() {
+		b.args.String("LT")
+	}
 	if mode.CH() {
 		b.args.String("CH")
 	}
@@ -196,6 +224,45 @@ func (b *batchAPI) ZScore(key, member string) *ReplyFloat {
 	return b.doFloat("ZSCORE")
 }
 
+// ZMScore returns the scores of members in the sorted set stored at key
+//
+// Scores of missing members are NaN.
+// Available since 6.2.0.
+func (b *batchAPI) ZMScore(key, member string, members ...string) *ReplyFloatArray {
+	b.args.Key(key)
+	b.args.String(member)
+	b.args.Strings(members...)
+	return b.doFloatArray("ZMSCORE")
+}
+
+// ZRandMember returns a random member of the sorted set stored at key
+//
+// Available since 6.2.0.
+func (b *batchAPI) ZRandMember(key string) *ReplyBulkString {
+	b.args.Key(key)
+	return b.doBulkString("ZRANDMEMBER")
+}
+
+// ZRandMemberN returns count random members of the sorted set stored at key
+//
+// If count is negative the same member may be returned multiple times.
+// Available since 6.2.0.
+func (b *batchAPI) ZRandMemberN(key string, count int64) *ReplyBulkStringArray {
+	b.args.Key(key)
+	b.args.Int(count)
+	return b.doBulkStringArray("ZRANDMEMBER")
+}
+
+// ZRandMemberWithScores returns count random members of the sorted set stored at key with their scores
+//
+// Available since 6.2.0.
+func (b *batchAPI) ZRandMemberWithScores(key string, count int64) *ReplyZRange {
+	b.args.Key(key)
+	b.args.Int(count)
+	b.args.String("WITHSCORES")
+	return b.doZRange("ZRANDMEMBER")
+}
+
 // Aggregate is an aggregate method
 type Aggregate int
 
@@ -230,6 +297,11 @@ type ZStore struct {
 
 func (z *ZStore) args(args *ArgBuilder) {
 	args.Key(z.Destination)
+	z.argsKeys(args)
+}
+
+// argsKeys adds the arguments following the destination
+func (z *ZStore) argsKeys(args *ArgBuilder) {
 	args.Int(int64(len(z.Keys)))
 	args.Keys(z.Keys...)
 	if len(z.Weights) > 0 {
@@ -260,6 +332,61 @@ func (b *batchAPI) ZUnionStore(args ZStore) *ReplyInteger {
 	return b.doInteger("ZUNIONSTORE")
 }
 
+// ZInter returns the intersection of the sorted sets given by the specified keys
+//
+// Destination is ignored. Available since 6.2.0.
+func (b *batchAPI) ZInter(args ZStore) *ReplyBulkStringArray {
+	args.argsKeys(&b.args)
+	return b.doBulkStringArray("ZINTER")
+}
+
+// ZInterWithScores returns the intersection of the sorted sets given by the specified keys with scores
+//
+// Destination is ignored. Available since 6.2.0.
+func (b *batchAPI) ZInterWithScores(args ZStore) *ReplyZRange {
+	args.argsKeys(&b.args)
+	b.args.String("WITHSCORES")
+	return b.doZRange("ZINTER")
+}
+
+// ZUnion returns the union of the sorted sets given by the specified keys
+//
+// Destination is ignored. Available since 6.2.0.
+func (b *batchAPI) ZUnion(args ZStore) *ReplyBulkStringArray {
+	args.argsKeys(&b.args)
+	return b.doBulkStringArray("ZUNION")
+}
+
+// ZUnionWithScores returns the union of the sorted sets given by the specified keys with scores
+//
+// Destination is ignored. Available since 6.2.0.
+func (b *batchAPI) ZUnionWithScores(args ZStore) *ReplyZRange {
+	args.argsKeys(&b.args)
+	b.args.String("WITHSCORES")
+	return b.doZRange("ZUNION")
+}
+
+// ZDiff returns the members of the first sorted set that are not in the successive sorted sets
+//
+// Available since 6.2.0.
+func (b *batchAPI) ZDiff(key string, keys ...string) *ReplyBulkStringArray {
+	b.args.Int(int64(len(keys) + 1))
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	return b.doBulkStringArray("ZDIFF")
+}
+
+// ZDiffWithScores returns the members of the first sorted set that are not in the successive sorted sets with scores
+//
+// Available since 6.2.0.
+func (b *batchAPI) ZDiffWithScores(key string, keys ...string) *ReplyZRange {
+	b.args.Int(int64(len(keys) + 1))
+	b.args.Key(key)
+	b.args.Keys(keys...)
+	b.args.String("WITHSCORES")
+	return b.doZRange("ZDIFF")
+}
+
 // ZRange returns the specified range of elements in the sorted set stored at key.
 func (b *batchAPI) ZRange(key string, start, stop int64) *ReplyBulkStringArray {
 	b.args.Key(key)
@@ -396,6 +523,71 @@ func (b *batchAPI) ZRemRangeByLex(key string, min, max Arg) *ReplyInteger {
 	return b.doInteger("ZREMRANGEBYLEX")
 }
 
+// ZRangeBy is the range type of a ZRangeQuery
+type ZRangeBy string
+
+// Range types, ranges are by rank if not set
+const (
+	ZByScore ZRangeBy = "BYSCORE"
+	ZByLex   ZRangeBy = "BYLEX"
+)
+
+// ZRangeQuery holds arguments for the unified ZRANGE and the ZRANGESTORE commands
+//
+//     ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+//
+// Available since 6.2.0.
+//
+// Start and Stop are ranks (Int), scores (Score, Float64) or lex ranges (Lex) depending on By.
+// If Rev is set the order is reversed and Start must be the higher bound for score and lex ranges.
+// LIMIT is only sent if Count != 0 and requires a score or lex range.
+type ZRangeQuery struct {
+	Key    string
+	Start  Arg
+	Stop   Arg
+	By     ZRangeBy
+	Rev    bool
+	Offset int64
+	Count  int64
+}
+
+func (q *ZRangeQuery) args(args *ArgBuilder) {
+	args.Key(q.Key)
+	args.Arg(q.Start)
+	args.Arg(q.Stop)
+	if q.By != "" {
+		args.String(string(q.By))
+	}
+	args.Flag("REV", q.Rev)
+	if q.Count != 0 {
+		args.String("LIMIT")
+		args.Int(q.Offset)
+		args.Int(q.Count)
+	}
+}
+
+// ZRangeQuery returns the members of a sorted set in a range using the unified ZRANGE command
+func (b *batchAPI) ZRangeQuery(q ZRangeQuery) *ReplyBulkStringArray {
+	q.args(&b.args)
+	return b.doBulkStringArray("ZRANGE")
+}
+
+// ZRangeQueryWithScores returns the members of a sorted set in a range with their scores using the unified ZRANGE command
+func (b *batchAPI) ZRangeQueryWithScores(q ZRangeQuery) *ReplyZRange {
+	q.args(&b.args)
+	b.args.String("WITHSCORES")
+	return b.doZRange("ZRANGE")
+}
+
+// ZRangeStore stores the members of a sorted set in a range at dest
+//
+// Available since 6.2.0.
+func (b *batchAPI) ZRangeStore(dest string, q ZRangeQuery) *ReplyInteger {
+	b.args.Key(dest)
+	q.args(&b.args)
+	return b.doInteger("ZRANGESTORE")
+}
+
 // ZEntry is the entry of a sorted set
 type ZEntry struct {
 	Member string
@@ -455,6 +647,76 @@ func (reply *ReplyZPop) Reply() (ZPop, error) {
 	return reply.zpop, reply.err
 }
 
+// ZPopN is the reply of ZMPOP and BZMPOP
+type ZPopN struct {
+	Key     string
+	Members []ZEntry
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (z *ZPopN) UnmarshalRESP(v resp.Value) error {
+	if v.Null() {
+		*z = ZPopN{}
+		return nil
+	}
+	pop := ZPopN{}
+	if err := v.Decode([]interface{}{
+		&pop.Key,
+		(*zEntriesWithScores)(&pop.Members),
+	}); err != nil {
+		return err
+	}
+	*z = pop
+	return nil
+}
+
+func argZMPop(args *ArgBuilder, where string, count int64, key string, keys []string) {
+	args.Int(int64(len(keys) + 1))
+	args.Key(key)
+	args.Keys(keys...)
+	args.String(where)
+	if count > 0 {
+		args.String("COUNT")
+		args.Int(count)
+	}
+}
+
+// ZMPopMax removes and returns up to count members with the highest scores from the first non-empty sorted set
+//
+// If all sorted sets are empty the reply is an empty ZPopN.
+// Available since 7.0.0.
+func (b *batchAPI) ZMPopMax(count int64, key string, keys ...string) *ReplyZPopN {
+	argZMPop(&b.args, "MAX", count, key, keys)
+	return b.doZMPop()
+}
+
+// ZMPopMin removes and returns up to count members with the lowest scores from the first non-empty sorted set
+//
+// If all sorted sets are empty the reply is an empty ZPopN.
+// Available since 7.0.0.
+func (b *batchAPI) ZMPopMin(count int64, key string, keys ...string) *ReplyZPopN {
+	argZMPop(&b.args, "MIN", count, key, keys)
+	return b.doZMPop()
+}
+
+func (b *batchAPI) doZMPop() *ReplyZPopN {
+	reply := ReplyZPopN{}
+	reply.Bind(&reply.pop)
+	b.do("ZMPOP", &reply.batchReply)
+	return &reply
+}
+
+// ReplyZPopN is the reply of a ZMPOP command
+type ReplyZPopN struct {
+	pop ZPopN
+	batchReply
+}
+
+// Reply returns the popped members and the key of their sorted set
+func (r *ReplyZPopN) Reply() (ZPopN, error) {
+	return r.pop, r.err
+}
+
 // type zEntries []ZEntry
 // func (z *zEntries) UnmarshalRESP(v resp.Value) error {
 // 	entries := *z
@@ -485,6 +747,21 @@ func (z *zEntriesWithScores) UnmarshalRESP(v resp.Value) error {
 		entries[i] = ZEntry{}
 	}
 	entries = entries[:0]
+	// RESP3 replies are arrays of [member, score] pairs
+	if iter := v.Iter(); iter.More() && iter.Value().Type().Aggregate() {
+		for ; iter.More(); iter.Next() {
+			entry := ZEntry{}
+			if err := iter.Value().Decode([]interface{}{
+				&entry.Member,
+				&entry.Score,
+			}); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		*z = entries
+		return nil
+	}
 	if err := v.EachKV(func(k, v string) error {
 		score, err := strconv.ParseFloat(v, 64)
 		if err != nil {
@@ -1,6 +1,7 @@
 package red
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/alxarch/red/resp"
@@ -100,6 +101,116 @@ func (cmd *XClaim) BuildCommand(args *ArgBuilder) string {
 	return "XCLAIM"
 }
 
+// XAutoClaim transfers ownership of pending messages idle for at least MinIdleTime to a consumer
+//
+//     XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+//
+// Available since 6.2.0.
+//
+// Start defaults to "0-0". Claiming continues from the Next ID of the reply until it is "0-0".
+type XAutoClaim struct {
+	Key         string
+	Group       string
+	Consumer    string
+	MinIdleTime time.Duration
+	Start       string
+	Count       int64
+	JustID      bool
+}
+
+// BuildCommand implements CommandBuilder interface
+func (cmd *XAutoClaim) BuildCommand(args *ArgBuilder) string {
+	args.Key(cmd.Key)
+	args.String(cmd.Group)
+	args.String(cmd.Consumer)
+	args.Milliseconds(cmd.MinIdleTime)
+	start := cmd.Start
+	if start == "" {
+		start = "0-0"
+	}
+	args.String(start)
+	if cmd.Count > 0 {
+		args.String("COUNT")
+		args.Int(cmd.Count)
+	}
+	args.Flag("JUSTID", cmd.JustID)
+	return "XAUTOCLAIM"
+}
+
+// XAutoClaim executes an XAUTOCLAIM command
+func (b *batchAPI) XAutoClaim(cmd *XAutoClaim) *ReplyXAutoClaim {
+	name := cmd.BuildCommand(&b.args)
+	reply := ReplyXAutoClaim{}
+	reply.Bind(&reply.result)
+	b.do(name, &reply.batchReply)
+	return &reply
+}
+
+// XAutoClaimResult is the result of an XAUTOCLAIM command
+//
+// Records only have an ID in JUSTID mode.
+// Deleted holds the IDs of pending messages that no longer exist in the stream (since 7.0.0).
+type XAutoClaimResult struct {
+	Next    string
+	Records []StreamRecord
+	Deleted []string
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (x *XAutoClaimResult) UnmarshalRESP(v resp.Value) error {
+	if err := v.Err(); err != nil {
+		return err
+	}
+	if n := v.Len(); n != 2 && n != 3 {
+		return fmt.Errorf("Invalid XAUTOCLAIM reply %v", v.Any())
+	}
+	result := XAutoClaimResult{}
+	iter := v.Iter()
+	defer iter.Close()
+	if err := iter.Value().Decode(&result.Next); err != nil {
+		return err
+	}
+	iter.Next()
+	claimed := iter.Value().Iter()
+	defer claimed.Close()
+	for ; claimed.More(); claimed.Next() {
+		entry := claimed.Value()
+		record := StreamRecord{}
+		switch {
+		case entry.Null():
+			// Deleted entries are null before 7.0.0
+			continue
+		case entry.Type().Aggregate():
+			if err := record.UnmarshalRESP(entry); err != nil {
+				return err
+			}
+		default:
+			if err := entry.Decode(&record.ID); err != nil {
+				return err
+			}
+		}
+		result.Records = append(result.Records, record)
+	}
+	if iter.Next(); iter.More() {
+		if err := iter.Value().Decode(&result.Deleted); err != nil {
+			return err
+		}
+	}
+	*x = result
+	return nil
+}
+
+// ReplyXAutoClaim is the reply of an XAUTOCLAIM command
+type ReplyXAutoClaim struct {
+	result XAutoClaimResult
+	batchReply
+}
+
+// Reply returns the claimed records and the ID to continue claiming from
+func (r *ReplyXAutoClaim) Reply() (XAutoClaimResult, error) {
+	return r.result, r.err
+}
+
 // XDel deletes entries from a stream
 func (b *batchAPI) XDel(key string, ids ...string) *ReplyInteger {
 	b.args.Key(key)
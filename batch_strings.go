@@ -31,6 +31,27 @@ func (b *batchAPI) Get(key string) *ReplyBulkString {
 	return b.doBulkString("GET")
 }
 
+// GetDel gets the value of a key and deletes it
+//
+// Available since 6.2.0.
+func (b *batchAPI) GetDel(key string) *ReplyBulkString {
+	b.args.Key(key)
+	return b.doBulkString("GETDEL")
+}
+
+// GetEx gets the value of a key and sets its expiration
+//
+// If ttl is 0 or KeepTTL the expiration is not changed, if ttl < 0 the expiration is removed.
+// Available since 6.2.0.
+func (b *batchAPI) GetEx(key string, ttl time.Duration) *ReplyBulkString {
+	b.args.Key(key)
+	if ttl < 0 && ttl != KeepTTL {
+		b.args.String("PERSIST")
+	}
+	b.argTTL(ttl)
+	return b.doBulkString("GETEX")
+}
+
 // GetRange gets a part of a string
 func (b *batchAPI) GetRange(key string, start, end int64) *ReplyBulkString {
 	b.args.Key(key)
@@ -107,18 +128,15 @@ func (b *batchAPI) MSetNX(values ...string) *ReplyBool {
 }
 
 func (b *batchAPI) doSet(mode Mode, k, v string, ttl time.Duration) *ReplyOK {
+	b.argSet(mode, k, v, ttl)
+	return b.doSimpleStringOK("SET", mode)
+}
+
+func (b *batchAPI) argSet(mode Mode, k, v string, ttl time.Duration) {
 	b.args.Key(k)
 	b.args.String(v)
 	const KeepTTL time.Duration = math.MinInt64
-	if ttl > 0 {
-		if ex := ttl.Truncate(time.Second); ex == ttl {
-			b.args.String("EX")
-			b.args.Arg(Seconds(ttl))
-		} else {
-			b.args.String("PX")
-			b.args.Arg(Milliseconds(ttl))
-		}
-	}
+	b.argTTL(ttl)
 	switch mode {
 	case NX:
 		b.args.String("NX")
@@ -128,7 +146,30 @@ func (b *batchAPI) doSet(mode Mode, k, v string, ttl time.Duration) *ReplyOK {
 	if ttl == KeepTTL {
 		b.args.String("KEEPTTL")
 	}
-	return b.doSimpleStringOK("SET", mode)
+}
+
+// argTTL adds EX or PX arguments if ttl > 0
+func (b *batchAPI) argTTL(ttl time.Duration) {
+	if ttl > 0 {
+		if ex := ttl.Truncate(time.Second); ex == ttl {
+			b.args.String("EX")
+			b.args.Arg(Seconds(ttl))
+		} else {
+			b.args.String("PX")
+			b.args.Arg(Milliseconds(ttl))
+		}
+	}
+}
+
+// SetGet sets a key to value and returns the old value
+//
+// Mode can be NX or XX (NX is available since 7.0.0).
+// The reply error is resp.ErrNull if the key did not exist.
+// Available since 6.2.0.
+func (b *batchAPI) SetGet(mode Mode, key, value string, ttl time.Duration) *ReplyBulkString {
+	b.argSet(mode, key, value, ttl)
+	b.args.String("GET")
+	return b.doBulkString("SET")
 }
 
 // SetXX resets a key value if it exists
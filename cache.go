@@ -0,0 +1,547 @@
+package red
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alxarch/red/internal/pubsub"
+	"github.com/alxarch/red/resp"
+)
+
+// Cache is a client-side cache of read command replies
+//
+// Cached replies are kept coherent using CLIENT TRACKING (available since 6.0.0).
+// By default invalidations are redirected to a dedicated connection subscribed to
+// __redis__:invalidate. If Push is set, connections must use RESP3 (Protocol: 3)
+// and invalidations are received as push messages on each connection.
+// In push mode invalidations are only processed when a connection reads a reply
+// so idle connections in the pool can delay them.
+//
+// Only commands that read keys are cached and keys must be passed with Key().
+type Cache struct {
+	noCopy noCopy //nolint:unused,structcheck
+
+	Pool          *Pool         // Pool of connections to the server (required)
+	MaxEntries    int           // Maximum number of cached replies (defaults to 10000)
+	TTL           time.Duration // Max time a reply is cached (0 => no limit)
+	Push          bool          // Receive invalidations as RESP3 push messages instead of a redirect connection
+	RetryInterval time.Duration // Interval to retry the invalidation connection (defaults to 1s)
+
+	once     sync.Once
+	mu       sync.Mutex
+	closed   bool
+	closeCh  chan struct{}
+	doneCh   chan struct{}
+	redirect int64  // Client id receiving invalidations (0 if not connected, -1 for push mode)
+	epoch    uint64 // Incremented on every flush
+	lru      list.List
+	entries  map[string]*list.Element
+	keys     map[string]map[*list.Element]struct{}
+	fetches  map[string]map[*cacheFetch]struct{} // Replies being fetched by key
+
+	stats struct {
+		hits, misses, invalidations, evictions int64
+	}
+}
+
+// CacheStats counts cache statistics
+type CacheStats struct {
+	Hits, Misses, Invalidations, Evictions int64
+	Entries                                int
+}
+
+type cacheEntry struct {
+	id      string   // Command and arguments
+	keys    []string // Keys read by the command
+	reply   []byte   // RESP encoded reply
+	expires time.Time
+}
+
+// cacheFetch is a reply being fetched from the server
+type cacheFetch struct {
+	epoch uint64
+	keys  []string
+	stale bool // A key was invalidated while fetching
+}
+
+const (
+	defaultCacheMaxEntries = 10000
+	cacheInvalidateChannel = "__redis__:invalidate"
+)
+
+var errCacheClosed = errors.New("Cache closed")
+
+func (c *Cache) init() {
+	c.entries = make(map[string]*list.Element)
+	c.keys = make(map[string]map[*list.Element]struct{})
+	c.fetches = make(map[string]map[*cacheFetch]struct{})
+	c.closeCh = make(chan struct{})
+	c.doneCh = make(chan struct{})
+	if c.Push {
+		c.redirect = -1
+		close(c.doneCh)
+		return
+	}
+	go c.watch()
+}
+
+// Stats returns current cache statistics
+func (c *Cache) Stats() CacheStats {
+	c.once.Do(c.init)
+	stats := CacheStats{
+		Hits:          atomic.LoadInt64(&c.stats.hits),
+		Misses:        atomic.LoadInt64(&c.stats.misses),
+		Invalidations: atomic.LoadInt64(&c.stats.invalidations),
+		Evictions:     atomic.LoadInt64(&c.stats.evictions),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// Close stops receiving invalidations and flushes the cache
+//
+// The pool is not closed.
+// Pool connections stop tracking keys for the cache when they are released to the pool.
+func (c *Cache) Close() error {
+	c.once.Do(c.init)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errCacheClosed
+	}
+	c.closed = true
+	close(c.closeCh)
+	c.mu.Unlock()
+	<-c.doneCh
+	c.Flush()
+	return nil
+}
+
+// Flush removes all cached replies
+func (c *Cache) Flush() {
+	c.once.Do(c.init)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.keys = make(map[string]map[*list.Element]struct{})
+}
+
+// DoCommand executes a command on a pool connection using cached replies for read commands
+func (c *Cache) DoCommand(dest interface{}, name string, args ...Arg) error {
+	id, hit, err := c.get(dest, name, args)
+	if hit {
+		return err
+	}
+	conn, err := c.Pool.Get()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return c.fetch(conn, id, dest, name, args)
+}
+
+// DoCommandConn executes a command on conn using cached replies for read commands
+//
+// The connection must be connected to the same server as the pool.
+func (c *Cache) DoCommandConn(conn *Conn, dest interface{}, name string, args ...Arg) error {
+	id, hit, err := c.get(dest, name, args)
+	if hit {
+		return err
+	}
+	return c.fetch(conn, id, dest, name, args)
+}
+
+// get decodes a cached reply to dest
+//
+// It returns an empty id if the command is not cacheable.
+func (c *Cache) get(dest interface{}, name string, args []Arg) (id string, hit bool, err error) {
+	c.once.Do(c.init)
+	if !cacheable(name, args) {
+		return "", false, nil
+	}
+	id = cacheID(name, args)
+	c.mu.Lock()
+	el, ok := c.entries[id]
+	if !ok {
+		c.mu.Unlock()
+		return id, false, nil
+	}
+	entry := el.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.removeLocked(el)
+		c.mu.Unlock()
+		return id, false, nil
+	}
+	c.lru.MoveToFront(el)
+	reply := entry.reply
+	c.mu.Unlock()
+	atomic.AddInt64(&c.stats.hits, 1)
+	if dest == nil {
+		return id, true, nil
+	}
+	var msg resp.Message
+	v, err := msg.Parse(reply)
+	if err != nil {
+		return id, true, err
+	}
+	if err := v.Decode(dest); err != nil {
+		return id, true, &resp.DecodeError{
+			Reason: err,
+			Source: v.Any(),
+			Dest:   dest,
+		}
+	}
+	return id, true, nil
+}
+
+// fetch executes a command on conn storing the reply if id is not empty
+func (c *Cache) fetch(conn *Conn, id string, dest interface{}, name string, args []Arg) error {
+	if id == "" {
+		return conn.DoCommand(dest, name, args...)
+	}
+	atomic.AddInt64(&c.stats.misses, 1)
+	tracking, err := c.track(conn)
+	if err != nil {
+		return err
+	}
+	if !tracking {
+		return conn.DoCommand(dest, name, args...)
+	}
+	f := c.beginFetch(cacheKeys(conn.options.KeyPrefix, args))
+	reply := cacheReply{dest: dest}
+	if err := conn.DoCommand(&reply, name, args...); err != nil {
+		c.store(f, id, nil)
+		if e, ok := err.(*resp.DecodeError); ok && e.Dest == &reply {
+			e.Dest = dest
+		}
+		return err
+	}
+	c.store(f, id, reply.raw)
+	return nil
+}
+
+// beginFetch registers a reply being fetched so that invalidations of its keys discard it
+func (c *Cache) beginFetch(keys []string) *cacheFetch {
+	f := cacheFetch{keys: keys}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f.epoch = c.epoch
+	for _, key := range keys {
+		fetches := c.fetches[key]
+		if fetches == nil {
+			fetches = make(map[*cacheFetch]struct{})
+			c.fetches[key] = fetches
+		}
+		fetches[&f] = struct{}{}
+	}
+	return &f
+}
+
+func (c *Cache) endFetchLocked(f *cacheFetch) {
+	for _, key := range f.keys {
+		if fetches := c.fetches[key]; fetches != nil {
+			delete(fetches, f)
+			if len(fetches) == 0 {
+				delete(c.fetches, key)
+			}
+		}
+	}
+}
+
+// track enables CLIENT TRACKING on a connection
+//
+// It returns false if invalidations cannot be received yet.
+func (c *Cache) track(conn *Conn) (bool, error) {
+	c.mu.Lock()
+	redirect, closed := c.redirect, c.closed
+	c.mu.Unlock()
+	if redirect == 0 || closed {
+		return false, nil
+	}
+	if conn.tracking == redirect && conn.cache == c {
+		return true, nil
+	}
+	args := []Arg{String("TRACKING"), String("ON")}
+	if redirect > 0 {
+		args = append(args, String("REDIRECT"), Int64(redirect))
+	} else if conn.Protocol() < 3 {
+		return false, fmt.Errorf("Cache push mode requires RESP3 connections")
+	}
+	var ok AssertOK
+	if err := conn.DoCommand(&ok, "CLIENT", args...); err != nil {
+		return false, fmt.Errorf("CLIENT TRACKING failed: %s", unwrapDecodeError(err))
+	}
+	conn.r.OnPush(c.onPush)
+	conn.tracking = redirect
+	conn.cache = c
+	return true, nil
+}
+
+func (c *Cache) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// store ends a fetch and adds its reply to the cache unless its keys were invalidated meanwhile
+func (c *Cache) store(f *cacheFetch, id string, reply []byte) {
+	entry := cacheEntry{
+		id:    id,
+		keys:  f.keys,
+		reply: reply,
+	}
+	if c.TTL > 0 {
+		entry.expires = time.Now().Add(c.TTL)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endFetchLocked(f)
+	if reply == nil || c.closed || f.stale || c.epoch != f.epoch {
+		// The reply might already be stale
+		return
+	}
+	if el, ok := c.entries[id]; ok {
+		c.removeLocked(el)
+	}
+	el := c.lru.PushFront(&entry)
+	c.entries[id] = el
+	for _, key := range f.keys {
+		els := c.keys[key]
+		if els == nil {
+			els = make(map[*list.Element]struct{})
+			c.keys[key] = els
+		}
+		els[el] = struct{}{}
+	}
+	max := c.MaxEntries
+	if max <= 0 {
+		max = defaultCacheMaxEntries
+	}
+	for c.lru.Len() > max {
+		c.removeLocked(c.lru.Back())
+		atomic.AddInt64(&c.stats.evictions, 1)
+	}
+}
+
+func (c *Cache) removeLocked(el *list.Element) {
+	entry := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, entry.id)
+	for _, key := range entry.keys {
+		if els := c.keys[key]; els != nil {
+			delete(els, el)
+			if len(els) == 0 {
+				delete(c.keys, key)
+			}
+		}
+	}
+}
+
+// invalidate removes all replies that read keys
+func (c *Cache) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		for f := range c.fetches[key] {
+			f.stale = true
+		}
+		for el := range c.keys[key] {
+			c.removeLocked(el)
+			atomic.AddInt64(&c.stats.invalidations, 1)
+		}
+	}
+}
+
+// onPush handles invalidation push messages on tracking connections
+//
+// After the cache is closed invalidations are consumed without effect
+// until the connection stops tracking keys.
+func (c *Cache) onPush(v resp.Value) bool {
+	iter := v.Iter()
+	var kind resp.BulkString
+	if !iter.More() || kind.UnmarshalRESP(iter.Value()) != nil {
+		return false
+	}
+	switch kind.String {
+	case "invalidate":
+		if c.isClosed() {
+			return true
+		}
+		iter.Next()
+		var keys []string
+		if iter.More() && !iter.Value().NullArray() {
+			if err := iter.Value().Decode(&keys); err != nil {
+				c.Flush()
+				return true
+			}
+			c.invalidate(keys...)
+			return true
+		}
+		// All keys were invalidated (ie FLUSHALL)
+		c.Flush()
+		return true
+	case "tracking-redir-broken":
+		// The redirect connection was lost
+		if !c.isClosed() {
+			c.Flush()
+		}
+		return true
+	}
+	return false
+}
+
+func (c *Cache) setRedirect(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.redirect = id
+}
+
+func (c *Cache) retryInterval() time.Duration {
+	if c.RetryInterval > 0 {
+		return c.RetryInterval
+	}
+	return time.Second
+}
+
+// watch receives invalidations on a dedicated connection until closed
+func (c *Cache) watch() {
+	defer close(c.doneCh)
+	for {
+		c.subscribe()
+		// Invalidations might have been lost
+		c.Flush()
+		select {
+		case <-c.closeCh:
+			return
+		case <-time.After(c.retryInterval()):
+		}
+	}
+}
+
+// subscribe listens for invalidations on __redis__:invalidate
+//
+// It returns when the connection fails or the Cache is closed.
+func (c *Cache) subscribe() {
+	conn, err := c.Pool.Dial()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var id int64
+	if err := conn.DoCommand(&id, "CLIENT", String("ID")); err != nil {
+		return
+	}
+	// RESP3 redirect connections receive invalidations as push messages
+	conn.r.OnPush(c.onPush)
+	// Subscribe before enabling tracking so that no invalidations are lost
+	if err := conn.writeCommand("SUBSCRIBE", String(cacheInvalidateChannel)); err != nil {
+		return
+	}
+	var reply pubsub.IncomingMessage
+	if err := conn.Scan(&reply); err != nil || reply.Kind() != pubsub.KindSubscribe {
+		return
+	}
+	sub, err := conn.Subscriber(0)
+	if err != nil {
+		return
+	}
+	sub.subscriptions.Subscribe(cacheInvalidateChannel, false)
+	defer func() {
+		c.setRedirect(0)
+		// Unblock the subscriber if the server does not reply
+		conn.closeConn()
+		_ = sub.Close()
+	}()
+	c.setRedirect(id)
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return
+			}
+			if msg.Channel != cacheInvalidateChannel {
+				continue
+			}
+			if msg.Values == nil {
+				// All keys were invalidated (ie FLUSHALL)
+				c.Flush()
+			} else {
+				c.invalidate(msg.Values...)
+			}
+		}
+	}
+}
+
+// cacheReply records the RESP encoded reply while decoding to dest
+type cacheReply struct {
+	dest interface{}
+	raw  []byte
+}
+
+func (r *cacheReply) UnmarshalRESP(v resp.Value) error {
+	if v.Err() == nil {
+		r.raw = v.AppendRESP(nil)
+	}
+	if r.dest == nil {
+		return nil
+	}
+	return v.Decode(r.dest)
+}
+
+// cacheable checks if a command only reads keys
+func cacheable(name string, args []Arg) bool {
+	switch strings.ToUpper(name) {
+	case "GET", "MGET", "STRLEN", "GETRANGE", "GETBIT", "BITCOUNT", "BITPOS", "BITFIELD_RO", "EXISTS", "TYPE",
+		"HGET", "HMGET", "HGETALL", "HEXISTS", "HLEN", "HKEYS", "HVALS", "HSTRLEN",
+		"LRANGE", "LLEN", "LINDEX",
+		"SMEMBERS", "SISMEMBER", "SMISMEMBER", "SCARD",
+		"ZRANGE", "ZRANGEBYSCORE", "ZRANGEBYLEX", "ZREVRANGE", "ZREVRANGEBYSCORE", "ZREVRANGEBYLEX",
+		"ZSCORE", "ZMSCORE", "ZCARD", "ZCOUNT", "ZLEXCOUNT", "ZRANK", "ZREVRANK":
+	default:
+		return false
+	}
+	for i := range args {
+		if args[i].typ == argKey {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheID encodes a command and its arguments
+func cacheID(name string, args []Arg) string {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, strings.ToUpper(name)...)
+	for i := range args {
+		arg := &args[i]
+		buf = append(buf, ' ', byte(arg.typ))
+		buf = strconv.AppendUint(buf, arg.num, 10)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(len(arg.str)), 10)
+		buf = append(buf, ':')
+		buf = append(buf, arg.str...)
+	}
+	return string(buf)
+}
+
+// cacheKeys returns the keys of a command as seen by the server
+func cacheKeys(prefix string, args []Arg) []string {
+	var keys []string
+	for i := range args {
+		if arg := &args[i]; arg.typ == argKey {
+			keys = append(keys, prefix+arg.str)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,334 @@
+package red_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+// fakeStore is a fake server keeping string values and recording CLIENT TRACKING commands
+type fakeStore struct {
+	mu       sync.Mutex
+	data     map[string]string
+	gets     int
+	tracking []string
+}
+
+func (s *fakeStore) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+}
+
+func (s *fakeStore) Gets() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+func (s *fakeStore) handler(proto int64) func(args []string) resp.Any {
+	return func(args []string) resp.Any {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "HELLO":
+			return helloReply(proto)
+		case "CLIENT":
+			switch strings.ToUpper(args[1]) {
+			case "ID":
+				return resp.Integer(42)
+			case "TRACKING":
+				s.tracking = args
+				return resp.SimpleString("OK")
+			}
+		case "SELECT":
+			return resp.SimpleString("OK")
+		case "GET":
+			s.gets++
+			if v, ok := s.data[args[1]]; ok {
+				return bulk(v)
+			}
+			return &resp.BulkString{}
+		}
+		return resp.Error("ERR unknown command")
+	}
+}
+
+func newFakeStore(t *testing.T, proto int64) (*fakeStore, *fakeServer) {
+	s := fakeStore{
+		data: make(map[string]string),
+	}
+	return &s, newFakeServer(t, s.handler(proto))
+}
+
+func cacheGet(t *testing.T, cache *red.Cache, key string) string {
+	t.Helper()
+	var value string
+	if err := cache.DoCommand(&value, "GET", red.Key(key)); err != nil {
+		t.Fatalf("GET %s failed %s", key, err)
+	}
+	return value
+}
+
+func TestCache(t *testing.T) {
+	store, srv := newFakeStore(t, 2)
+	store.Set("app:foo", "bar")
+	store.Set("app:bar", "baz")
+	store.Set("app:baz", "foo")
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), &red.ConnOptions{
+				KeyPrefix: "app:",
+			})
+		},
+	}
+	defer pool.Close()
+	cache := red.Cache{
+		Pool:          &pool,
+		MaxEntries:    2,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	defer cache.Close()
+
+	// Wait for the invalidation connection
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().Hits == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Cache not ready")
+		}
+		if value := cacheGet(t, &cache, "foo"); value != "bar" {
+			t.Fatalf("Invalid value %q", value)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if expect := []string{"CLIENT", "TRACKING", "ON", "REDIRECT", "42"}; !reflect.DeepEqual(store.tracking, expect) {
+		t.Errorf("Invalid tracking command %v", store.tracking)
+	}
+	gets := store.Gets()
+	if value := cacheGet(t, &cache, "foo"); value != "bar" {
+		t.Errorf("Invalid value %q", value)
+	}
+	if n := store.Gets(); n != gets {
+		t.Errorf("Cached reply not used")
+	}
+
+	// Invalidation
+	store.Set("app:foo", "baz")
+	if n := srv.PublishValue("__redis__:invalidate", resp.Array{bulk("app:foo")}); n != 1 {
+		t.Fatalf("Invalid subscribers %d", n)
+	}
+	for cache.Stats().Invalidations == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Invalidation not received")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if value := cacheGet(t, &cache, "foo"); value != "baz" {
+		t.Errorf("Invalid value after invalidation %q", value)
+	}
+
+	// Eviction
+	cacheGet(t, &cache, "bar")
+	cacheGet(t, &cache, "baz")
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Invalid stats after eviction %v", stats)
+	}
+
+	// Flush all
+	srv.PublishValue("__redis__:invalidate", resp.Array(nil))
+	for cache.Stats().Entries != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Flush not received")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	store, srv := newFakeStore(t, 3)
+	store.Set("foo", "bar")
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), &red.ConnOptions{
+				Protocol: 3,
+			})
+		},
+	}
+	defer pool.Close()
+	cache := red.Cache{
+		Pool: &pool,
+		TTL:  20 * time.Millisecond,
+		Push: true,
+	}
+	defer cache.Close()
+	cacheGet(t, &cache, "foo")
+	cacheGet(t, &cache, "foo")
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Invalid stats %v", stats)
+	}
+	time.Sleep(30 * time.Millisecond)
+	cacheGet(t, &cache, "foo")
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expired reply used %v", stats)
+	}
+}
+
+func TestCache_Push(t *testing.T) {
+	store, srv := newFakeStore(t, 3)
+	store.Set("foo", "bar")
+	store.Set("bar", "baz")
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), &red.ConnOptions{
+				Protocol: 3,
+			})
+		},
+	}
+	defer pool.Close()
+	cache := red.Cache{
+		Pool: &pool,
+		Push: true,
+	}
+	defer cache.Close()
+	if value := cacheGet(t, &cache, "foo"); value != "bar" {
+		t.Errorf("Invalid value %q", value)
+	}
+	if expect := []string{"CLIENT", "TRACKING", "ON"}; !reflect.DeepEqual(store.tracking, expect) {
+		t.Errorf("Invalid tracking command %v", store.tracking)
+	}
+	cacheGet(t, &cache, "foo")
+	if n := store.Gets(); n != 1 {
+		t.Errorf("Cached reply not used")
+	}
+	store.Set("foo", "baz")
+	srv.Push(resp.Push{bulk("invalidate"), resp.Array{bulk("foo")}})
+	// Push messages are handled when the connection reads a reply
+	if value := cacheGet(t, &cache, "bar"); value != "baz" {
+		t.Errorf("Invalid value %q", value)
+	}
+	if value := cacheGet(t, &cache, "foo"); value != "baz" {
+		t.Errorf("Invalid value after invalidation %q", value)
+	}
+	if stats := cache.Stats(); stats.Invalidations != 1 {
+		t.Errorf("Invalid stats %v", stats)
+	}
+	// Connections without RESP3 cannot receive push messages
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	if err := cache.DoCommandConn(conn, nil, "GET", red.Key("baz")); err == nil {
+		t.Errorf("Push mode on RESP2 connection did not fail")
+	}
+}
+
+func TestCache_PushInFlight(t *testing.T) {
+	store, srv := newFakeStore(t, 3)
+	store.Set("foo", "bar")
+	store.Set("bar", "baz")
+	store.Set("baz", "foo")
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), &red.ConnOptions{
+				Protocol: 3,
+			})
+		},
+	}
+	defer pool.Close()
+	cache := red.Cache{
+		Pool: &pool,
+		Push: true,
+	}
+	defer cache.Close()
+	// Enable tracking on the connection
+	cacheGet(t, &cache, "baz")
+
+	// Push messages are read while the reply is fetched
+	srv.Push(resp.Push{bulk("invalidate"), resp.Array{bulk("other")}})
+	cacheGet(t, &cache, "foo")
+	gets := store.Gets()
+	cacheGet(t, &cache, "foo")
+	if n := store.Gets(); n != gets {
+		t.Errorf("Reply not cached after invalidation of another key")
+	}
+
+	srv.Push(resp.Push{bulk("invalidate"), resp.Array{bulk("bar")}})
+	cacheGet(t, &cache, "bar")
+	gets = store.Gets()
+	cacheGet(t, &cache, "bar")
+	if n := store.Gets(); n != gets+1 {
+		t.Errorf("Reply cached after invalidation while fetching")
+	}
+}
+
+func TestCache_PushClose(t *testing.T) {
+	store, srv := newFakeStore(t, 3)
+	store.Set("foo", "bar")
+	store.Set("bar", "baz")
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), &red.ConnOptions{
+				Protocol: 3,
+			})
+		},
+	}
+	defer pool.Close()
+	cache := red.Cache{
+		Pool: &pool,
+		Push: true,
+	}
+	cacheGet(t, &cache, "foo")
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Close failed %s", err)
+	}
+
+	conn, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed %s", err)
+	}
+	// Invalidations are consumed but ignored by a closed cache
+	srv.Push(resp.Push{bulk("invalidate"), resp.Array{bulk("foo")}})
+	var value string
+	if err := conn.DoCommand(&value, "GET", red.Key("foo")); err != nil || value != "bar" {
+		t.Errorf("Invalid reply %q %v", value, err)
+	}
+	if stats := cache.Stats(); stats.Invalidations != 0 {
+		t.Errorf("Closed cache received invalidations %v", stats)
+	}
+	// Tracking is disabled when the connection is released
+	conn.Close()
+	conn, err = pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed %s", err)
+	}
+	// Wait for the server to process the skipped reply
+	if err := conn.DoCommand(nil, "GET", red.Key("foo")); err != nil {
+		t.Fatalf("GET failed %s", err)
+	}
+	conn.Close()
+	store.mu.Lock()
+	tracking := store.tracking
+	store.mu.Unlock()
+	if expect := []string{"CLIENT", "TRACKING", "OFF"}; !reflect.DeepEqual(tracking, expect) {
+		t.Errorf("Invalid tracking command %v", tracking)
+	}
+
+	// Another cache enables tracking again
+	other := red.Cache{
+		Pool: &pool,
+		Push: true,
+	}
+	defer other.Close()
+	cacheGet(t, &other, "foo")
+	srv.Push(resp.Push{bulk("invalidate"), resp.Array{bulk("foo")}})
+	cacheGet(t, &other, "bar")
+	if stats := other.Stats(); stats.Invalidations != 1 {
+		t.Errorf("Invalid stats %v", stats)
+	}
+}
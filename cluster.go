@@ -0,0 +1,750 @@
+package red
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alxarch/red/resp"
+)
+
+// ClusterSlots is the number of hash slots in a redis cluster
+const ClusterSlots = 16384
+
+// ClusterClient is a client for a redis cluster
+//
+// It keeps a Pool for each master node and routes commands to nodes
+// by the hash slot of their first key.
+type ClusterClient struct {
+	noCopy noCopy //nolint:unused,structcheck
+
+	Addrs          []string      // Seed node addresses (required)
+	Options        ConnOptions   // Connection options for all nodes
+	MaxConnections int           // Maximum number of connections per node (defaults to 1)
+	MinConnections int           // Minimum number of connections per node (defaults to 1)
+	MaxIdleTime    time.Duration // Max time a node connection will be left idling (0 => no limit)
+	MaxRedirects   int           // Maximum number of MOVED/ASK redirections to follow (defaults to 5)
+
+	mu        sync.RWMutex
+	closed    bool
+	slots     []*Pool // Pool by slot
+	nodes     map[string]*Pool
+	reloading int32
+}
+
+var (
+	errClusterClosed = errors.New("Cluster client closed")
+	errClusterNoSlot = errors.New("Cluster slots not loaded")
+)
+
+// Slot returns the cluster hash slot of a key
+//
+// If the key contains a non-empty {hashtag} only the hashtag is hashed.
+func Slot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start != -1 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return crc16(key) % ClusterSlots
+}
+
+// KeySlot returns the hash slot of a key with the KeyPrefix option applied
+func (c *ClusterClient) KeySlot(key string) uint16 {
+	return Slot(c.Options.KeyPrefix + key)
+}
+
+// Nodes returns the addresses of all known master nodes
+func (c *ClusterClient) Nodes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	addrs := make([]string, 0, len(c.nodes))
+	for addr := range c.nodes {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// Close closes all node pools
+func (c *ClusterClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClusterClosed
+	}
+	c.closed = true
+	for addr, pool := range c.nodes {
+		delete(c.nodes, addr)
+		_ = pool.Close()
+	}
+	c.slots = nil
+	return nil
+}
+
+// Reload fetches the slot map from the cluster
+//
+// It tries CLUSTER SHARDS first and falls back to CLUSTER SLOTS on older servers.
+// Known nodes are queried first and then the seed addresses.
+func (c *ClusterClient) Reload() error {
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return errClusterClosed
+	}
+	addrs := make([]string, 0, len(c.nodes)+len(c.Addrs))
+	for addr := range c.nodes {
+		addrs = append(addrs, addr)
+	}
+	c.mu.RUnlock()
+	addrs = append(addrs, c.Addrs...)
+	if len(addrs) == 0 {
+		return fmt.Errorf("No cluster addresses")
+	}
+	var err error
+	for _, addr := range addrs {
+		var shards []clusterShard
+		shards, err = c.fetchShards(addr)
+		if err != nil {
+			continue
+		}
+		return c.setShards(shards)
+	}
+	return fmt.Errorf("Cluster reload failed: %s", err)
+}
+
+type clusterShard struct {
+	slots []uint16 // Start, end pairs of slot ranges
+	addr  string   // Master node address
+}
+
+func (c *ClusterClient) fetchShards(addr string) ([]clusterShard, error) {
+	conn, err := Dial(addr, &c.Options)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	host, _, _ := net.SplitHostPort(addr)
+	shards := clusterShards{
+		host: host,
+		tls:  c.Options.TLSConfig != nil,
+	}
+	err = conn.DoCommand(&shards, "CLUSTER", String("SHARDS"))
+	if err == nil {
+		return shards.shards, nil
+	}
+	if conn.Err() != nil {
+		return nil, err
+	}
+	// Redis < 7.0
+	slots := clusterSlots{
+		host: host,
+	}
+	if err := conn.DoCommand(&slots, "CLUSTER", String("SLOTS")); err != nil {
+		return nil, unwrapDecodeError(err)
+	}
+	return slots.shards, nil
+}
+
+func (c *ClusterClient) setShards(shards []clusterShard) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClusterClosed
+	}
+	slots := make([]*Pool, ClusterSlots)
+	active := make(map[string]struct{}, len(shards))
+	for _, shard := range shards {
+		pool := c.nodeLocked(shard.addr)
+		active[shard.addr] = struct{}{}
+		for i := 0; i+1 < len(shard.slots); i += 2 {
+			start, end := shard.slots[i], shard.slots[i+1]
+			for slot := start; slot <= end && slot < ClusterSlots; slot++ {
+				slots[slot] = pool
+			}
+		}
+	}
+	// Close pools of nodes no longer in the cluster
+	for addr, pool := range c.nodes {
+		if _, ok := active[addr]; !ok {
+			delete(c.nodes, addr)
+			_ = pool.Close()
+		}
+	}
+	c.slots = slots
+	return nil
+}
+
+// nodeLocked returns the pool for a node address creating it if needed
+func (c *ClusterClient) nodeLocked(addr string) *Pool {
+	if pool, ok := c.nodes[addr]; ok {
+		return pool
+	}
+	if c.nodes == nil {
+		c.nodes = make(map[string]*Pool)
+	}
+	options := c.Options
+	pool := &Pool{
+		Dial: func() (*Conn, error) {
+			return Dial(addr, &options)
+		},
+		MaxConnections: c.MaxConnections,
+		MinConnections: c.MinConnections,
+		MaxIdleTime:    c.MaxIdleTime,
+	}
+	c.nodes[addr] = pool
+	return pool
+}
+
+// slotNode returns the pool serving a slot
+//
+// If slot is < 0 a random node is returned.
+func (c *ClusterClient) slotNode(slot int) (*Pool, error) {
+	c.mu.RLock()
+	loaded := c.slots != nil
+	c.mu.RUnlock()
+	if !loaded {
+		if err := c.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return nil, errClusterClosed
+	}
+	if c.slots == nil {
+		return nil, errClusterNoSlot
+	}
+	if slot < 0 {
+		slot = rand.Intn(ClusterSlots)
+		// Find the first served slot
+		for i := 0; i < ClusterSlots; i++ {
+			if pool := c.slots[(slot+i)%ClusterSlots]; pool != nil {
+				return pool, nil
+			}
+		}
+		return nil, errClusterNoSlot
+	}
+	if pool := c.slots[slot]; pool != nil {
+		return pool, nil
+	}
+	return nil, fmt.Errorf("Cluster slot %d not served", slot)
+}
+
+func (c *ClusterClient) nodeAddr(pool *Pool) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for addr, p := range c.nodes {
+		if p == pool {
+			return addr
+		}
+	}
+	return ""
+}
+
+func (c *ClusterClient) maxRedirects() int {
+	if c.MaxRedirects > 0 {
+		return c.MaxRedirects
+	}
+	return 5
+}
+
+// argsSlot returns the slot of the first key in args or -1
+func (c *ClusterClient) argsSlot(args []Arg) int {
+	for i := range args {
+		if arg := &args[i]; arg.typ == argKey {
+			return int(c.KeySlot(arg.str))
+		}
+	}
+	return -1
+}
+
+// redirect handles a MOVED/ASK redirection returning the target pool
+func (c *ClusterClient) redirect(r *clusterRedirect, from *Pool) (*Pool, error) {
+	addr := r.addr
+	if strings.HasPrefix(addr, ":") {
+		// Same host as the node that replied
+		if host, _, err := net.SplitHostPort(c.nodeAddr(from)); err == nil {
+			addr = net.JoinHostPort(host, addr[1:])
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, errClusterClosed
+	}
+	pool := c.nodeLocked(addr)
+	if !r.ask && c.slots != nil {
+		c.slots[r.slot] = pool
+		// Slots are probably being migrated so reload the whole map
+		if atomic.CompareAndSwapInt32(&c.reloading, 0, 1) {
+			go func() {
+				defer atomic.StoreInt32(&c.reloading, 0)
+				_ = c.Reload()
+			}()
+		}
+	}
+	return pool, nil
+}
+
+// DoCommand executes a redis command on the node serving its first key
+//
+// Commands without keys are executed on a random node.
+func (c *ClusterClient) DoCommand(dest interface{}, name string, args ...Arg) error {
+	pool, err := c.slotNode(c.argsSlot(args))
+	if err != nil {
+		return err
+	}
+	var asking bool
+	for attempt := 0; ; attempt++ {
+		reply := clusterDest{
+			dest: dest,
+		}
+		// Scripts are rewritten in place so each attempt needs a copy of args
+		argv := append([]Arg(nil), args...)
+		err := c.doCommand(pool, asking, &reply, name, argv)
+		if reply.redirect == nil || attempt >= c.maxRedirects() {
+			return err
+		}
+		if pool, err = c.redirect(reply.redirect, pool); err != nil {
+			return err
+		}
+		asking = reply.redirect.ask
+	}
+}
+
+func (c *ClusterClient) doCommand(pool *Pool, asking bool, dest interface{}, name string, args []Arg) error {
+	conn, err := pool.Get()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !asking {
+		return conn.DoCommand(dest, name, args...)
+	}
+	if err := conn.WriteCommand("ASKING"); err != nil {
+		return err
+	}
+	if err := conn.WriteCommand(name, args...); err != nil {
+		return err
+	}
+	if err := conn.Scan(nil); err != nil {
+		return err
+	}
+	return conn.Scan(dest)
+}
+
+// clusterUnit is a single command or a MULTI/EXEC block of a batch
+type clusterUnit struct {
+	cmds   []batchCmd
+	reply  *batchReply
+	pool   *Pool
+	asking bool
+	// Wrapped replies for the current attempt
+	sub    *batchReply
+	queued []*batchReply
+}
+
+// redirected checks if the unit's last attempt was redirected
+func (u *clusterUnit) redirected() *clusterRedirect {
+	if u.queued == nil {
+		return u.sub.dest.(*clusterDest).redirect
+	}
+	// MULTI/EXEC replies are checked by their error
+	for _, q := range u.queued {
+		if r := q.dest.(*clusterDest).redirect; r != nil {
+			return r
+		}
+		if q.err != nil {
+			if r := parseRedirect(q.err.Error()); r != nil {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// resolve copies the last attempt's results to the batch replies
+func (u *clusterUnit) resolve() {
+	if u.queued == nil {
+		u.reply.err = u.sub.err
+		return
+	}
+	queued, _ := u.reply.dest.([]*batchReply)
+	for i, q := range u.queued {
+		if i < len(queued) {
+			queued[i].err = q.err
+		}
+	}
+	u.reply.err = u.sub.err
+}
+
+// prepare creates wrapped replies for a new attempt
+func (u *clusterUnit) prepare() {
+	if queued, ok := u.reply.dest.([]*batchReply); ok {
+		u.queued = make([]*batchReply, len(queued))
+		for i, q := range queued {
+			u.queued[i] = &batchReply{
+				dest: &clusterDest{dest: q.dest},
+			}
+		}
+		u.sub = &batchReply{
+			dest: u.queued,
+		}
+		return
+	}
+	u.sub = &batchReply{
+		dest: &clusterDest{dest: u.reply.dest},
+	}
+}
+
+// DoBatch executes a batch splitting it to per-node pipelines
+//
+// Replies are bound in the order of the original batch.
+// Commands in a MULTI/EXEC block are sent to the node of the first key in the block.
+func (c *ClusterClient) DoBatch(b *Batch) error {
+	defer b.Reset()
+	units, err := c.splitBatch(&b.batchAPI)
+	if err != nil {
+		for _, reply := range b.replies {
+			reply.reject(err)
+		}
+		return err
+	}
+	for attempt := 0; len(units) > 0; attempt++ {
+		groups := make(map[*Pool][]*clusterUnit)
+		var order []*Pool
+		for _, u := range units {
+			if _, ok := groups[u.pool]; !ok {
+				order = append(order, u.pool)
+			}
+			groups[u.pool] = append(groups[u.pool], u)
+		}
+		errs := make([]error, len(order))
+		wg := sync.WaitGroup{}
+		for i, pool := range order {
+			wg.Add(1)
+			go func(i int, pool *Pool, units []*clusterUnit) {
+				defer wg.Done()
+				errs[i] = c.doUnits(pool, b.w.args, units)
+			}(i, pool, groups[pool])
+		}
+		wg.Wait()
+		for _, e := range errs {
+			if e != nil && err == nil {
+				err = e
+			}
+		}
+		var retry []*clusterUnit
+		for _, u := range units {
+			r := u.redirected()
+			if r == nil || attempt >= c.maxRedirects() {
+				u.resolve()
+				continue
+			}
+			pool, err := c.redirect(r, u.pool)
+			if err != nil {
+				u.resolve()
+				continue
+			}
+			u.pool, u.asking = pool, r.ask
+			retry = append(retry, u)
+		}
+		units = retry
+	}
+	return err
+}
+
+func (c *ClusterClient) splitBatch(b *batchAPI) ([]*clusterUnit, error) {
+	var units []*clusterUnit
+	cmds := b.w.commands
+	for i, n := 0, 0; i < len(cmds); i, n = i+1, n+1 {
+		if n >= len(b.replies) {
+			return nil, fmt.Errorf("Invalid batch")
+		}
+		u := clusterUnit{
+			cmds:  cmds[i : i+1],
+			reply: b.replies[n],
+		}
+		slot := c.argsSlot(cmds[i].Args(b.w.args))
+		if cmds[i].name == "MULTI" {
+			j := i + 1
+			for ; j < len(cmds) && cmds[j].name != "EXEC"; j++ {
+				if slot == -1 {
+					slot = c.argsSlot(cmds[j].Args(b.w.args))
+				}
+			}
+			if j == len(cmds) {
+				return nil, fmt.Errorf("Invalid MULTI/EXEC block")
+			}
+			u.cmds = cmds[i : j+1]
+			i = j
+		}
+		pool, err := c.slotNode(slot)
+		if err != nil {
+			return nil, err
+		}
+		u.pool = pool
+		units = append(units, &u)
+	}
+	return units, nil
+}
+
+func (c *ClusterClient) doUnits(pool *Pool, args []Arg, units []*clusterUnit) error {
+	sub := batchAPI{}
+	for _, u := range units {
+		u.prepare()
+		if u.asking {
+			_ = sub.w.WriteCommand("ASKING")
+			sub.replies = append(sub.replies, &batchReply{})
+		}
+		for i := range u.cmds {
+			cmd := &u.cmds[i]
+			_ = sub.w.WriteCommand(cmd.name, cmd.Args(args)...)
+		}
+		sub.replies = append(sub.replies, u.sub)
+	}
+	conn, err := pool.Get()
+	if err != nil {
+		for _, reply := range sub.replies {
+			reply.reject(err)
+		}
+		return err
+	}
+	defer conn.Close()
+	return conn.doBatch(&sub)
+}
+
+// clusterRedirect is a MOVED or ASK redirection
+type clusterRedirect struct {
+	ask  bool
+	slot uint16
+	addr string
+}
+
+// parseRedirect parses MOVED/ASK errors
+//
+//     MOVED 3999 127.0.0.1:6381
+//     ASK 3999 127.0.0.1:6381
+func parseRedirect(msg string) *clusterRedirect {
+	var r clusterRedirect
+	switch {
+	case strings.HasPrefix(msg, "MOVED "):
+		msg = msg[len("MOVED "):]
+	case strings.HasPrefix(msg, "ASK "):
+		msg = msg[len("ASK "):]
+		r.ask = true
+	default:
+		return nil
+	}
+	i := strings.IndexByte(msg, ' ')
+	if i == -1 {
+		return nil
+	}
+	slot, err := strconv.ParseUint(msg[:i], 10, 16)
+	if err != nil || slot >= ClusterSlots {
+		return nil
+	}
+	r.slot = uint16(slot)
+	r.addr = msg[i+1:]
+	return &r
+}
+
+// clusterDest wraps a reply destination to capture redirections
+type clusterDest struct {
+	dest     interface{}
+	redirect *clusterRedirect
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (d *clusterDest) UnmarshalRESP(v resp.Value) error {
+	if err := v.Err(); err != nil {
+		if r := parseRedirect(err.Error()); r != nil {
+			d.redirect = r
+			return err
+		}
+	}
+	if d.dest == nil {
+		return nil
+	}
+	return v.Decode(d.dest)
+}
+
+// clusterShards decodes a CLUSTER SHARDS reply
+type clusterShards struct {
+	host   string
+	tls    bool
+	shards []clusterShard
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (s *clusterShards) UnmarshalRESP(v resp.Value) error {
+	if err := v.Err(); err != nil {
+		return err
+	}
+	iter := v.Iter()
+	defer iter.Close()
+	for ; iter.More(); iter.Next() {
+		shard := clusterShard{}
+		var slots []int64
+		if err := iter.Value().EachPair(func(k string, v resp.Value) error {
+			switch k {
+			case "slots":
+				return v.Decode(&slots)
+			case "nodes":
+				it := v.Iter()
+				defer it.Close()
+				for ; it.More(); it.Next() {
+					node := clusterShardNode{}
+					if err := it.Value().Decode(&node); err != nil {
+						return err
+					}
+					if node.role == "master" {
+						shard.addr = node.addr(s.host, s.tls)
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if shard.addr == "" {
+			continue
+		}
+		for _, n := range slots {
+			shard.slots = append(shard.slots, uint16(n))
+		}
+		s.shards = append(s.shards, shard)
+	}
+	return nil
+}
+
+type clusterShardNode struct {
+	ip, endpoint, role string
+	port, tlsPort      int64
+}
+
+func (n *clusterShardNode) addr(host string, tls bool) string {
+	if n.endpoint != "" && n.endpoint != "?" {
+		host = n.endpoint
+	} else if n.ip != "" {
+		host = n.ip
+	}
+	port := n.port
+	if tls && n.tlsPort > 0 {
+		port = n.tlsPort
+	}
+	return net.JoinHostPort(host, strconv.FormatInt(port, 10))
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (n *clusterShardNode) UnmarshalRESP(v resp.Value) error {
+	return v.EachPair(func(k string, v resp.Value) error {
+		switch k {
+		case "ip":
+			return v.Decode(&n.ip)
+		case "endpoint":
+			return v.Decode(&n.endpoint)
+		case "role":
+			return v.Decode(&n.role)
+		case "port":
+			return v.Decode(&n.port)
+		case "tls-port":
+			return v.Decode(&n.tlsPort)
+		}
+		return nil
+	})
+}
+
+// clusterSlots decodes a CLUSTER SLOTS reply
+type clusterSlots struct {
+	host   string
+	shards []clusterShard
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+//
+//     1) 1) (integer) 0
+//        2) (integer) 5460
+//        3) 1) "127.0.0.1"
+//           2) (integer) 30001
+//           3) "09dbe9720cda62f7865eabc5fd8857c5d2678366"
+//        4) ... replicas
+func (s *clusterSlots) UnmarshalRESP(v resp.Value) error {
+	if err := v.Err(); err != nil {
+		return err
+	}
+	iter := v.Iter()
+	defer iter.Close()
+	for ; iter.More(); iter.Next() {
+		var (
+			start, end int64
+			host       string
+			port       int64
+		)
+		it := iter.Value().Iter()
+		for i := 0; it.More() && i < 3; i++ {
+			var err error
+			switch i {
+			case 0:
+				err = it.Value().Decode(&start)
+			case 1:
+				err = it.Value().Decode(&end)
+			case 2:
+				node := it.Value().Iter()
+				if node.More() {
+					err = node.Value().Decode(&host)
+					node.Next()
+				}
+				if err == nil && node.More() {
+					err = node.Value().Decode(&port)
+				}
+				node.Close()
+			}
+			if err != nil {
+				it.Close()
+				return err
+			}
+			it.Next()
+		}
+		it.Close()
+		if host == "" {
+			host = s.host
+		}
+		s.shards = append(s.shards, clusterShard{
+			slots: []uint16{uint16(start), uint16(end)},
+			addr:  net.JoinHostPort(host, strconv.FormatInt(port, 10)),
+		})
+	}
+	return nil
+}
+
+// crc16 implements CRC16-CCITT (XMODEM) as used by redis cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}()
@@ -0,0 +1,363 @@
+package red_test
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestSlot(t *testing.T) {
+	for key, slot := range map[string]uint16{
+		"":                      0,
+		"foo":                   12182,
+		"123456789":             12739,
+		"{user1000}.following":  red.Slot("user1000"),
+		"{user1000}.followers":  red.Slot("user1000"),
+		"foo{}{bar}":            red.Slot("foo{}{bar}"),
+		"foo{{bar}}zap":         red.Slot("{bar"),
+		"foo{bar}{zap}":         red.Slot("bar"),
+		"{user1000":             red.Slot("{user1000"),
+		"prefix:{tag}:whatever": red.Slot("tag"),
+	} {
+		if s := red.Slot(key); s != slot {
+			t.Errorf("Invalid slot for %q %d != %d", key, s, slot)
+		}
+	}
+	if red.Slot("foo{}{bar}") == red.Slot("bar") {
+		t.Errorf("Empty hashtag should hash the whole key")
+	}
+}
+
+// fakeCluster is a cluster of fake servers splitting slots evenly
+type fakeCluster struct {
+	mu     sync.Mutex
+	nodes  []*fakeServer
+	owners []int               // Node index by slot
+	data   []map[string]string // Data by node
+	ask    map[string]int      // Keys that reply with ASK to a node
+	moved  int                 // Number of MOVED replies
+	asked  int                 // Number of ASK replies
+	shards bool                // Support CLUSTER SHARDS
+}
+
+func newFakeCluster(t *testing.T, size int, shards bool) *fakeCluster {
+	c := fakeCluster{
+		owners: make([]int, red.ClusterSlots),
+		ask:    make(map[string]int),
+		shards: shards,
+	}
+	for i := 0; i < size; i++ {
+		c.data = append(c.data, make(map[string]string))
+	}
+	for slot := range c.owners {
+		c.owners[slot] = slot * size / red.ClusterSlots
+	}
+	for i := 0; i < size; i++ {
+		c.nodes = append(c.nodes, newFakeServer(t, c.handler(i)))
+	}
+	return &c
+}
+
+func (c *fakeCluster) Addrs() (addrs []string) {
+	for _, node := range c.nodes {
+		addrs = append(addrs, node.Addr())
+	}
+	return
+}
+
+// ranges returns slot ranges by node
+func (c *fakeCluster) ranges() [][]int {
+	ranges := make([][]int, len(c.nodes))
+	start := 0
+	for slot := 1; slot <= len(c.owners); slot++ {
+		if slot == len(c.owners) || c.owners[slot] != c.owners[start] {
+			n := c.owners[start]
+			ranges[n] = append(ranges[n], start, slot-1)
+			start = slot
+		}
+	}
+	return ranges
+}
+
+func (c *fakeCluster) nodePort(n int) int64 {
+	_, port, _ := net.SplitHostPort(c.nodes[n].Addr())
+	p, _ := strconv.ParseInt(port, 10, 64)
+	return p
+}
+
+func bulk(s string) *resp.BulkString {
+	return &resp.BulkString{String: s, Valid: true}
+}
+
+func (c *fakeCluster) slotsReply() resp.Any {
+	var reply resp.Array
+	for n, ranges := range c.ranges() {
+		for i := 0; i < len(ranges); i += 2 {
+			reply = append(reply, resp.Array{
+				resp.Integer(ranges[i]),
+				resp.Integer(ranges[i+1]),
+				resp.Array{bulk("127.0.0.1"), resp.Integer(c.nodePort(n)), bulk(fmt.Sprintf("node%d", n))},
+			})
+		}
+	}
+	return reply
+}
+
+func (c *fakeCluster) shardsReply() resp.Any {
+	var reply resp.Array
+	for n, ranges := range c.ranges() {
+		var slots resp.Array
+		for _, s := range ranges {
+			slots = append(slots, resp.Integer(s))
+		}
+		reply = append(reply, resp.Map{
+			bulk("slots"), slots,
+			bulk("nodes"), resp.Array{
+				resp.Map{
+					bulk("id"), bulk(fmt.Sprintf("node%d", n)),
+					bulk("port"), resp.Integer(c.nodePort(n)),
+					bulk("ip"), bulk("127.0.0.1"),
+					bulk("endpoint"), bulk("127.0.0.1"),
+					bulk("role"), bulk("master"),
+				},
+				resp.Map{
+					bulk("id"), bulk(fmt.Sprintf("replica%d", n)),
+					bulk("port"), resp.Integer(1),
+					bulk("ip"), bulk("127.0.0.1"),
+					bulk("endpoint"), bulk("127.0.0.1"),
+					bulk("role"), bulk("replica"),
+				},
+			},
+		})
+	}
+	return reply
+}
+
+func (c *fakeCluster) handler(n int) func(args []string) resp.Any {
+	var asking, multi bool
+	var queued []resp.Any
+	return func(args []string) resp.Any {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		cmd := strings.ToUpper(args[0])
+		isAsking := asking
+		asking = false
+		switch cmd {
+		case "CLUSTER":
+			switch strings.ToUpper(args[1]) {
+			case "SLOTS":
+				return c.slotsReply()
+			case "SHARDS":
+				if c.shards {
+					return c.shardsReply()
+				}
+			}
+			return resp.Error("ERR unknown subcommand")
+		case "ASKING":
+			asking = true
+			return resp.SimpleString("OK")
+		case "MULTI":
+			multi, queued = true, nil
+			return resp.SimpleString("OK")
+		case "EXEC":
+			multi = false
+			return resp.Array(queued)
+		case "PING":
+			return resp.SimpleString("PONG")
+		case "SELECT":
+			return resp.SimpleString("OK")
+		}
+		if len(args) < 2 {
+			return resp.Error("ERR wrong number of arguments")
+		}
+		key := args[1]
+		slot := red.Slot(key)
+		if to, ok := c.ask[key]; ok && to != n && !isAsking {
+			c.asked++
+			return resp.Error(fmt.Sprintf("ASK %d %s", slot, c.nodes[to].Addr()))
+		}
+		if owner := c.owners[slot]; owner != n && !(isAsking && c.ask[key] == n) {
+			c.moved++
+			return resp.Error(fmt.Sprintf("MOVED %d %s", slot, c.nodes[owner].Addr()))
+		}
+		var reply resp.Any
+		switch cmd {
+		case "SET":
+			c.data[n][key] = args[2]
+			reply = resp.SimpleString("OK")
+		case "GET":
+			if v, ok := c.data[n][key]; ok {
+				reply = bulk(v)
+			} else {
+				reply = &resp.BulkString{}
+			}
+		default:
+			return resp.Error("ERR unknown command")
+		}
+		if multi {
+			queued = append(queued, reply)
+			return resp.SimpleString("QUEUED")
+		}
+		return reply
+	}
+}
+
+func TestClusterClient(t *testing.T) {
+	for _, shards := range []bool{true, false} {
+		fc := newFakeCluster(t, 3, shards)
+		cluster := red.ClusterClient{
+			Addrs: fc.Addrs()[:1],
+			Options: red.ConnOptions{
+				KeyPrefix: "test:",
+			},
+		}
+		defer cluster.Close()
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key%d", i)
+			if err := cluster.DoCommand(nil, "SET", red.Key(key), red.String(key)); err != nil {
+				t.Fatalf("SET %s failed %s", key, err)
+			}
+		}
+		if n := len(cluster.Nodes()); n != 3 {
+			t.Errorf("Invalid nodes %d", n)
+		}
+		if fc.moved != 0 {
+			t.Errorf("Invalid slot routing %d MOVED replies", fc.moved)
+		}
+		for n, data := range fc.data {
+			if len(data) == 0 {
+				t.Errorf("No keys on node %d", n)
+			}
+			for key := range data {
+				if !strings.HasPrefix(key, "test:") {
+					t.Errorf("Key prefix not applied %q", key)
+				}
+			}
+		}
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key%d", i)
+			var value string
+			if err := cluster.DoCommand(&value, "GET", red.Key(key)); err != nil {
+				t.Fatalf("GET %s failed %s", key, err)
+			}
+			if value != key {
+				t.Errorf("Invalid value %q != %q", value, key)
+			}
+		}
+	}
+}
+
+func TestClusterClient_Redirect(t *testing.T) {
+	fc := newFakeCluster(t, 3, true)
+	cluster := red.ClusterClient{
+		Addrs: fc.Addrs(),
+	}
+	defer cluster.Close()
+	if err := cluster.Reload(); err != nil {
+		t.Fatalf("Reload failed %s", err)
+	}
+	// Move the slot of foo to the next node
+	slot := red.Slot("foo")
+	fc.mu.Lock()
+	from := fc.owners[slot]
+	to := (from + 1) % 3
+	fc.owners[slot] = to
+	fc.mu.Unlock()
+	if err := cluster.DoCommand(nil, "SET", red.Key("foo"), red.String("bar")); err != nil {
+		t.Fatalf("SET failed %s", err)
+	}
+	fc.mu.Lock()
+	if fc.moved != 1 {
+		t.Errorf("Invalid MOVED replies %d", fc.moved)
+	}
+	if fc.data[to]["foo"] != "bar" {
+		t.Errorf("MOVED not followed")
+	}
+	// Start migrating bar to another node
+	slot = red.Slot("bar")
+	from = fc.owners[slot]
+	to = (from + 1) % 3
+	fc.ask["bar"] = to
+	fc.mu.Unlock()
+	if err := cluster.DoCommand(nil, "SET", red.Key("bar"), red.String("baz")); err != nil {
+		t.Fatalf("SET failed %s", err)
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.asked != 1 {
+		t.Errorf("Invalid ASK replies %d", fc.asked)
+	}
+	if fc.data[to]["bar"] != "baz" {
+		t.Errorf("ASK not followed")
+	}
+	if fc.owners[slot] != from {
+		t.Errorf("ASK changed the slot owner")
+	}
+}
+
+func TestClusterClient_DoBatch(t *testing.T) {
+	fc := newFakeCluster(t, 3, true)
+	cluster := red.ClusterClient{
+		Addrs: fc.Addrs()[:1],
+	}
+	defer cluster.Close()
+	b := red.Batch{}
+	var sets []*red.ReplyOK
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		sets = append(sets, b.Set(key, key, 0))
+	}
+	if err := cluster.DoBatch(&b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	for i, reply := range sets {
+		if _, err := reply.Reply(); err != nil {
+			t.Errorf("SET %d failed %s", i, err)
+		}
+	}
+	// Move a slot so that a reply is redirected
+	fc.mu.Lock()
+	slot := red.Slot("key3")
+	fc.owners[slot] = (fc.owners[slot] + 1) % 3
+	fc.data[fc.owners[slot]]["key3"] = "key3"
+	fc.mu.Unlock()
+
+	var gets []*red.ReplyBulkString
+	for i := 0; i < 20; i++ {
+		gets = append(gets, b.Get(fmt.Sprintf("key%d", i)))
+	}
+	tx := red.Tx{}
+	tx.Set("{user}:name", "alice", 0)
+	tx.Set("{user}:email", "alice@example.com", 0)
+	exec := b.Multi(&tx)
+	name := b.Get("{user}:name")
+	if err := cluster.DoBatch(&b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	for i, reply := range gets {
+		value, err := reply.Reply()
+		if err != nil {
+			t.Errorf("GET %d failed %s", i, err)
+		}
+		if expect := fmt.Sprintf("key%d", i); value != expect {
+			t.Errorf("Invalid value %q != %q", value, expect)
+		}
+	}
+	if err := exec.Err(); err != nil {
+		t.Errorf("MULTI/EXEC failed %s", err)
+	}
+	if value, err := name.Reply(); err != nil || value != "alice" {
+		t.Errorf("Invalid value %q %v", value, err)
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.moved != 1 {
+		t.Errorf("Invalid MOVED replies %d", fc.moved)
+	}
+}
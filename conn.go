@@ -1,7 +1,9 @@
 package red
 
 import (
+	"context"
 	"crypto/sha1"
+	"crypto/tls"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -27,11 +29,20 @@ type Conn struct {
 	managed bool
 	state   pipeline.State
 	scripts map[Arg]string // Loaded scripts
+	info    *ServerInfo    // Server info from HELLO handshake
+	// Client id receiving CLIENT TRACKING invalidations (-1 for RESP3 push mode)
+	tracking int64
+	cache    *Cache // Cache receiving invalidations of tracked keys
+	// Deadline set by a context.Context for all reads and writes
+	deadline time.Time
+	// Loaded function libraries
+	libraries map[string]bool
 
 	// Pool fields
 	createdAt  time.Time
 	lastUsedAt time.Time
 	pool       *Pool
+	poolEpoch  uint64
 }
 
 // WriteCommand writes a redis command to the pipeline buffer updating the state
@@ -43,18 +54,28 @@ func (conn *Conn) WriteCommand(name string, args ...Arg) error {
 		name, args = conn.rewriteCommand(name, args)
 	}
 
-	switch name {
+	switch strings.ToUpper(name) {
 	case "CLIENT":
-		return fmt.Errorf("CLIENT commands not allowed")
+		// CLIENT REPLY is only used internally to manage the pipeline
+		if clientSubcommand(args, conn.options.KeyPrefix) == "REPLY" {
+			return fmt.Errorf("CLIENT REPLY commands not allowed")
+		}
 	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
 		return fmt.Errorf("Subscribe commands not allowed")
 	}
+	return conn.writeCommand(name, args...)
+}
 
+// writeCommand writes a command to the pipeline buffer without any checks
+func (conn *Conn) writeCommand(name string, args ...Arg) error {
 	if err := conn.w.WriteCommand(name, args...); err != nil {
-		_ = conn.Close()
+		conn.closeConn()
 		return err
 	}
 	conn.updatePipeline(name, args...)
+	if conn.options.Observer != nil {
+		conn.state.Observe(name, time.Now())
+	}
 	return nil
 }
 
@@ -252,7 +273,17 @@ type ConnOptions struct {
 	DB              int           // Redis DB index
 	KeyPrefix       string        // Prefix all keys
 	Auth            string        // Redis auth
+	Username        string        // Redis ACL username (requires Auth)
+	ClientName      string        // If set, the connection name is set with CLIENT SETNAME
 	Debug           bool          // Disables script injection
+	TLSConfig       *tls.Config   // If set, Dial uses a TLS connection
+	Dialer          *Dialer       // If set, Dial uses this to dial the network connection
+	Protocol        int           // If > 0 use HELLO to negotiate the RESP protocol version (falls back to AUTH if HELLO is not supported)
+
+	// If set, function libraries are loaded before FCALL commands
+	Functions *FunctionRegistry
+	// If set, observes the commands and network traffic of the connection
+	Observer Observer
 }
 
 var (
@@ -281,13 +312,24 @@ func (conn *Conn) Err() error {
 	return errConnClosed
 }
 
+// closeConn closes the underlying network connection
+//
+// It is used when the connection is left in an unknown state (ie after an I/O error).
+// If the connection belongs to a pool it will be discarded when it is released.
+func (conn *Conn) closeConn() {
+	if cn := conn.conn; cn != nil {
+		conn.conn = nil
+		_ = cn.Close()
+	}
+}
+
 // Close closes a redis connection
 func (conn *Conn) Close() error {
 	if conn.pool != nil {
 		err := conn.pool.put(conn)
 		return err
 	}
-	if cn := conn.conn; conn != nil {
+	if cn := conn.conn; cn != nil {
 		conn.conn = nil
 		return cn.Close()
 	}
@@ -314,21 +356,34 @@ func (conn *Conn) Reset(options *ConnOptions) error {
 		_ = conn.WriteCommand("UNWATCH")
 	}
 	if options.WriteOnly {
-		_ = conn.WriteCommand("CLIENT", String("REPLY"), String("OFF"))
+		_ = conn.writeCommand("CLIENT", String("REPLY"), String("OFF"))
 	} else if state.IsReplyOFF() {
-		_ = conn.WriteCommand("CLIENT", String("REPLY"), String("ON"))
+		_ = conn.writeCommand("CLIENT", String("REPLY"), String("ON"))
 	} else if state.IsReplySkip() {
 		_ = conn.WriteCommand("PING")
 	}
 	if DBIndexValid(options.DB) && int(state.DB()) != options.DB {
 		_ = conn.injectCommand("SELECT", Int(options.DB))
 	}
-	return conn.clear()
+	untrack := conn.cache != nil && conn.cache.isClosed()
+	if untrack {
+		// Stop tracking keys for a closed Cache
+		_ = conn.injectCommand("CLIENT", String("TRACKING"), String("OFF"))
+	}
+	if err := conn.clear(); err != nil {
+		return err
+	}
+	if untrack {
+		// Invalidations sent before tracking was disabled were consumed while draining
+		conn.r.OnPush(nil)
+		conn.tracking, conn.cache = 0, nil
+	}
+	return nil
 }
 
 func (conn *Conn) clear() error {
 	if conn.options.WriteOnly {
-		_ = conn.WriteCommand("CLIENT", String("REPLY"), String("OFF"))
+		_ = conn.writeCommand("CLIENT", String("REPLY"), String("OFF"))
 	} else {
 		_ = conn.flush()
 		_ = conn.drain()
@@ -339,20 +394,30 @@ func (conn *Conn) clear() error {
 func (conn *Conn) rewriteCommand(name string, args []Arg) (string, []Arg) {
 	name = strings.ToUpper(name)
 	switch name {
-	case "EVAL":
+	case "EVAL", "EVAL_RO":
 		// Inject scripts
-		if len(args) > 0 {
+		// Scripts in MULTI/EXEC are sent as is since they cannot be retried on NOSCRIPT
+		if len(args) > 0 && !conn.state.IsMulti() {
+			evalSHA := strings.Replace(name, "EVAL", "EVALSHA", 1)
 			arg := args[0]
 			if sha1, ok := conn.scripts[arg]; ok {
 				args[0] = String(sha1)
-				return "EVALSHA", args
+				return evalSHA, args
 			}
 			if script, ok := arg.Value().(string); ok {
 				sha1 := sha1Sum(script)
-				conn.scripts[arg] = sha1
-				conn.injectCommand("SCRIPT", String("LOAD"), String(script))
-				args[0] = String(sha1)
-				return "EVALSHA", args
+				if err := conn.injectCommand("SCRIPT", String("LOAD"), String(script)); err == nil {
+					conn.scripts[arg] = sha1
+					args[0] = String(sha1)
+					return evalSHA, args
+				}
+			}
+		}
+	case "FCALL", "FCALL_RO":
+		// Inject function libraries
+		if len(args) > 0 {
+			if function, ok := args[0].Value().(string); ok {
+				conn.injectLibrary(function)
 			}
 		}
 	}
@@ -374,8 +439,7 @@ func (conn *Conn) injectCommand(name string, args ...Arg) error {
 		// NOTE: any write error in conn.cmd is sticky so it will be returned
 		// by the conn.WriteCommand call at the end of the function
 
-		_ = conn.w.WriteCommand("CLIENT", String("REPLY"), String("SKIP"))
-		conn.updatePipeline("CLIENT", String("REPLY"), String("SKIP"))
+		_ = conn.writeCommand("CLIENT", String("REPLY"), String("SKIP"))
 		return conn.WriteCommand(name, args...)
 	}
 }
@@ -383,7 +447,7 @@ func (conn *Conn) injectCommand(name string, args ...Arg) error {
 // flush flushes the pipeline buffer
 func (conn *Conn) flush() error {
 	if err := conn.w.Flush(); err != nil {
-		_ = conn.Close()
+		conn.closeConn()
 		return err
 	}
 	return nil
@@ -399,7 +463,7 @@ func (conn *Conn) drain() error {
 			continue
 		}
 		if err := conn.scanValue(nil, entry); err != nil {
-			_ = conn.Close()
+			conn.closeConn()
 			return err
 		}
 		// if !conn.state.Dirty() {
@@ -432,15 +496,115 @@ func (conn *Conn) resetTimeout(entry pipeline.Entry) error {
 			timeout = -1
 		}
 	}
-	if timeout == 0 {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	// Context deadline takes precedence if it is sooner
+	if d := conn.deadline; !d.IsZero() && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	if timeout == 0 && deadline.IsZero() {
 		return nil
 	}
-	if timeout > 0 {
-		deadline := time.Now().Add(timeout)
-		return conn.conn.SetReadDeadline(deadline)
+	return conn.conn.SetReadDeadline(deadline)
+}
+
+// write writes to the underlying connection respecting write timeouts
+func (conn *Conn) write(p []byte) (int, error) {
+	cn := conn.conn
+	if cn == nil {
+		return 0, errConnClosed
+	}
+	deadline := conn.deadline
+	if timeout := conn.options.WriteTimeout; timeout > 0 {
+		if d := time.Now().Add(timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if !deadline.IsZero() {
+		if err := cn.SetWriteDeadline(deadline); err != nil {
+			return 0, err
+		}
+	}
+	n, err := cn.Write(p)
+	if obs := conn.options.Observer; obs != nil && n > 0 {
+		obs.ObserveBytes(0, n)
 	}
-	return conn.conn.SetReadDeadline(time.Time{})
+	return n, err
 }
+
+// aLongTimeAgo is used to unblock pending I/O when a context is canceled
+var aLongTimeAgo = time.Unix(1, 0)
+
+// withContext runs fn with deadlines and cancellation from ctx applied to the connection
+//
+// If fn fails after ctx is done the reply is abandoned mid-stream so the connection
+// is closed and ctx.Err() is returned.
+func (conn *Conn) withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := conn.Err(); err != nil {
+		return err
+	}
+	cn := conn.conn
+	deadline, hasDeadline := ctx.Deadline()
+	done := ctx.Done()
+	if done == nil {
+		return fn()
+	}
+	conn.deadline = deadline
+	stop := make(chan struct{})
+	watch := make(chan struct{})
+	go func() {
+		defer close(watch)
+		select {
+		case <-done:
+			// Unblock any pending reads or writes
+			_ = cn.SetDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+	err := fn()
+	close(stop)
+	<-watch
+	conn.deadline = time.Time{}
+	if err != nil {
+		ctxErr := ctx.Err()
+		if ctxErr == nil && hasDeadline && !time.Now().Before(deadline) {
+			// Socket deadline expired before the context timer fired
+			ctxErr = context.DeadlineExceeded
+		}
+		if ctxErr != nil {
+			conn.closeConn()
+			return ctxErr
+		}
+	}
+	if conn.conn != nil {
+		// Clear deadlines left over by ctx
+		if err := cn.SetDeadline(time.Time{}); err != nil {
+			conn.closeConn()
+			return err
+		}
+	}
+	return err
+}
+
+// DoCommandContext executes a redis command using ctx for deadlines and cancellation
+func (conn *Conn) DoCommandContext(ctx context.Context, dest interface{}, name string, args ...Arg) error {
+	return conn.withContext(ctx, func() error {
+		return conn.DoCommand(dest, name, args...)
+	})
+}
+
+// ScanContext decodes a reply to dest using ctx for deadlines and cancellation
+func (conn *Conn) ScanContext(ctx context.Context, dest interface{}) error {
+	return conn.withContext(ctx, func() error {
+		return conn.Scan(dest)
+	})
+}
+
 func isDecodeError(err error) bool {
 	_, ok := err.(*resp.DecodeError)
 	return ok
@@ -448,12 +612,15 @@ func isDecodeError(err error) bool {
 
 func (conn *Conn) scanValue(dest interface{}, entry pipeline.Entry) error {
 	if err := conn.resetTimeout(entry); err != nil {
-		_ = conn.Close()
+		conn.closeConn()
 		return err
 	}
+	if obs := conn.options.Observer; obs != nil {
+		return conn.scanObserved(obs, dest, entry)
+	}
 	if err := conn.r.Decode(dest); err != nil {
 		if !isDecodeError(err) {
-			_ = conn.Close()
+			conn.closeConn()
 		}
 		return err
 	}
@@ -489,6 +656,26 @@ func (conn *Conn) Auth(password string) error {
 	return nil
 }
 
+// AuthUser authenticates a connection as an ACL user
+//
+// Available since 6.0.0.
+func (conn *Conn) AuthUser(username, password string) error {
+	var ok AssertOK
+	if err := conn.DoCommand(&ok, "AUTH", String(username), String(password)); err != nil {
+		return fmt.Errorf("Authentication failed: %s", err)
+	}
+	return nil
+}
+
+// SetClientName sets the name of the connection using CLIENT SETNAME
+func (conn *Conn) SetClientName(name string) error {
+	var ok AssertOK
+	if err := conn.DoCommand(&ok, "CLIENT", String("SETNAME"), String(name)); err != nil {
+		return fmt.Errorf("CLIENT SETNAME failed: %s", err)
+	}
+	return nil
+}
+
 func (conn *Conn) updatePipeline(name string, args ...Arg) {
 	switch name {
 	case "SELECT":
@@ -522,6 +709,12 @@ func (conn *Conn) updatePipeline(name string, args ...Arg) {
 	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BZPOPMIN", "BZPOPMAX":
 		timeout := lastArgTimeout(args)
 		conn.state.Block(timeout)
+	case "BLMOVE":
+		timeout := secondsArgTimeout(args, len(args)-1)
+		conn.state.Block(timeout)
+	case "BLMPOP", "BZMPOP":
+		timeout := secondsArgTimeout(args, 0)
+		conn.state.Block(timeout)
 	default:
 		conn.state.Command()
 	}
@@ -536,6 +729,15 @@ func selectArg(args []Arg) int64 {
 	}
 	return -1
 }
+
+// clientSubcommand returns the subcommand of a CLIENT command as it is written to the server
+func clientSubcommand(args []Arg, keyPrefix string) string {
+	if len(args) > 0 {
+		return strings.ToUpper(args[0].text(keyPrefix))
+	}
+	return ""
+}
+
 func clientReplyArg(args []Arg) string {
 	if len(args) == 2 {
 		arg0, arg1 := args[0], args[1]
@@ -561,6 +763,22 @@ func lastArgTimeout(args []Arg) time.Duration {
 	return 0
 }
 
+// secondsArgTimeout parses a timeout in seconds at args[i]
+func secondsArgTimeout(args []Arg, i int) time.Duration {
+	if 0 <= i && i < len(args) {
+		switch v := args[i].Value().(type) {
+		case float64:
+			return time.Duration(v * float64(time.Second))
+		case int64:
+			return time.Duration(v) * time.Second
+		case string:
+			f, _ := strconv.ParseFloat(v, 64)
+			return time.Duration(f * float64(time.Second))
+		}
+	}
+	return 0
+}
+
 type managedConn struct {
 	*Conn
 }
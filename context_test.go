@@ -0,0 +1,123 @@
+package red_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+// newSlowServer creates a fake server that blocks on SLOW commands until the test ends
+func newSlowServer(t *testing.T) *fakeServer {
+	release := make(chan struct{})
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		if strings.ToUpper(args[0]) == "SLOW" {
+			<-release
+		}
+		return resp.SimpleString("OK")
+	})
+	// Cleanup runs in LIFO order so release happens before the server closes
+	t.Cleanup(func() { close(release) })
+	return srv
+}
+
+func TestConn_DoCommandContext(t *testing.T) {
+	srv := newSlowServer(t)
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := conn.DoCommandContext(ctx, nil, "PING"); err != nil {
+		t.Fatalf("PING failed %s", err)
+	}
+	// Deadline must not leak to commands without a context
+	time.Sleep(60 * time.Millisecond)
+	if err := conn.DoCommand(nil, "PING"); err != nil {
+		t.Fatalf("PING after deadline failed %s", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := conn.DoCommandContext(ctx, nil, "SLOW"); err != context.DeadlineExceeded {
+		t.Errorf("Invalid error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Deadline not respected %s", elapsed)
+	}
+	if err := conn.Err(); err == nil {
+		t.Errorf("Connection not closed after abandoned reply")
+	}
+}
+
+func TestConn_DoBatchContext(t *testing.T) {
+	srv := newSlowServer(t)
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	b := red.Batch{}
+	ping := b.Do("PING")
+	slow := b.Do("SLOW")
+	if err := conn.DoBatchContext(ctx, &b); err != context.Canceled {
+		t.Errorf("Invalid error %v", err)
+	}
+	if _, err := ping.Reply(); err != nil {
+		t.Errorf("PING reply failed %s", err)
+	}
+	if _, err := slow.Reply(); err == nil {
+		t.Errorf("SLOW reply did not fail")
+	}
+	if err := conn.Err(); err == nil {
+		t.Errorf("Connection not closed after abandoned reply")
+	}
+}
+
+func TestPool_GetContext(t *testing.T) {
+	srv := newSlowServer(t)
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), nil)
+		},
+		MaxConnections: 1,
+	}
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		t.Fatalf("GetContext failed %s", err)
+	}
+	if _, err := pool.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Invalid error %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := pool.DoCommandContext(ctx, nil, "PING"); err != context.Canceled {
+		t.Errorf("Invalid error %v", err)
+	}
+	conn.Close()
+
+	// Abandoned replies discard the connection
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.DoCommandContext(ctx, nil, "SLOW"); err != context.DeadlineExceeded {
+		t.Errorf("Invalid error %v", err)
+	}
+	if stats := pool.Stats(); stats.Active != 0 {
+		t.Errorf("Connection not discarded %v", stats)
+	}
+	if err := pool.DoCommandContext(context.Background(), nil, "PING"); err != nil {
+		t.Errorf("PING failed %s", err)
+	}
+}
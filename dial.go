@@ -2,8 +2,12 @@ package red
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/url"
 	"strconv"
@@ -14,22 +18,156 @@ import (
 )
 
 // Dial opens a connection to a redis server
+//
+// If options.TLSConfig is set the connection uses TLS.
+// If options.Dialer is set it is used to dial the network connection.
 func Dial(addr string, options *ConnOptions) (*Conn, error) {
-	conn, err := net.Dial("tcp", addr)
+	if options == nil {
+		options = new(ConnOptions)
+	}
+	conn, err := options.Dialer.Dial(addr)
 	if err != nil {
 		return nil, err
 	}
+	if config := options.TLSConfig; config != nil {
+		conn, err = clientTLS(conn, addr, config, options.Dialer.timeout())
+		if err != nil {
+			return nil, err
+		}
+	}
 	return WrapConn(conn, options)
 }
 
+// DefaultDialTimeout is the dial timeout of a Dialer without a Timeout
+const DefaultDialTimeout = 10 * time.Second
+
+// Dialer dials network connections to a redis server
+//
+// The zero value dials TCP connections with DefaultDialTimeout.
+type Dialer struct {
+	Network   string        // Network to dial (defaults to "tcp")
+	Timeout   time.Duration // Dials (and TLS handshakes) fail if exceeded (0 => DefaultDialTimeout, < 0 => disabled)
+	KeepAlive time.Duration // Keep-alive period for TCP connections (0 => system default, < 0 => disabled)
+	LocalAddr net.Addr      // Local address to use when dialing
+	NetDialer *net.Dialer   // Custom net.Dialer to use as base for the above options
+
+	// DialFunc overrides dialing, ie to use a proxy
+	DialFunc func(network, address string) (net.Conn, error)
+}
+
+// Dial dials a network connection to addr
+//
+// A nil Dialer dials a TCP connection with DefaultDialTimeout
+func (d *Dialer) Dial(addr string) (net.Conn, error) {
+	if d == nil {
+		return net.DialTimeout("tcp", addr, DefaultDialTimeout)
+	}
+	network := d.Network
+	if network == "" {
+		network = "tcp"
+	}
+	if d.DialFunc != nil {
+		return d.DialFunc(network, addr)
+	}
+	dialer := net.Dialer{}
+	if d.NetDialer != nil {
+		dialer = *d.NetDialer
+	}
+	dialer.Timeout = d.timeout()
+	if d.KeepAlive != 0 {
+		dialer.KeepAlive = d.KeepAlive
+	}
+	if d.LocalAddr != nil {
+		dialer.LocalAddr = d.LocalAddr
+	}
+	return dialer.Dial(network, addr)
+}
+
+// timeout returns the timeout for dials and TLS handshakes
+func (d *Dialer) timeout() time.Duration {
+	switch {
+	case d == nil:
+		return DefaultDialTimeout
+	case d.Timeout > 0:
+		return d.Timeout
+	case d.Timeout < 0:
+		return 0
+	case d.NetDialer != nil && d.NetDialer.Timeout > 0:
+		return d.NetDialer.Timeout
+	default:
+		return DefaultDialTimeout
+	}
+}
+
+// clientTLS performs a client TLS handshake over conn
+//
+// The handshake fails if timeout > 0 is exceeded.
+func clientTLS(conn net.Conn, addr string, config *tls.Config, timeout time.Duration) (net.Conn, error) {
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		// Same as tls.Dial
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			config = config.Clone()
+			config.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, config)
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Time{}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return tlsConn, nil
+}
+
+// DialTLS opens a TLS connection to a redis server
+func DialTLS(addr string, config *tls.Config, options *ConnOptions) (*Conn, error) {
+	o := ConnOptions{}
+	if options != nil {
+		o = *options
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	o.TLSConfig = config
+	return Dial(addr, &o)
+}
+
 // ParseURL parses a URL to PoolOptions
+//
+// Supported schemes are redis://, rediss:// (TLS) and unix://
+//
+// Pool options are set with the query params max-connections, min-connections, max-idle-time,
+// max-conn-lifetime, test-on-borrow, health-check-interval, clock-interval, max-concurrent-dials and dial-backoff.
+// The clock-interval param sets the interval of health checks and has no effect without health-check-interval.
+// Connection options are set with db, dial-timeout, keep-alive, read-timeout, write-timeout,
+// read-buffer-size, write-buffer-size, key-prefix, client-name and protocol.
+// TLS options for rediss:// are set with tls-server-name, tls-insecure-skip-verify,
+// tls-ca-file, tls-cert-file and tls-key-file.
 func ParseURL(redisURL string) (*Pool, error) {
+	return ParseURLDialer(redisURL, nil)
+}
+
+// ParseURLDialer parses a URL to PoolOptions using a custom Dialer
+//
+// URL query params for the dialer override the options of dialer.
+func ParseURLDialer(redisURL string, dialer *Dialer) (*Pool, error) {
 	pool := Pool{}
 	u, err := url.Parse(redisURL)
 	if err != nil {
 		return nil, err
 	}
-	pool.Dial, err = dialURL(u)
+	pool.Dial, err = dialURL(u, dialer)
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +184,31 @@ func ParseURL(redisURL string) (*Pool, error) {
 			pool.MaxIdleTime = d
 		}
 	}
+	if v, ok := q["max-conn-lifetime"]; ok && len(v) > 0 {
+		if d, _ := time.ParseDuration(v[0]); d > 0 {
+			pool.MaxConnLifetime = d
+		}
+	}
+	if v, ok := q["test-on-borrow"]; ok && len(v) > 0 {
+		if d, _ := time.ParseDuration(v[0]); d > 0 {
+			pool.TestOnBorrow = d
+		}
+	}
+	if v, ok := q["health-check-interval"]; ok && len(v) > 0 {
+		if d, _ := time.ParseDuration(v[0]); d > 0 {
+			pool.HealthCheckInterval = d
+		}
+	}
+	if v, ok := q["max-concurrent-dials"]; ok && len(v) > 0 {
+		if size, _ := strconv.Atoi(v[0]); size > 0 {
+			pool.MaxConcurrentDials = size
+		}
+	}
+	if v, ok := q["dial-backoff"]; ok && len(v) > 0 {
+		if d, _ := time.ParseDuration(v[0]); d > 0 {
+			pool.DialBackoff = d
+		}
+	}
 	if v, ok := q["max-connections"]; ok && len(v) > 0 {
 		if size, _ := strconv.Atoi(v[0]); size > 0 {
 			pool.MaxConnections = size
@@ -63,26 +226,67 @@ func ParseURL(redisURL string) (*Pool, error) {
 // DialFunc dials a red.Conn
 type DialFunc func() (*Conn, error)
 
-func dialURL(u *url.URL) (DialFunc, error) {
+func dialURL(u *url.URL, dialer *Dialer) (DialFunc, error) {
 	options := ConnOptions{}
-	if u.Scheme != "redis" {
+	dialOptions := Dialer{}
+	if dialer != nil {
+		dialOptions = *dialer
+	}
+	var addr string
+	switch u.Scheme {
+	case "redis", "rediss":
+		if u.Scheme == "rediss" {
+			config, err := tlsConfigURL(u)
+			if err != nil {
+				return nil, err
+			}
+			options.TLSConfig = config
+		}
+		if path := strings.Trim(u.Path, "/"); path != "" {
+			n, err := strconv.ParseInt(path, 10, 32)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf(`Invalid URL path %q`, u.Path)
+			}
+			options.DB = int(n)
+		}
+		host, port := u.Hostname(), u.Port()
+		if port == "" {
+			port = "6379"
+		}
+		addr = host + ":" + port
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf(`Invalid URL path %q`, u.Path)
+		}
+		dialOptions.Network = "unix"
+		addr = u.Path
+	default:
 		return nil, fmt.Errorf(`Invalid URL scheme %q`, u.Scheme)
 	}
 
-	if path := strings.Trim(u.Path, "/"); path != "" {
-		n, err := strconv.ParseInt(path, 10, 32)
+	if user := u.User; user != nil {
+		options.Username = user.Username()
+		options.Auth, _ = user.Password()
+	}
+
+	q := u.Query()
+	if v, ok := q["db"]; ok && len(v) > 0 {
+		n, err := strconv.ParseInt(v[0], 10, 32)
 		if err != nil || n < 0 {
-			return nil, fmt.Errorf(`Invalid URL path %q`, u.Path)
+			return nil, fmt.Errorf(`Invalid db %q`, v[0])
 		}
 		options.DB = int(n)
 	}
-	host, port := u.Hostname(), u.Port()
-	if port == "" {
-		port = "6379"
+	if v, ok := q["dial-timeout"]; ok && len(v) > 0 {
+		if d, _ := time.ParseDuration(v[0]); d > 0 {
+			dialOptions.Timeout = d
+		}
+	}
+	if v, ok := q["keep-alive"]; ok && len(v) > 0 {
+		if d, err := time.ParseDuration(v[0]); err == nil {
+			dialOptions.KeepAlive = d
+		}
 	}
-	addr := host + ":" + port
-
-	q := u.Query()
 	if v, ok := q["read-timeout"]; ok && len(v) > 0 {
 		if d, _ := time.ParseDuration(v[0]); d > 0 {
 			options.ReadTimeout = d
@@ -106,12 +310,64 @@ func dialURL(u *url.URL) (DialFunc, error) {
 	if v, ok := q["key-prefix"]; ok && len(v) > 0 {
 		options.KeyPrefix = v[0]
 	}
+	if v, ok := q["client-name"]; ok && len(v) > 0 {
+		options.ClientName = v[0]
+	}
+	if v, ok := q["protocol"]; ok && len(v) > 0 {
+		if proto, _ := strconv.Atoi(v[0]); proto > 0 {
+			options.Protocol = proto
+		}
+	}
+	options.Dialer = &dialOptions
 	return func() (*Conn, error) {
 		return Dial(addr, &options)
 	}, nil
 
 }
 
+// tlsConfigURL builds a TLS config from rediss:// URL query params
+func tlsConfigURL(u *url.URL) (*tls.Config, error) {
+	config := tls.Config{
+		ServerName: u.Hostname(),
+	}
+	q := u.Query()
+	if v, ok := q["tls-server-name"]; ok && len(v) > 0 {
+		config.ServerName = v[0]
+	}
+	if v, ok := q["tls-insecure-skip-verify"]; ok && len(v) > 0 {
+		skip, err := strconv.ParseBool(v[0])
+		if err != nil {
+			return nil, fmt.Errorf(`Invalid tls-insecure-skip-verify %q`, v[0])
+		}
+		config.InsecureSkipVerify = skip
+	}
+	if v, ok := q["tls-ca-file"]; ok && len(v) > 0 {
+		data, err := ioutil.ReadFile(v[0])
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf(`Invalid tls-ca-file %q`, v[0])
+		}
+		config.RootCAs = pool
+	}
+	if v, ok := q["tls-cert-file"]; ok && len(v) > 0 {
+		certFile, keyFile := v[0], v[0]
+		if v, ok := q["tls-key-file"]; ok && len(v) > 0 {
+			keyFile = v[0]
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	} else if _, ok := q["tls-key-file"]; ok {
+		return nil, errors.New(`Missing tls-cert-file`)
+	}
+	return &config, nil
+}
+
 const minBufferSize = 4096
 
 // WrapConn wraps a net.Conn in a redis connection
@@ -128,25 +384,26 @@ func WrapConn(conn net.Conn, options *ConnOptions) (*Conn, error) {
 	if sizeW < minBufferSize {
 		sizeW = minBufferSize
 	}
-	w := timeoutWriter(conn, options.WriteTimeout)
 	c := Conn{
 		conn:    conn,
 		options: *options,
 		r:       *resp.NewStreamSize(conn, sizeR),
 		w: PipelineWriter{
 			KeyPrefix: options.KeyPrefix,
-			dest:      bufio.NewWriterSize(w, sizeW),
 		},
 		createdAt:  now,
 		lastUsedAt: now,
 		scripts:    make(map[Arg]string),
+		libraries:  make(map[string]bool),
+	}
+	c.w.dest = bufio.NewWriterSize(funcWriter(c.write), sizeW)
+	if obs := options.Observer; obs != nil {
+		c.r = *resp.NewStreamSize(observedReader{conn, obs}, sizeR)
 	}
 
-	if pass := options.Auth; pass != "" {
-		if err := c.Auth(pass); err != nil {
-			conn.Close()
-			return nil, err
-		}
+	if err := c.handshake(options); err != nil {
+		conn.Close()
+		return nil, err
 	}
 
 	if db := options.DB; DBIndexValid(db) {
@@ -156,6 +413,11 @@ func WrapConn(conn net.Conn, options *ConnOptions) (*Conn, error) {
 		}
 	}
 
+	if err := c.loadLibraries(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	if options.WriteOnly {
 		type closeReader interface {
 			CloseRead() error
@@ -166,7 +428,7 @@ func WrapConn(conn net.Conn, options *ConnOptions) (*Conn, error) {
 				return nil, err
 			}
 		}
-		if err := c.WriteCommand("CLIENT", String("REPLY"), String("OFF")); err != nil {
+		if err := c.writeCommand("CLIENT", String("REPLY"), String("OFF")); err != nil {
 			conn.Close()
 			return nil, err
 		}
@@ -181,16 +443,3 @@ var _ io.Writer = (funcWriter)(nil)
 func (f funcWriter) Write(p []byte) (int, error) {
 	return f(p)
 }
-
-func timeoutWriter(conn net.Conn, timeout time.Duration) io.Writer {
-	if timeout > 0 {
-		return funcWriter(func(p []byte) (int, error) {
-			deadline := time.Now().Add(timeout)
-			if err := conn.SetWriteDeadline(deadline); err != nil {
-				return 0, err
-			}
-			return conn.Write(p)
-		})
-	}
-	return conn
-}
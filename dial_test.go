@@ -0,0 +1,310 @@
+package red_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestParseURL_UserInfo(t *testing.T) {
+	var mu sync.Mutex
+	var cmds [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		cmds = append(cmds, args)
+		mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			return resp.SimpleString("PONG")
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+	pool, err := red.ParseURL("redis://alice:secret@" + srv.Addr() + "/2?client-name=worker")
+	if err != nil {
+		t.Fatalf("ParseURL failed %s", err)
+	}
+	defer pool.Close()
+	conn, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed %s", err)
+	}
+	var pong string
+	if err := conn.DoCommand(&pong, "PING"); err != nil {
+		t.Fatalf("PING failed %s", err)
+	}
+	conn.Close()
+	expect := [][]string{
+		{"AUTH", "alice", "secret"},
+		{"CLIENT", "SETNAME", "worker"},
+		{"SELECT", "2"},
+		{"PING"},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(cmds, expect) {
+		t.Errorf("Invalid commands %v", cmds)
+	}
+}
+
+func TestConn_Client(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		if len(args) == 2 && strings.ToUpper(args[0]) == "CLIENT" && strings.ToUpper(args[1]) == "GETNAME" {
+			return &resp.BulkString{String: "worker", Valid: true}
+		}
+		return resp.SimpleString("OK")
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		ClientName: "worker",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	var name string
+	if err := conn.DoCommand(&name, "CLIENT", red.String("GETNAME")); err != nil {
+		t.Fatalf("CLIENT GETNAME failed %s", err)
+	}
+	if name != "worker" {
+		t.Errorf("Invalid client name %q", name)
+	}
+	for _, arg := range []red.Arg{red.String("REPLY"), red.String("reply"), red.Key("Reply")} {
+		if err := conn.WriteCommand("client", arg, red.String("OFF")); err == nil {
+			t.Errorf("CLIENT REPLY not rejected for %v", arg.Value())
+		}
+	}
+	// Commands are not rewritten in debug mode
+	debug, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		Debug: true,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer debug.Close()
+	if err := debug.WriteCommand("client", red.String("reply"), red.String("OFF")); err == nil {
+		t.Errorf("CLIENT REPLY not rejected in debug mode")
+	}
+}
+
+// testCertificate creates a self-signed certificate for 127.0.0.1
+func testCertificate(t *testing.T) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "red"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"redis.local"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tpl, &tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestDialTLS(t *testing.T) {
+	cert, certPEM := testCertificate(t)
+	srv := newFakeServerTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}, func(args []string) resp.Any {
+		return resp.SimpleString("PONG")
+	})
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	conn, err := red.DialTLS(srv.Addr(), &tls.Config{RootCAs: roots}, nil)
+	if err != nil {
+		t.Fatalf("DialTLS failed %s", err)
+	}
+	defer conn.Close()
+	var pong string
+	if err := conn.DoCommand(&pong, "PING"); err != nil {
+		t.Fatalf("PING failed %s", err)
+	}
+	if pong != "PONG" {
+		t.Errorf("Invalid reply %q", pong)
+	}
+	if _, err := red.DialTLS(srv.Addr(), nil, nil); err == nil {
+		t.Errorf("DialTLS did not fail on unknown authority")
+	}
+}
+
+func TestParseURL_TLS(t *testing.T) {
+	cert, certPEM := testCertificate(t)
+	srv := newFakeServerTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}, func(args []string) resp.Any {
+		return resp.SimpleString("PONG")
+	})
+	f, err := ioutil.TempFile("", "red-ca-*.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(certPEM)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := f.Name()
+	for _, u := range []string{
+		"rediss://" + srv.Addr() + "?tls-ca-file=" + url.QueryEscape(caFile),
+		"rediss://" + srv.Addr() + "?tls-server-name=redis.local&tls-ca-file=" + url.QueryEscape(caFile),
+		"rediss://" + srv.Addr() + "?tls-insecure-skip-verify=true",
+	} {
+		pool, err := red.ParseURL(u)
+		if err != nil {
+			t.Fatalf("ParseURL %q failed %s", u, err)
+		}
+		var pong string
+		if err := pool.DoCommand(&pong, "PING"); err != nil {
+			t.Errorf("PING %q failed %s", u, err)
+		}
+		pool.Close()
+	}
+	pool, err := red.ParseURL("rediss://" + srv.Addr() + "?tls-server-name=example.com&tls-ca-file=" + url.QueryEscape(caFile))
+	if err != nil {
+		t.Fatalf("ParseURL failed %s", err)
+	}
+	defer pool.Close()
+	if err := pool.DoCommand(nil, "PING"); err == nil {
+		t.Errorf("PING did not fail on server name mismatch")
+	}
+	if _, err := red.ParseURL("rediss://localhost?tls-ca-file=/nonexistent"); err == nil {
+		t.Errorf("ParseURL did not fail on missing CA file")
+	}
+}
+
+func TestParseURL_Unix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/redis.sock"
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen failed %s", err)
+	}
+	var mu sync.Mutex
+	var cmds [][]string
+	startFakeServer(t, ln, func(args []string) resp.Any {
+		mu.Lock()
+		cmds = append(cmds, args)
+		mu.Unlock()
+		return resp.SimpleString("OK")
+	})
+	pool, err := red.ParseURL("unix://" + path + "?db=2&dial-timeout=1s")
+	if err != nil {
+		t.Fatalf("ParseURL failed %s", err)
+	}
+	defer pool.Close()
+	if err := pool.DoCommand(nil, "PING"); err != nil {
+		t.Fatalf("PING failed %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(cmds, [][]string{{"SELECT", "2"}, {"PING"}}) {
+		t.Errorf("Invalid commands %v", cmds)
+	}
+}
+
+func TestParseURLDialer(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("PONG")
+	})
+	var dials []string
+	pool, err := red.ParseURLDialer("redis://redis.example.com:6380", &red.Dialer{
+		DialFunc: func(network, address string) (net.Conn, error) {
+			dials = append(dials, network+"://"+address)
+			return net.Dial("tcp", srv.Addr())
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseURLDialer failed %s", err)
+	}
+	defer pool.Close()
+	var pong string
+	if err := pool.DoCommand(&pong, "PING"); err != nil {
+		t.Fatalf("PING failed %s", err)
+	}
+	if !reflect.DeepEqual(dials, []string{"tcp://redis.example.com:6380"}) {
+		t.Errorf("Invalid dials %v", dials)
+	}
+}
+
+func TestDialer_Timeout(t *testing.T) {
+	// A server that accepts connections but never replies to the TLS handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	for _, dialer := range []*red.Dialer{
+		{Timeout: 50 * time.Millisecond},
+		// The handshake uses the timeout of the base net.Dialer
+		{NetDialer: &net.Dialer{Timeout: 50 * time.Millisecond}},
+	} {
+		start := time.Now()
+		_, err = red.DialTLS(ln.Addr().String(), nil, &red.ConnOptions{
+			Dialer: dialer,
+		})
+		if err == nil {
+			t.Errorf("Dial did not fail")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Dial timeout not respected %s", elapsed)
+		}
+	}
+}
+
+func TestParseURL_PoolOptions(t *testing.T) {
+	pool, err := red.ParseURL("redis://localhost?health-check-interval=1m&clock-interval=1s&max-connections=4&dial-backoff=100ms")
+	if err != nil {
+		t.Fatalf("ParseURL failed %s", err)
+	}
+	if pool.HealthCheckInterval != time.Minute || pool.ClockInterval != time.Second {
+		t.Errorf("Invalid health check options %s %s", pool.HealthCheckInterval, pool.ClockInterval)
+	}
+	if pool.MaxConnections != 4 || pool.DialBackoff != 100*time.Millisecond {
+		t.Errorf("Invalid pool options %d %s", pool.MaxConnections, pool.DialBackoff)
+	}
+}
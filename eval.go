@@ -3,14 +3,37 @@ package red
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
+	"strings"
 
 	"github.com/alxarch/red/resp"
 )
 
 // Eval evaluates a Lua script
+//
+// If the script is not cached on the server it is loaded again and the call is retried once.
 func (conn *Conn) Eval(dest interface{}, script string, numKeys int, args ...string) error {
-	return conn.DoCommand(dest, evalCmd(script), evalArgs(script, numKeys, args...)...)
+	return conn.eval(dest, evalCmd(script), script, numKeys, args)
 }
+
+// EvalRO evaluates a read-only Lua script
+//
+//     EVAL_RO script numkeys [key [key ...]] [arg [arg ...]]
+//
+// Available since 7.0.0.
+func (conn *Conn) EvalRO(dest interface{}, script string, numKeys int, args ...string) error {
+	return conn.eval(dest, evalCmd(script)+"_RO", script, numKeys, args)
+}
+
+func (conn *Conn) eval(dest interface{}, cmd, script string, numKeys int, args []string) error {
+	err := conn.DoCommand(dest, cmd, evalArgs(script, numKeys, args...)...)
+	if isNoScript(scriptError(dest, err)) && !isSHA1(script) {
+		delete(conn.scripts, String(script))
+		return conn.DoCommand(dest, cmd, evalArgs(script, numKeys, args...)...)
+	}
+	return err
+}
+
 func evalCmd(script string) string {
 	cmd := "EVAL"
 	if isSHA1(script) {
@@ -62,3 +85,128 @@ func evalArgs(script string, numKeys int, args ...string) []Arg {
 	}
 	return argv
 }
+
+// Script is a Lua script with a precomputed SHA1 digest
+//
+// Scripts are evaluated with EVALSHA and fall back to EVAL if the server replies with NOSCRIPT,
+// ie after a SCRIPT FLUSH or a failover.
+// If ReadOnly is set EVALSHA_RO and EVAL_RO are used instead (available since 7.0.0).
+type Script struct {
+	Src      string
+	SHA1     string
+	NumKeys  int
+	ReadOnly bool
+}
+
+// NewScript creates a Script with numKeys keys
+func NewScript(numKeys int, src string) *Script {
+	return &Script{
+		Src:     src,
+		SHA1:    sha1Sum(src),
+		NumKeys: numKeys,
+	}
+}
+
+func (s *Script) evalCmd() string {
+	if s.ReadOnly {
+		return "EVAL_RO"
+	}
+	return "EVAL"
+}
+
+func (s *Script) evalSHACmd() string {
+	if s.ReadOnly {
+		return "EVALSHA_RO"
+	}
+	return "EVALSHA"
+}
+
+func (s *Script) sha1() string {
+	if s.SHA1 == "" {
+		return sha1Sum(s.Src)
+	}
+	return s.SHA1
+}
+
+// EvalScript evaluates a script
+//
+// The first Script.NumKeys args are keys.
+func (conn *Conn) EvalScript(dest interface{}, s *Script, args ...string) error {
+	err := conn.DoCommand(dest, s.evalSHACmd(), evalArgs(s.sha1(), s.NumKeys, args...)...)
+	if isNoScript(scriptError(dest, err)) {
+		delete(conn.scripts, String(s.Src))
+		return conn.DoCommand(dest, s.evalCmd(), evalArgs(s.Src, s.NumKeys, args...)...)
+	}
+	return err
+}
+
+// EvalScript evaluates a script on a pool connection
+func (p *Pool) EvalScript(dest interface{}, s *Script, args ...string) error {
+	conn, err := p.Get()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.EvalScript(dest, s, args...)
+}
+
+// EvalScript evaluates a script
+//
+// Scripts are sent with EVALSHA and a SCRIPT LOAD is queued before them if the connection has not loaded them yet.
+// If the server replies with NOSCRIPT, ie after a SCRIPT FLUSH, the error is returned
+// and the script is loaded again by the next batch.
+// Scripts are not retried in the batch since commands that follow them could miss their writes.
+func (b *batchAPI) EvalScript(s *Script, args ...string) *ReplyAny {
+	argv := evalArgs(s.Src, s.NumKeys, args...)
+	b.args.Append(argv...)
+	reply := b.doAny(s.evalCmd())
+	b.scripts = append(b.scripts, batchScript{
+		reply: reply,
+		arg:   argv[0],
+	})
+	return reply
+}
+
+// batchScript is a script or function call queued in a batch
+type batchScript struct {
+	reply *ReplyAny
+	arg   Arg // Script source or function name
+}
+
+// forgetScripts marks scripts and function libraries as not loaded if their calls failed
+// with NOSCRIPT or "Function not found" so that the next call loads them again
+func (conn *Conn) forgetScripts(scripts []batchScript) {
+	for i := range scripts {
+		s := &scripts[i]
+		_, err := s.reply.Reply()
+		switch {
+		case isNoScript(err):
+			delete(conn.scripts, s.arg)
+		case isFunctionNotFound(err):
+			if function, ok := s.arg.Value().(string); ok {
+				conn.forgetLibrary(function)
+			}
+		}
+	}
+}
+
+// scriptError returns the error of a script call including error values decoded to a resp.Any
+func scriptError(dest interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	if v, ok := dest.(*resp.Any); ok && v != nil {
+		if e, ok := (*v).(resp.Error); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+func isNoScript(err error) bool {
+	var e resp.Error
+	if errors.As(err, &e) {
+		return strings.HasPrefix(string(e), "NOSCRIPT")
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package red_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestScript_NoScript(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	scripts := map[string]bool{}
+	var multi bool
+	var queued resp.Array
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		var reply resp.Any
+		switch cmd := strings.ToUpper(args[0]); cmd {
+		case "SCRIPT":
+			switch strings.ToUpper(args[1]) {
+			case "LOAD":
+				sum := sha1.Sum([]byte(args[2]))
+				sha := hex.EncodeToString(sum[:])
+				scripts[sha] = true
+				return bulk(sha)
+			case "FLUSH":
+				scripts = map[string]bool{}
+			}
+			return resp.SimpleString("OK")
+		case "EVALSHA", "EVALSHA_RO":
+			if !scripts[args[1]] {
+				return resp.Error("NOSCRIPT No matching script. Please use EVAL.")
+			}
+			reply = bulk(args[3])
+		case "EVAL", "EVAL_RO":
+			reply = bulk(args[3])
+		case "MULTI":
+			multi, queued = true, nil
+			return resp.SimpleString("OK")
+		case "EXEC":
+			multi = false
+			return queued
+		default:
+			return resp.SimpleString("OK")
+		}
+		if multi {
+			queued = append(queued, reply)
+			return resp.SimpleString("QUEUED")
+		}
+		return reply
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		KeyPrefix: "app:",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	src := "return KEYS[1]"
+	script := red.NewScript(1, src)
+	sha := script.SHA1
+	var key string
+	if err := conn.EvalScript(&key, script, "foo"); err != nil {
+		t.Fatalf("EvalScript failed %s", err)
+	}
+	if key != "app:foo" {
+		t.Errorf("Invalid reply %q", key)
+	}
+	flush := func() {
+		if err := conn.DoCommand(nil, "SCRIPT", red.String("FLUSH")); err != nil {
+			t.Fatalf("SCRIPT FLUSH failed %s", err)
+		}
+	}
+	flush()
+
+	b := new(red.Batch)
+	reply := b.EvalScript(script, "bar")
+	tx := new(red.Tx)
+	txReply := tx.EvalScript(script, "baz")
+	b.Multi(tx)
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	if _, err := reply.Reply(); err == nil || !strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		t.Errorf("Invalid batch reply error %v", err)
+	}
+	if v, err := txReply.Reply(); err != nil || !reflect.DeepEqual(v, bulk("app:baz")) {
+		t.Errorf("Invalid tx reply %v %v", v, err)
+	}
+	// The script is loaded again by the next batch
+	b = new(red.Batch)
+	reply = b.EvalScript(script, "bar")
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	if v, err := reply.Reply(); err != nil || !reflect.DeepEqual(v, bulk("app:bar")) {
+		t.Errorf("Invalid batch reply %v %v", v, err)
+	}
+	flush()
+
+	if err := conn.Eval(&key, src, 1, "foo"); err != nil {
+		t.Fatalf("Eval failed %s", err)
+	}
+	ro := *script
+	ro.ReadOnly = true
+	if err := conn.EvalScript(&key, &ro, "foo"); err != nil {
+		t.Fatalf("EvalScript failed %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		// EvalScript on an empty script cache
+		{"EVALSHA", sha, "1", "app:foo"},
+		{"SCRIPT", "LOAD", src},
+		{"EVALSHA", sha, "1", "app:foo"},
+		{"SCRIPT", "FLUSH"},
+		// Batch with a stale connection script cache
+		{"EVALSHA", sha, "1", "app:bar"},
+		{"MULTI"},
+		{"EVAL", src, "1", "app:baz"},
+		{"EXEC"},
+		{"SCRIPT", "LOAD", src},
+		{"EVALSHA", sha, "1", "app:bar"},
+		{"SCRIPT", "FLUSH"},
+		// Eval with a stale connection script cache
+		{"EVALSHA", sha, "1", "app:foo"},
+		{"SCRIPT", "LOAD", src},
+		{"EVALSHA", sha, "1", "app:foo"},
+		// Read only script
+		{"EVALSHA_RO", sha, "1", "app:foo"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%q\n%q", commands, expectCommands)
+	}
+}
+
+func TestScript_BatchOrder(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	scripts := map[string]bool{}
+	values := map[string]string{}
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		switch cmd := strings.ToUpper(args[0]); cmd {
+		case "SCRIPT":
+			sum := sha1.Sum([]byte(args[2]))
+			sha := hex.EncodeToString(sum[:])
+			scripts[sha] = true
+			return bulk(sha)
+		case "EVALSHA":
+			if !scripts[args[1]] {
+				return resp.Error("NOSCRIPT No matching script. Please use EVAL.")
+			}
+			values[args[3]] = args[4]
+		case "GET":
+			if v, ok := values[args[1]]; ok {
+				return bulk(v)
+			}
+			return &resp.BulkString{}
+		}
+		return resp.SimpleString("OK")
+	})
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	script := red.NewScript(1, "return redis.call('SET', KEYS[1], ARGV[1])")
+	b := new(red.Batch)
+	reply := b.EvalScript(script, "bar", "2")
+	get := b.Get("bar")
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	if _, err := reply.Reply(); err != nil {
+		t.Errorf("EvalScript failed %s", err)
+	}
+	if v, err := get.Reply(); err != nil || v != "2" {
+		t.Errorf("Invalid GET after script %q %v", v, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		{"SCRIPT", "LOAD", script.Src},
+		{"EVALSHA", script.SHA1, "1", "bar", "2"},
+		{"GET", "bar"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%q\n%q", commands, expectCommands)
+	}
+}
@@ -0,0 +1,230 @@
+package red_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red/resp"
+)
+
+// fakeServer is a minimal RESP server for testing the client without a redis server
+type fakeServer struct {
+	ln      net.Listener
+	handler func(args []string) resp.Any
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	conns   []net.Conn
+	subs    map[*fakeConn][]string
+
+	fakeConns map[*fakeConn]struct{}
+}
+
+// fakeConn is a connection to a fakeServer
+type fakeConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *fakeConn) write(v resp.Any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.Write(v.AppendRESP(nil))
+	return err
+}
+
+// Publish sends a message to all connections subscribed to channel
+func (srv *fakeServer) Publish(channel, payload string) int {
+	return srv.PublishValue(channel, &resp.BulkString{String: payload, Valid: true})
+}
+
+// PublishValue sends a message with any payload to all connections subscribed to channel
+func (srv *fakeServer) PublishValue(channel string, payload resp.Any) int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	n := 0
+	for conn, channels := range srv.subs {
+		for _, ch := range channels {
+			if ch == channel {
+				_ = conn.write(resp.Array{
+					&resp.BulkString{String: "message", Valid: true},
+					&resp.BulkString{String: channel, Valid: true},
+					payload,
+				})
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Push sends a value to all connections
+func (srv *fakeServer) Push(v resp.Any) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for conn := range srv.fakeConns {
+		_ = conn.write(v)
+	}
+}
+
+// subscribe handles SUBSCRIBE/UNSUBSCRIBE commands
+func (srv *fakeServer) subscribe(conn *fakeConn, args []string) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.subs == nil {
+		srv.subs = make(map[*fakeConn][]string)
+	}
+	kind := strings.ToLower(args[0])
+	channels := args[1:]
+	if kind == "unsubscribe" && len(channels) == 0 {
+		channels = srv.subs[conn]
+	}
+	for _, ch := range channels {
+		active := srv.subs[conn]
+		if kind == "subscribe" {
+			active = append(active, ch)
+		} else {
+			for i, c := range active {
+				if c == ch {
+					active = append(active[:i:i], active[i+1:]...)
+					break
+				}
+			}
+		}
+		srv.subs[conn] = active
+		if err := conn.write(resp.Array{
+			&resp.BulkString{String: kind, Valid: true},
+			&resp.BulkString{String: ch, Valid: true},
+			resp.Integer(len(active)),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newFakeServer(t *testing.T, handler func(args []string) resp.Any) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed %s", err)
+	}
+	return startFakeServer(t, ln, handler)
+}
+
+func newFakeServerTLS(t *testing.T, config *tls.Config, handler func(args []string) resp.Any) *fakeServer {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("Listen failed %s", err)
+	}
+	return startFakeServer(t, ln, handler)
+}
+
+func startFakeServer(t *testing.T, ln net.Listener, handler func(args []string) resp.Any) *fakeServer {
+	srv := fakeServer{
+		ln:      ln,
+		handler: handler,
+	}
+	srv.wg.Add(1)
+	go srv.serve()
+	t.Cleanup(srv.Close)
+	return &srv
+}
+
+func (srv *fakeServer) Addr() string {
+	return srv.ln.Addr().String()
+}
+
+// Disconnect closes all client connections as if the server restarted
+func (srv *fakeServer) Disconnect() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for conn := range srv.fakeConns {
+		_ = conn.Close()
+	}
+}
+
+func (srv *fakeServer) Close() {
+	_ = srv.ln.Close()
+	srv.mu.Lock()
+	for _, conn := range srv.conns {
+		_ = conn.Close()
+	}
+	srv.conns = nil
+	srv.mu.Unlock()
+	srv.wg.Wait()
+}
+
+func (srv *fakeServer) serve() {
+	defer srv.wg.Done()
+	for {
+		conn, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		srv.mu.Lock()
+		srv.conns = append(srv.conns, conn)
+		srv.mu.Unlock()
+		srv.wg.Add(1)
+		go srv.serveConn(conn)
+	}
+}
+
+func (srv *fakeServer) serveConn(nc net.Conn) {
+	defer srv.wg.Done()
+	defer nc.Close()
+	conn := &fakeConn{Conn: nc}
+	srv.mu.Lock()
+	if srv.fakeConns == nil {
+		srv.fakeConns = make(map[*fakeConn]struct{})
+	}
+	srv.fakeConns[conn] = struct{}{}
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.subs, conn)
+		delete(srv.fakeConns, conn)
+		srv.mu.Unlock()
+	}()
+	r := bufio.NewReader(conn)
+	var skip, off bool
+	for {
+		cmd, err := resp.ReadAny(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		if err := cmd.Decode(&args); err != nil || len(args) == 0 {
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "SUBSCRIBE", "UNSUBSCRIBE":
+			if err := srv.subscribe(conn, args); err != nil {
+				return
+			}
+			continue
+		}
+		if strings.ToUpper(args[0]) == "CLIENT" && len(args) == 3 && strings.ToUpper(args[1]) == "REPLY" {
+			switch strings.ToUpper(args[2]) {
+			case "SKIP":
+				skip = true
+				continue
+			case "OFF":
+				off = true
+				continue
+			case "ON":
+				off = false
+			}
+		}
+		reply := srv.handler(args)
+		if skip || off || reply == nil {
+			skip = false
+			continue
+		}
+		if err := conn.write(reply); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package red
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alxarch/red/resp"
+)
+
+// Functions
+
+// FunctionLibrary is a library of Redis Functions
+type FunctionLibrary struct {
+	Name      string
+	Code      string
+	Functions []string // Names of registered functions
+}
+
+var (
+	rxLibraryName      = regexp.MustCompile(`^#!\w+\s+name=(\S+)`)
+	rxRegisterFunction = regexp.MustCompile(`redis\.register_function\s*\(?\s*(?:\{\s*function_name\s*=\s*)?['"]([^'"]+)['"]`)
+)
+
+// ParseFunctionLibrary parses the name and the registered functions of library code
+//
+// The code must start with a shebang line ie "#!lua name=mylib".
+func ParseFunctionLibrary(code string) (*FunctionLibrary, error) {
+	m := rxLibraryName.FindStringSubmatch(code)
+	if m == nil {
+		return nil, fmt.Errorf("Missing library name in shebang")
+	}
+	lib := FunctionLibrary{
+		Name: m[1],
+		Code: code,
+	}
+	for _, m := range rxRegisterFunction.FindAllStringSubmatch(code, -1) {
+		lib.Functions = append(lib.Functions, m[1])
+	}
+	if len(lib.Functions) == 0 {
+		return nil, fmt.Errorf("Library %q registers no functions", lib.Name)
+	}
+	return &lib, nil
+}
+
+// FunctionRegistry holds function libraries that connections load when needed
+//
+// Connections with a registry in ConnOptions.Functions inject a FUNCTION LOAD command
+// before the first FCALL to a function of a library, the same way EVAL scripts are injected.
+// If LoadOnConnect is set all libraries are loaded when a connection is established.
+// Libraries that already exist on the server are not replaced.
+type FunctionRegistry struct {
+	LoadOnConnect bool
+
+	mu        sync.RWMutex
+	libraries map[string]*FunctionLibrary
+	functions map[string]*FunctionLibrary
+}
+
+// Register parses and adds library code to the registry
+//
+// A library with the same name replaces the previous one in the registry.
+func (r *FunctionRegistry) Register(code string) (*FunctionLibrary, error) {
+	lib, err := ParseFunctionLibrary(code)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.libraries == nil {
+		r.libraries = make(map[string]*FunctionLibrary)
+		r.functions = make(map[string]*FunctionLibrary)
+	}
+	if old := r.libraries[lib.Name]; old != nil {
+		for _, fn := range old.Functions {
+			delete(r.functions, fn)
+		}
+	}
+	r.libraries[lib.Name] = lib
+	for _, fn := range lib.Functions {
+		r.functions[fn] = lib
+	}
+	return lib, nil
+}
+
+// LibraryOf returns the library that registers function
+func (r *FunctionRegistry) LibraryOf(function string) *FunctionLibrary {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.functions[function]
+}
+
+// Libraries returns all libraries in the registry sorted by name
+func (r *FunctionRegistry) Libraries() []*FunctionLibrary {
+	r.mu.RLock()
+	libs := make([]*FunctionLibrary, 0, len(r.libraries))
+	for _, lib := range r.libraries {
+		libs = append(libs, lib)
+	}
+	r.mu.RUnlock()
+	sort.Slice(libs, func(i, j int) bool {
+		return libs[i].Name < libs[j].Name
+	})
+	return libs
+}
+
+// FCall calls a function
+//
+//     FCALL function numkeys [key [key ...]] [arg [arg ...]]
+//
+// Available since 7.0.0.
+//
+// If the function is not found and its library is in the connection's registry
+// the library is loaded and the call is retried once.
+func (conn *Conn) FCall(dest interface{}, function string, numKeys int, args ...string) error {
+	return conn.fcall(dest, "FCALL", function, numKeys, args)
+}
+
+// FCallRO calls a read-only function
+//
+//     FCALL_RO function numkeys [key [key ...]] [arg [arg ...]]
+//
+// Available since 7.0.0.
+func (conn *Conn) FCallRO(dest interface{}, function string, numKeys int, args ...string) error {
+	return conn.fcall(dest, "FCALL_RO", function, numKeys, args)
+}
+
+func (conn *Conn) fcall(dest interface{}, cmd, function string, numKeys int, args []string) error {
+	err := conn.DoCommand(dest, cmd, evalArgs(function, numKeys, args...)...)
+	if isFunctionNotFound(err) && conn.forgetLibrary(function) {
+		return conn.DoCommand(dest, cmd, evalArgs(function, numKeys, args...)...)
+	}
+	return err
+}
+
+// FunctionLoad loads a function library
+//
+// The reply is the library name.
+func (conn *Conn) FunctionLoad(code string, replace bool) (string, error) {
+	args := ArgBuilder{}
+	args.String("LOAD")
+	args.Flag("REPLACE", replace)
+	args.String(code)
+	var name resp.BulkString
+	if err := conn.DoCommand(&name, "FUNCTION", args.Args()...); err != nil {
+		return "", err
+	}
+	if name.Null() {
+		return "", resp.ErrNull
+	}
+	if conn.libraries != nil {
+		conn.libraries[name.String] = true
+	}
+	return name.String, nil
+}
+
+// injectLibrary injects a FUNCTION LOAD command for the library of function if the connection has not loaded it yet
+//
+// The reply of the injected command is skipped so errors in the library code are never reported.
+// FUNCTION LOAD is sent without REPLACE so a library that is already on the server is kept as is.
+// The library is marked as loaded anyway and calls to its functions fail with "Function not found".
+// The library is loaded again only after a call fails with "Function not found".
+func (conn *Conn) injectLibrary(function string) {
+	registry := conn.options.Functions
+	if registry == nil {
+		return
+	}
+	lib := registry.LibraryOf(function)
+	if lib == nil {
+		return
+	}
+	if conn.libraries[lib.Name] {
+		return
+	}
+	if err := conn.injectCommand("FUNCTION", String("LOAD"), String(lib.Code)); err != nil {
+		return
+	}
+	conn.libraries[lib.Name] = true
+}
+
+// loadLibraries injects FUNCTION LOAD commands for all libraries of the registry
+func (conn *Conn) loadLibraries() error {
+	registry := conn.options.Functions
+	if registry == nil || !registry.LoadOnConnect {
+		return nil
+	}
+	for _, lib := range registry.Libraries() {
+		if err := conn.injectCommand("FUNCTION", String("LOAD"), String(lib.Code)); err != nil {
+			return err
+		}
+		conn.libraries[lib.Name] = true
+	}
+	return nil
+}
+
+// forgetLibrary marks the library of function as not loaded
+//
+// It reports whether the library is in the registry and can be injected again.
+func (conn *Conn) forgetLibrary(function string) bool {
+	if registry := conn.options.Functions; registry != nil && !conn.options.Debug {
+		if lib := registry.LibraryOf(function); lib != nil {
+			delete(conn.libraries, lib.Name)
+			return true
+		}
+	}
+	return false
+}
+
+func isFunctionNotFound(err error) bool {
+	var e resp.Error
+	if errors.As(err, &e) {
+		return strings.Contains(string(e), "Function not found")
+	}
+	return false
+}
+
+// FunctionLoad loads a function library
+//
+//     FUNCTION LOAD [REPLACE] function-code
+//
+// Available since 7.0.0.
+//
+// The reply is the library name.
+func (b *batchAPI) FunctionLoad(code string, replace bool) *ReplyBulkString {
+	b.args.String("LOAD")
+	b.args.Flag("REPLACE", replace)
+	b.args.String(code)
+	return b.doBulkString("FUNCTION")
+}
+
+// FunctionDelete deletes a function library
+func (b *batchAPI) FunctionDelete(library string) *ReplyOK {
+	b.args.String("DELETE")
+	b.args.String(library)
+	return b.doSimpleStringOK("FUNCTION", 0)
+}
+
+// FunctionFlush deletes all function libraries
+func (b *batchAPI) FunctionFlush(async bool) *ReplyOK {
+	b.args.String("FLUSH")
+	b.args.Flag("ASYNC", async)
+	return b.doSimpleStringOK("FUNCTION", 0)
+}
+
+// FunctionDump returns a serialized payload of all function libraries
+func (b *batchAPI) FunctionDump() *ReplyBulkString {
+	b.args.String("DUMP")
+	return b.doBulkString("FUNCTION")
+}
+
+// FunctionRestorePolicy controls how FUNCTION RESTORE handles existing libraries
+type FunctionRestorePolicy string
+
+// Restore policies
+const (
+	RestoreAppend  FunctionRestorePolicy = "APPEND"  // Fail on conflicting library names, the default
+	RestoreReplace FunctionRestorePolicy = "REPLACE" // Replace conflicting libraries
+	RestoreFlush   FunctionRestorePolicy = "FLUSH"   // Delete all existing libraries first
+)
+
+// FunctionRestore restores function libraries from a payload created by FunctionDump
+//
+//     FUNCTION RESTORE serialized-value [FLUSH|APPEND|REPLACE]
+func (b *batchAPI) FunctionRestore(payload string, policy FunctionRestorePolicy) *ReplyOK {
+	b.args.String("RESTORE")
+	b.args.String(payload)
+	if policy != "" {
+		b.args.String(string(policy))
+	}
+	return b.doSimpleStringOK("FUNCTION", 0)
+}
+
+// FunctionList returns information about the function libraries matching pattern
+//
+//     FUNCTION LIST [LIBRARYNAME library-name-pattern] [WITHCODE]
+//
+// If pattern is empty all libraries are listed.
+func (b *batchAPI) FunctionList(pattern string, withCode bool) *ReplyFunctionList {
+	b.args.String("LIST")
+	if pattern != "" {
+		b.args.String("LIBRARYNAME")
+		b.args.String(pattern)
+	}
+	b.args.Flag("WITHCODE", withCode)
+	reply := ReplyFunctionList{}
+	reply.Bind(&reply.libraries)
+	b.do("FUNCTION", &reply.batchReply)
+	return &reply
+}
+
+// FCall calls a function
+//
+// The first numKeys args are keys.
+// If the function's library is in the connection's registry and the connection has not loaded it yet
+// a FUNCTION LOAD is queued before the call.
+// If the call fails with "Function not found", ie after a FUNCTION FLUSH, the error is returned
+// and the library is loaded again by the next batch.
+func (b *batchAPI) FCall(function string, numKeys int, args ...string) *ReplyAny {
+	return b.fcall("FCALL", function, numKeys, args)
+}
+
+// FCallRO calls a read-only function
+//
+// The first numKeys args are keys.
+func (b *batchAPI) FCallRO(function string, numKeys int, args ...string) *ReplyAny {
+	return b.fcall("FCALL_RO", function, numKeys, args)
+}
+
+func (b *batchAPI) fcall(cmd, function string, numKeys int, args []string) *ReplyAny {
+	argv := evalArgs(function, numKeys, args...)
+	b.args.Append(argv...)
+	reply := b.doAny(cmd)
+	b.scripts = append(b.scripts, batchScript{
+		reply: reply,
+		arg:   argv[0],
+	})
+	return reply
+}
+
+// FunctionInfo is the information of a function in FUNCTION LIST
+type FunctionInfo struct {
+	Name        string
+	Description string
+	Flags       []string
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (info *FunctionInfo) UnmarshalRESP(v resp.Value) error {
+	fn := FunctionInfo{}
+	if err := v.EachPair(func(k string, v resp.Value) error {
+		switch k {
+		case "name":
+			return v.Decode(&fn.Name)
+		case "description":
+			if v.Null() {
+				return nil
+			}
+			return v.Decode(&fn.Description)
+		case "flags":
+			return v.Decode(&fn.Flags)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	*info = fn
+	return nil
+}
+
+// FunctionLibraryInfo is the information of a library in FUNCTION LIST
+//
+// Code is only set if requested with WITHCODE.
+type FunctionLibraryInfo struct {
+	Name      string
+	Engine    string
+	Functions []FunctionInfo
+	Code      string
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (info *FunctionLibraryInfo) UnmarshalRESP(v resp.Value) error {
+	lib := FunctionLibraryInfo{}
+	if err := v.EachPair(func(k string, v resp.Value) error {
+		switch k {
+		case "library_name":
+			return v.Decode(&lib.Name)
+		case "engine":
+			return v.Decode(&lib.Engine)
+		case "functions":
+			return v.Decode(&lib.Functions)
+		case "library_code":
+			return v.Decode(&lib.Code)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	*info = lib
+	return nil
+}
+
+// ReplyFunctionList is the reply of a FUNCTION LIST command
+type ReplyFunctionList struct {
+	libraries []FunctionLibraryInfo
+	batchReply
+}
+
+// Reply returns the libraries
+func (r *ReplyFunctionList) Reply() ([]FunctionLibraryInfo, error) {
+	return r.libraries, r.err
+}
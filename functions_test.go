@@ -0,0 +1,260 @@
+package red_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+const testLibrary = `#!lua name=mylib
+local function knockknock()
+	return 'Who is there?'
+end
+redis.register_function('knockknock', knockknock)
+redis.register_function{function_name='peek', callback=function(keys) return redis.call('GET', keys[1]) end, flags={'no-writes'}}
+`
+
+func TestParseFunctionLibrary(t *testing.T) {
+	lib, err := red.ParseFunctionLibrary(testLibrary)
+	if err != nil {
+		t.Fatalf("Parse failed %s", err)
+	}
+	if lib.Name != "mylib" {
+		t.Errorf("Invalid name %q", lib.Name)
+	}
+	if !reflect.DeepEqual(lib.Functions, []string{"knockknock", "peek"}) {
+		t.Errorf("Invalid functions %v", lib.Functions)
+	}
+	if _, err := red.ParseFunctionLibrary("return 1"); err == nil {
+		t.Errorf("Parse should fail without a shebang")
+	}
+}
+
+func TestConn_Functions(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	loaded := false
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		switch strings.ToUpper(args[0]) {
+		case "FCALL", "FCALL_RO":
+			if !loaded || (args[1] != "knockknock" && args[1] != "peek") {
+				return resp.Error("ERR Function not found")
+			}
+			return bulk("Who is there?")
+		case "FUNCTION":
+			switch strings.ToUpper(args[1]) {
+			case "LOAD":
+				loaded = true
+				return bulk("mylib")
+			case "FLUSH":
+				loaded = false
+				return resp.SimpleString("OK")
+			case "LIST":
+				return resp.Array{
+					resp.Array{
+						bulk("library_name"), bulk("mylib"),
+						bulk("engine"), bulk("LUA"),
+						bulk("functions"), resp.Array{
+							resp.Array{
+								bulk("name"), bulk("peek"),
+								bulk("description"), &resp.BulkString{},
+								bulk("flags"), resp.Array{bulk("no-writes")},
+							},
+						},
+					},
+				}
+			}
+			return resp.SimpleString("OK")
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+	registry := red.FunctionRegistry{}
+	if _, err := registry.Register(testLibrary); err != nil {
+		t.Fatalf("Register failed %s", err)
+	}
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		KeyPrefix: "app:",
+		Functions: &registry,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	var reply string
+	if err := conn.FCall(&reply, "knockknock", 0); err != nil {
+		t.Fatalf("FCALL failed %s", err)
+	}
+	if reply != "Who is there?" {
+		t.Errorf("Invalid FCALL reply %q", reply)
+	}
+
+	b := new(red.Batch)
+	b.FunctionFlush(false)
+	list := b.FunctionList("my*", false)
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	libs, err := list.Reply()
+	if err != nil {
+		t.Fatalf("FUNCTION LIST failed %s", err)
+	}
+	expectLibs := []red.FunctionLibraryInfo{{
+		Name:   "mylib",
+		Engine: "LUA",
+		Functions: []red.FunctionInfo{
+			{Name: "peek", Flags: []string{"no-writes"}},
+		},
+	}}
+	if !reflect.DeepEqual(libs, expectLibs) {
+		t.Errorf("Invalid FUNCTION LIST reply %v", libs)
+	}
+
+	// The library was flushed so FCALL is retried after loading it again
+	if err := conn.FCallRO(&reply, "peek", 1, "foo"); err != nil {
+		t.Fatalf("FCALL_RO failed %s", err)
+	}
+	if err := conn.FCall(&reply, "unknown", 0); err == nil {
+		t.Errorf("FCALL should fail for functions not in the registry")
+	}
+
+	mu.Lock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		{"FUNCTION", "LOAD", testLibrary},
+		{"FCALL", "knockknock", "0"},
+		{"FUNCTION", "FLUSH"},
+		{"FUNCTION", "LIST", "LIBRARYNAME", "my*"},
+		{"FCALL_RO", "peek", "1", "app:foo"},
+		{"FUNCTION", "LOAD", testLibrary},
+		{"FCALL_RO", "peek", "1", "app:foo"},
+		{"FCALL", "unknown", "0"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%q\n%q", commands, expectCommands)
+	}
+	commands, loaded = nil, false
+	mu.Unlock()
+
+	registry.LoadOnConnect = true
+	conn2, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		Functions: &registry,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn2.Close()
+	if err := conn2.FCall(&reply, "knockknock", 0); err != nil {
+		t.Fatalf("FCALL failed %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands = [][]string{
+		{"SELECT", "0"},
+		{"FUNCTION", "LOAD", testLibrary},
+		{"FCALL", "knockknock", "0"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%q\n%q", commands, expectCommands)
+	}
+}
+
+func TestBatch_FCall(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	loaded := false
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		switch strings.ToUpper(args[0]) {
+		case "FCALL", "FCALL_RO":
+			if !loaded {
+				return resp.Error("ERR Function not found")
+			}
+			return bulk("Who is there?")
+		case "FUNCTION":
+			switch strings.ToUpper(args[1]) {
+			case "LOAD":
+				if loaded {
+					return resp.Error("ERR Library 'mylib' already exists")
+				}
+				loaded = true
+				return bulk("mylib")
+			case "FLUSH":
+				loaded = false
+			}
+		}
+		return resp.SimpleString("OK")
+	})
+	registry := red.FunctionRegistry{}
+	if _, err := registry.Register(testLibrary); err != nil {
+		t.Fatalf("Register failed %s", err)
+	}
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		Functions: &registry,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	var reply string
+	if err := conn.FCall(&reply, "knockknock", 0); err != nil {
+		t.Fatalf("FCALL failed %s", err)
+	}
+	// The connection still marks the library as loaded
+	if err := conn.DoCommand(nil, "FUNCTION", red.String("FLUSH")); err != nil {
+		t.Fatalf("FUNCTION FLUSH failed %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		b := new(red.Batch)
+		knock := b.FCall("knockknock", 0)
+		peek := b.FCallRO("peek", 1, "foo")
+		if err := conn.DoBatch(b); err != nil {
+			t.Fatalf("DoBatch failed %s", err)
+		}
+		_, knockErr := knock.Reply()
+		_, peekErr := peek.Reply()
+		if i == 0 {
+			// The first batch fails and the library is loaded again by the next one
+			if knockErr == nil || peekErr == nil {
+				t.Errorf("Function calls did not fail %v %v", knockErr, peekErr)
+			}
+			continue
+		}
+		if knockErr != nil {
+			t.Errorf("FCALL failed %s", knockErr)
+		}
+		if peekErr != nil {
+			t.Errorf("FCALL_RO failed %s", peekErr)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		{"FUNCTION", "LOAD", testLibrary},
+		{"FCALL", "knockknock", "0"},
+		{"FUNCTION", "FLUSH"},
+		{"FCALL", "knockknock", "0"},
+		{"FCALL_RO", "peek", "1", "foo"},
+		{"FUNCTION", "LOAD", testLibrary},
+		{"FCALL", "knockknock", "0"},
+		{"FCALL_RO", "peek", "1", "foo"},
+		{"FCALL", "knockknock", "0"},
+		{"FCALL_RO", "peek", "1", "foo"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%q\n%q", commands, expectCommands)
+	}
+}
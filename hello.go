@@ -0,0 +1,185 @@
+package red
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alxarch/red/resp"
+)
+
+// Hello holds arguments for the HELLO command
+//
+//     HELLO [protover [AUTH username password] [SETNAME clientname]]
+//
+// Available since 6.0.0.
+type Hello struct {
+	Proto      int    // Protocol version (2 or 3)
+	Username   string // ACL username (defaults to "default" if a password is set)
+	Password   string // Password for AUTH
+	ClientName string // Client name for SETNAME
+}
+
+// BuildCommand implements CommandBuilder interface
+func (cmd *Hello) BuildCommand(args *ArgBuilder) string {
+	proto := cmd.Proto
+	if proto <= 0 {
+		proto = 2
+	}
+	args.Int(int64(proto))
+	if cmd.Password != "" {
+		user := cmd.Username
+		if user == "" {
+			user = "default"
+		}
+		args.String("AUTH")
+		args.String(user)
+		args.String(cmd.Password)
+	}
+	args.Option("SETNAME", cmd.ClientName)
+	return "HELLO"
+}
+
+// ServerInfo is the server information returned by HELLO
+type ServerInfo struct {
+	Server  string
+	Version string
+	Proto   int
+	ID      int64
+	Mode    string // standalone, sentinel or cluster
+	Role    string // master or replica
+	Modules []ServerModule
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (info *ServerInfo) UnmarshalRESP(v resp.Value) error {
+	if err := v.Err(); err != nil {
+		return err
+	}
+	s := ServerInfo{}
+	if err := v.EachPair(func(k string, v resp.Value) error {
+		switch k {
+		case "server":
+			return v.Decode(&s.Server)
+		case "version":
+			return v.Decode(&s.Version)
+		case "proto":
+			var proto int64
+			if err := v.Decode(&proto); err != nil {
+				return err
+			}
+			s.Proto = int(proto)
+		case "id":
+			return v.Decode(&s.ID)
+		case "mode":
+			return v.Decode(&s.Mode)
+		case "role":
+			return v.Decode(&s.Role)
+		case "modules":
+			return v.Decode(&s.Modules)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	*info = s
+	return nil
+}
+
+// ServerModule is a module loaded on the server
+type ServerModule struct {
+	Name    string
+	Version int64
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (m *ServerModule) UnmarshalRESP(v resp.Value) error {
+	mod := ServerModule{}
+	if err := v.EachPair(func(k string, v resp.Value) error {
+		switch k {
+		case "name":
+			return v.Decode(&mod.Name)
+		case "ver":
+			return v.Decode(&mod.Version)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	*m = mod
+	return nil
+}
+
+// Hello negotiates the protocol with the server
+//
+// On success the server info is recorded on the connection.
+func (conn *Conn) Hello(hello Hello) (*ServerInfo, error) {
+	args := ArgBuilder{}
+	cmd := hello.BuildCommand(&args)
+	info := ServerInfo{}
+	if err := conn.DoCommand(&info, cmd, args.Args()...); err != nil {
+		return nil, unwrapDecodeError(err)
+	}
+	conn.info = &info
+	return &info, nil
+}
+
+// ServerInfo returns the server info recorded during a HELLO handshake
+//
+// If the connection did not use HELLO it returns nil.
+func (conn *Conn) ServerInfo() *ServerInfo {
+	return conn.info
+}
+
+// Protocol returns the RESP protocol version used by the connection
+func (conn *Conn) Protocol() int {
+	if info := conn.info; info != nil && info.Proto > 0 {
+		return info.Proto
+	}
+	return 2
+}
+
+// helloNotSupported checks if a HELLO error means that the server does not support HELLO
+func helloNotSupported(err error) bool {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "NOPROTO"):
+		return true
+	case strings.HasPrefix(msg, "ERR unknown command"):
+		return true
+	default:
+		return false
+	}
+}
+
+func (conn *Conn) handshake(options *ConnOptions) error {
+	if proto := options.Protocol; proto > 0 {
+		_, err := conn.Hello(Hello{
+			Proto:      proto,
+			Username:   options.Username,
+			Password:   options.Auth,
+			ClientName: options.ClientName,
+		})
+		if err == nil {
+			return nil
+		}
+		if !helloNotSupported(err) {
+			return fmt.Errorf("HELLO failed: %s", err)
+		}
+	}
+	// Legacy handshake
+	if pass := options.Auth; pass != "" {
+		if user := options.Username; user != "" {
+			if err := conn.AuthUser(user, pass); err != nil {
+				return err
+			}
+		} else if err := conn.Auth(pass); err != nil {
+			return err
+		}
+	}
+	if name := options.ClientName; name != "" {
+		if err := conn.SetClientName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package red_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func helloReply(proto int64) resp.Any {
+	return resp.Map{
+		resp.SimpleString("server"), &resp.BulkString{String: "redis", Valid: true},
+		resp.SimpleString("version"), &resp.BulkString{String: "6.2.6", Valid: true},
+		resp.SimpleString("proto"), resp.Integer(proto),
+		resp.SimpleString("id"), resp.Integer(42),
+		resp.SimpleString("mode"), &resp.BulkString{String: "standalone", Valid: true},
+		resp.SimpleString("role"), &resp.BulkString{String: "master", Valid: true},
+		resp.SimpleString("modules"), resp.Array{
+			resp.Map{
+				resp.SimpleString("name"), &resp.BulkString{String: "search", Valid: true},
+				resp.SimpleString("ver"), resp.Integer(20206),
+			},
+		},
+	}
+}
+
+func TestConn_Hello(t *testing.T) {
+	var hello []string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		switch strings.ToUpper(args[0]) {
+		case "HELLO":
+			hello = args
+			return helloReply(3)
+		case "SELECT":
+			return resp.SimpleString("OK")
+		default:
+			return resp.Error("ERR unexpected command")
+		}
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		Protocol: 3,
+		Auth:     "secret",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	if !reflect.DeepEqual(hello, []string{"HELLO", "3", "AUTH", "default", "secret"}) {
+		t.Errorf("Invalid HELLO command %v", hello)
+	}
+	info := conn.ServerInfo()
+	if info == nil {
+		t.Fatalf("No server info")
+	}
+	expect := red.ServerInfo{
+		Server:  "redis",
+		Version: "6.2.6",
+		Proto:   3,
+		ID:      42,
+		Mode:    "standalone",
+		Role:    "master",
+		Modules: []red.ServerModule{{Name: "search", Version: 20206}},
+	}
+	if !reflect.DeepEqual(*info, expect) {
+		t.Errorf("Invalid server info %#v", info)
+	}
+	if proto := conn.Protocol(); proto != 3 {
+		t.Errorf("Invalid protocol %d", proto)
+	}
+}
+
+func TestConn_HelloFallback(t *testing.T) {
+	var auth []string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		switch strings.ToUpper(args[0]) {
+		case "HELLO":
+			return resp.Error("ERR unknown command 'HELLO'")
+		case "AUTH":
+			auth = args
+			return resp.SimpleString("OK")
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		Protocol: 3,
+		Auth:     "secret",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	if !reflect.DeepEqual(auth, []string{"AUTH", "secret"}) {
+		t.Errorf("Invalid AUTH command %v", auth)
+	}
+	if info := conn.ServerInfo(); info != nil {
+		t.Errorf("Unexpected server info %v", info)
+	}
+	if proto := conn.Protocol(); proto != 2 {
+		t.Errorf("Invalid protocol %d", proto)
+	}
+}
+
+func TestConn_HelloAuthFailed(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		switch strings.ToUpper(args[0]) {
+		case "HELLO":
+			return resp.Error("WRONGPASS invalid username-password pair")
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+	if _, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		Protocol: 3,
+		Auth:     "secret",
+	}); err == nil {
+		t.Errorf("Dial did not fail")
+	}
+}
@@ -0,0 +1,185 @@
+package red
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alxarch/red/resp"
+)
+
+// Info is the server information returned by INFO
+//
+// Values are grouped by lowercase section name (ie "server", "clients", "keyspace").
+type Info map[string]map[string]string
+
+// ParseInfo parses the text of an INFO reply
+//
+// Lines starting with '#' start a new section and all other lines are key:value pairs.
+func ParseInfo(text string) Info {
+	info := Info{}
+	var section map[string]string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			name := strings.ToLower(strings.TrimSpace(line[1:]))
+			if section = info[name]; section == nil {
+				section = make(map[string]string)
+				info[name] = section
+			}
+			continue
+		}
+		pos := strings.IndexByte(line, ':')
+		if pos == -1 {
+			continue
+		}
+		if section == nil {
+			section = make(map[string]string)
+			info[""] = section
+		}
+		section[line[:pos]] = line[pos+1:]
+	}
+	return info
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (info *Info) UnmarshalRESP(v resp.Value) error {
+	var text string
+	if err := v.Decode(&text); err != nil {
+		return err
+	}
+	*info = ParseInfo(text)
+	return nil
+}
+
+// Value returns the value of key in any section
+func (info Info) Value(key string) (string, bool) {
+	for _, section := range info {
+		if v, ok := section[key]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Int returns the integer value of key in any section
+func (info Info) Int(key string) (int64, bool) {
+	if v, ok := info.Value(key); ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// Version returns the redis_version field
+func (info Info) Version() string {
+	v, _ := info.Value("redis_version")
+	return v
+}
+
+// Role returns the role field ("master" or "slave")
+func (info Info) Role() string {
+	v, _ := info.Value("role")
+	return v
+}
+
+// UsedMemory returns the used_memory field in bytes
+func (info Info) UsedMemory() int64 {
+	n, _ := info.Int("used_memory")
+	return n
+}
+
+// ConnectedClients returns the connected_clients field
+func (info Info) ConnectedClients() int64 {
+	n, _ := info.Int("connected_clients")
+	return n
+}
+
+// MasterReplOffset returns the master_repl_offset field
+func (info Info) MasterReplOffset() int64 {
+	n, _ := info.Int("master_repl_offset")
+	return n
+}
+
+// SlaveReplOffset returns the slave_repl_offset field of a replica
+func (info Info) SlaveReplOffset() int64 {
+	n, _ := info.Int("slave_repl_offset")
+	return n
+}
+
+// KeyspaceInfo is the keyspace information of a database
+type KeyspaceInfo struct {
+	Keys    int64
+	Expires int64
+	AvgTTL  int64 // Average TTL in milliseconds
+}
+
+// Keyspace returns the keyspace information of each database by index
+func (info Info) Keyspace() map[int]KeyspaceInfo {
+	keyspace := make(map[int]KeyspaceInfo)
+	for key, value := range info["keyspace"] {
+		if !strings.HasPrefix(key, "db") {
+			continue
+		}
+		db, err := strconv.Atoi(key[2:])
+		if err != nil {
+			continue
+		}
+		ks := KeyspaceInfo{}
+		for k, v := range infoFields(value) {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			switch k {
+			case "keys":
+				ks.Keys = n
+			case "expires":
+				ks.Expires = n
+			case "avg_ttl":
+				ks.AvgTTL = n
+			}
+		}
+		keyspace[db] = ks
+	}
+	return keyspace
+}
+
+// ReplicaInfo is the information of a replica connected to a master
+type ReplicaInfo struct {
+	Addr   string
+	State  string
+	Offset int64
+	Lag    int64
+}
+
+// Replicas returns the replicas connected to a master in order
+func (info Info) Replicas() []ReplicaInfo {
+	var replicas []ReplicaInfo
+	replication := info["replication"]
+	for i := 0; ; i++ {
+		value, ok := replication["slave"+strconv.Itoa(i)]
+		if !ok {
+			return replicas
+		}
+		fields := infoFields(value)
+		r := ReplicaInfo{
+			Addr:  fields["ip"] + ":" + fields["port"],
+			State: fields["state"],
+		}
+		r.Offset, _ = strconv.ParseInt(fields["offset"], 10, 64)
+		r.Lag, _ = strconv.ParseInt(fields["lag"], 10, 64)
+		replicas = append(replicas, r)
+	}
+}
+
+// infoFields parses a comma separated list of key=value pairs
+func infoFields(value string) map[string]string {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(value, ",") {
+		if pos := strings.IndexByte(field, '='); pos != -1 {
+			fields[field[:pos]] = field[pos+1:]
+		}
+	}
+	return fields
+}
@@ -0,0 +1,104 @@
+package red_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+const infoText = "# Server\r\n" +
+	"redis_version:7.0.11\r\n" +
+	"redis_mode:standalone\r\n" +
+	"\r\n" +
+	"# Clients\r\n" +
+	"connected_clients:3\r\n" +
+	"\r\n" +
+	"# Memory\r\n" +
+	"used_memory:1048576\r\n" +
+	"used_memory_human:1.00M\r\n" +
+	"\r\n" +
+	"# Replication\r\n" +
+	"role:master\r\n" +
+	"connected_slaves:2\r\n" +
+	"slave0:ip=10.0.0.2,port=6379,state=online,offset=1234,lag=0\r\n" +
+	"slave1:ip=10.0.0.3,port=6380,state=wait_bgsave,offset=0,lag=1\r\n" +
+	"master_repl_offset:1234\r\n" +
+	"\r\n" +
+	"# Keyspace\r\n" +
+	"db0:keys=10,expires=2,avg_ttl=3000\r\n" +
+	"db3:keys=1,expires=0,avg_ttl=0\r\n"
+
+func TestAPI_Info(t *testing.T) {
+	for _, proto := range []int64{2, 3} {
+		var sections []string
+		srv := newFakeServer(t, func(args []string) resp.Any {
+			switch args[0] {
+			case "HELLO":
+				return helloReply(proto)
+			case "INFO":
+				sections = args[1:]
+				if proto == 3 {
+					return resp.VerbatimString{Format: "txt", String: infoText}
+				}
+				return bulk(infoText)
+			default:
+				return resp.SimpleString("OK")
+			}
+		})
+		conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+			Protocol: int(proto),
+		})
+		if err != nil {
+			t.Fatalf("Dial failed %s", err)
+		}
+		b := new(red.Batch)
+		reply := b.Info("server", "replication")
+		if err := conn.DoBatch(b); err != nil {
+			t.Fatalf("DoBatch failed %s", err)
+		}
+		conn.Close()
+		if !reflect.DeepEqual(sections, []string{"server", "replication"}) {
+			t.Errorf("Invalid sections %v", sections)
+		}
+		info, err := reply.Reply()
+		if err != nil {
+			t.Fatalf("INFO failed %s", err)
+		}
+		if v := info["memory"]["used_memory_human"]; v != "1.00M" {
+			t.Errorf("Invalid section value %q", v)
+		}
+		if v := info.Version(); v != "7.0.11" {
+			t.Errorf("Invalid version %q", v)
+		}
+		if v := info.Role(); v != "master" {
+			t.Errorf("Invalid role %q", v)
+		}
+		if n := info.UsedMemory(); n != 1048576 {
+			t.Errorf("Invalid used memory %d", n)
+		}
+		if n := info.ConnectedClients(); n != 3 {
+			t.Errorf("Invalid connected clients %d", n)
+		}
+		if n := info.MasterReplOffset(); n != 1234 {
+			t.Errorf("Invalid master offset %d", n)
+		}
+		keyspace := info.Keyspace()
+		expect := map[int]red.KeyspaceInfo{
+			0: {Keys: 10, Expires: 2, AvgTTL: 3000},
+			3: {Keys: 1},
+		}
+		if !reflect.DeepEqual(keyspace, expect) {
+			t.Errorf("Invalid keyspace %v", keyspace)
+		}
+		replicas := info.Replicas()
+		expectReplicas := []red.ReplicaInfo{
+			{Addr: "10.0.0.2:6379", State: "online", Offset: 1234},
+			{Addr: "10.0.0.3:6380", State: "wait_bgsave", Lag: 1},
+		}
+		if !reflect.DeepEqual(replicas, expectReplicas) {
+			t.Errorf("Invalid replicas %v", replicas)
+		}
+	}
+}
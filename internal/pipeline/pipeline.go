@@ -23,6 +23,8 @@ type Entry struct {
 	skip    bool
 	block   bool
 	timeout time.Duration
+	name    string
+	start   time.Time
 }
 
 func (e *Entry) Discard() bool {
@@ -44,6 +46,16 @@ func (e *Entry) Block() (time.Duration, bool) {
 	return e.timeout, e.block
 }
 
+// Name returns the command name set by Observe
+func (e *Entry) Name() string {
+	return e.name
+}
+
+// Start returns the time set by Observe
+func (e *Entry) Start() time.Time {
+	return e.start
+}
+
 func (s *State) Pop() (Entry, bool) {
 	if last := len(s.stack) - 1; 0 <= last && last < len(s.stack) {
 		var entry Entry
@@ -215,6 +227,15 @@ func (q *State) Block(timeout time.Duration) {
 		e.timeout = timeout
 	}
 }
+
+// Observe sets the command name and the time it was written on the last entry
+func (q *State) Observe(name string, start time.Time) {
+	if last := len(q.queue) - 1; 0 <= last && last < len(q.queue) {
+		e := &q.queue[last]
+		e.name, e.start = name, start
+	}
+}
+
 func (q *State) Len() int {
 	return len(q.queue)
 }
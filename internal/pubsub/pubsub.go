@@ -21,6 +21,7 @@ const (
 type IncomingMessage struct {
 	kind        MessageKind
 	payload     string
+	values      []string
 	numChannels int64
 	channel     string
 }
@@ -48,6 +49,12 @@ func (m *IncomingMessage) Pattern() (string, bool) {
 func (m *IncomingMessage) Payload() (string, bool) {
 	return m.payload, m.kind == KindMessage
 }
+
+// Values returns the payload of messages with array payloads (ie client tracking invalidations)
+func (m *IncomingMessage) Values() ([]string, bool) {
+	return m.values, m.kind == KindMessage && m.values != nil
+}
+
 func (m *IncomingMessage) NumChannels() (int64, bool) {
 	return m.numChannels, m.kind == KindSubscribe || m.kind == KindUnsubscribe
 }
@@ -76,6 +83,18 @@ func (m *IncomingMessage) UnmarshalRESP(value resp.Value) error {
 			return fmt.Errorf("Invalid incoming message %v", value.Any())
 		}
 		iter.Next()
+		switch payload := iter.Value(); {
+		case payload.NullArray():
+			// Client tracking invalidations for all keys carry a null payload
+			return nil
+		case payload.Type() == resp.TypeArray, payload.Type() == resp.TypeSet:
+			// Client tracking invalidations carry an array of keys
+			m.values = make([]string, 0, payload.Len())
+			if err := payload.Decode(&m.values); err != nil {
+				return fmt.Errorf("Invalid incoming message %v", value.Any())
+			}
+			return nil
+		}
 		if err := str.UnmarshalRESP(iter.Value()); err != nil {
 			return fmt.Errorf("Invalid incoming message %v", value.Any())
 		}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alxarch/red"
+)
+
+// String implements expvar.Var interface
+//
+// Use expvar.Publish to export metrics as JSON in /debug/vars.
+func (m *Metrics) String() string {
+	s := m.Snapshot()
+	type poolStats struct {
+		red.PoolStats
+		LastDialError string `json:",omitempty"`
+	}
+	pools := make(map[string]poolStats, len(s.Pools))
+	for name, stats := range s.Pools {
+		p := poolStats{PoolStats: stats}
+		if err := stats.LastDialError; err != nil {
+			p.LastDialError = err.Error()
+		}
+		pools[name] = p
+	}
+	data, err := json.Marshal(struct {
+		Snapshot
+		Pools map[string]poolStats
+	}{s, pools})
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// PrometheusContentType is the content type of the Prometheus text format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler returns an http.Handler that serves metrics in Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", PrometheusContentType)
+		_ = m.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes metrics in Prometheus text format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	s := m.Snapshot()
+	p := promWriter{w: bufio.NewWriter(w)}
+
+	pools := make([]string, 0, len(s.Pools))
+	for name := range s.Pools {
+		pools = append(pools, name)
+	}
+	sort.Strings(pools)
+	poolCounter := func(name, help string, value func(red.PoolStats) int64) {
+		p.header(name, help, "counter")
+		for _, pool := range pools {
+			p.sample(name, label("pool", pool), float64(value(s.Pools[pool])))
+		}
+	}
+	poolGauge := func(name, help string, value func(red.PoolStats) int) {
+		p.header(name, help, "gauge")
+		for _, pool := range pools {
+			p.sample(name, label("pool", pool), float64(value(s.Pools[pool])))
+		}
+	}
+	if len(pools) > 0 {
+		poolCounter("redis_pool_hits_total", "Connections taken from the pool without waiting", func(s red.PoolStats) int64 { return s.Hits })
+		poolCounter("redis_pool_misses_total", "Connections taken from the pool after waiting", func(s red.PoolStats) int64 { return s.Misses })
+		poolCounter("redis_pool_timeouts_total", "Waits for a connection that timed out", func(s red.PoolStats) int64 { return s.Timeouts })
+		poolCounter("redis_pool_dials_total", "Connections dialed", func(s red.PoolStats) int64 { return s.Dials })
+		poolCounter("redis_pool_dial_errors_total", "Failed dials", func(s red.PoolStats) int64 { return s.DialErrors })
+		poolGauge("redis_pool_idle_connections", "Idle connections", func(s red.PoolStats) int { return s.Idle })
+		poolGauge("redis_pool_active_connections", "Open connections", func(s red.PoolStats) int { return s.Active })
+		poolGauge("redis_pool_waiting", "Goroutines waiting for a connection", func(s red.PoolStats) int { return s.Waiting })
+		p.header("redis_pool_wait_seconds", "Time spent waiting for a connection", "histogram")
+		for _, pool := range pools {
+			stats := s.Pools[pool]
+			p.histogram("redis_pool_wait_seconds", label("pool", pool), red.PoolWaitBuckets[:], stats.WaitBuckets, stats.WaitTime)
+		}
+	}
+
+	commands := make([]string, 0, len(s.Commands))
+	for name := range s.Commands {
+		commands = append(commands, name)
+	}
+	sort.Strings(commands)
+	p.header("redis_command_duration_seconds", "Latency of commands from write to reply", "histogram")
+	for _, name := range commands {
+		stats := s.Commands[name]
+		p.histogram("redis_command_duration_seconds", label("command", name), s.Buckets, stats.Buckets, stats.Latency)
+	}
+
+	prefixes := make([]string, 0, len(s.Errors))
+	for prefix := range s.Errors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	p.header("redis_errors_total", "Error replies by prefix", "counter")
+	for _, prefix := range prefixes {
+		p.sample("redis_errors_total", label("prefix", prefix), float64(s.Errors[prefix]))
+	}
+
+	p.header("redis_read_bytes_total", "Bytes read from connections", "counter")
+	p.sample("redis_read_bytes_total", "", float64(s.BytesRead))
+	p.header("redis_written_bytes_total", "Bytes written to connections", "counter")
+	p.sample("redis_written_bytes_total", "", float64(s.BytesWritten))
+	return p.flush()
+}
+
+// promWriter writes Prometheus text format keeping the first error
+type promWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *promWriter) flush() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+func (p *promWriter) header(name, help, typ string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (p *promWriter) sample(name, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	p.printf("%s%s %s\n", name, labels, formatFloat(value))
+}
+
+// histogram writes cumulative buckets from non-cumulative counts
+func (p *promWriter) histogram(name, labels string, bounds []time.Duration, counts []int64, sum time.Duration) {
+	var total int64
+	for i, bound := range bounds {
+		if i < len(counts) {
+			total += counts[i]
+		}
+		p.sample(name+"_bucket", labels+","+label("le", formatFloat(bound.Seconds())), float64(total))
+	}
+	if i := len(bounds); i < len(counts) {
+		total += counts[i]
+	}
+	p.sample(name+"_bucket", labels+","+label("le", "+Inf"), float64(total))
+	p.sample(name+"_sum", labels, sum.Seconds())
+	p.sample(name+"_count", labels, float64(total))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,238 @@
+// Package metrics collects metrics of redis pools and connections
+//
+// Metrics implements red.Observer so it can be set as ConnOptions.Observer to collect
+// per-command latencies, error replies and network traffic.
+// Pools registered with RegisterPool export their stats.
+// Metrics are exposed with expvar (Metrics implements expvar.Var) and
+// in Prometheus text format (see Metrics.Handler).
+package metrics
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+// DefaultBuckets are the default upper bounds of command latency histograms
+var DefaultBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// NetworkError is the prefix used to count errors that are not error replies
+const NetworkError = "NETWORK"
+
+// Metrics collects metrics of redis pools and connections
+//
+// It is safe for concurrent use.
+type Metrics struct {
+	buckets []time.Duration
+
+	mu       sync.RWMutex
+	pools    map[string]*red.Pool
+	commands map[string]*histogram
+	errors   map[string]*int64
+
+	bytesRead, bytesWritten int64
+}
+
+var _ red.Observer = (*Metrics)(nil)
+
+// New creates a new Metrics
+//
+// Command latency histograms use buckets as upper bounds (defaults to DefaultBuckets).
+func New(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i] < buckets[j]
+	})
+	return &Metrics{
+		buckets:  buckets,
+		pools:    make(map[string]*red.Pool),
+		commands: make(map[string]*histogram),
+		errors:   make(map[string]*int64),
+	}
+}
+
+// RegisterPool exports the stats of a pool with a name
+//
+// A pool registered with the same name is replaced.
+func (m *Metrics) RegisterPool(name string, pool *red.Pool) {
+	m.mu.Lock()
+	m.pools[name] = pool
+	m.mu.Unlock()
+}
+
+// UnregisterPool stops exporting the stats of a pool
+func (m *Metrics) UnregisterPool(name string) {
+	m.mu.Lock()
+	delete(m.pools, name)
+	m.mu.Unlock()
+}
+
+// ObserveCommand implements red.Observer interface
+func (m *Metrics) ObserveCommand(name string, latency time.Duration, err error) {
+	name = strings.ToUpper(name)
+	m.command(name).observe(latency)
+	if err != nil {
+		atomic.AddInt64(m.errorCounter(ErrorPrefix(err)), 1)
+	}
+}
+
+// ObserveBytes implements red.Observer interface
+func (m *Metrics) ObserveBytes(read, written int) {
+	if read > 0 {
+		atomic.AddInt64(&m.bytesRead, int64(read))
+	}
+	if written > 0 {
+		atomic.AddInt64(&m.bytesWritten, int64(written))
+	}
+}
+
+// ErrorPrefix returns the prefix of an error reply (ie "WRONGTYPE", "MOVED", "NOSCRIPT")
+//
+// Errors that are not error replies have the NetworkError prefix.
+func ErrorPrefix(err error) string {
+	var e resp.Error
+	if !errors.As(err, &e) {
+		return NetworkError
+	}
+	prefix := string(e)
+	if i := strings.IndexByte(prefix, ' '); i != -1 {
+		prefix = prefix[:i]
+	}
+	if prefix == "" {
+		return "ERR"
+	}
+	return prefix
+}
+
+func (m *Metrics) command(name string) *histogram {
+	m.mu.RLock()
+	h := m.commands[name]
+	m.mu.RUnlock()
+	if h != nil {
+		return h
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h = m.commands[name]; h == nil {
+		h = newHistogram(m.buckets)
+		m.commands[name] = h
+	}
+	return h
+}
+
+func (m *Metrics) errorCounter(prefix string) *int64 {
+	m.mu.RLock()
+	n := m.errors[prefix]
+	m.mu.RUnlock()
+	if n != nil {
+		return n
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n = m.errors[prefix]; n == nil {
+		n = new(int64)
+		m.errors[prefix] = n
+	}
+	return n
+}
+
+// histogram counts durations in buckets
+type histogram struct {
+	bounds []time.Duration
+	counts []int64 // The last bucket counts durations above all bounds
+	count  int64
+	sum    int64
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool {
+		return d <= h.bounds[i]
+	})
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddInt64(&h.count, 1)
+}
+
+// CommandStats are the stats of a command
+type CommandStats struct {
+	Count   int64         // Total replies read
+	Latency time.Duration // Total latency of all replies
+	Buckets []int64       // Reply counts by latency, the last bucket counts replies above all bounds
+}
+
+// Snapshot is a snapshot of all metrics
+type Snapshot struct {
+	Pools        map[string]red.PoolStats
+	Commands     map[string]CommandStats
+	Errors       map[string]int64 // Error counts by prefix
+	Buckets      []time.Duration  // Upper bounds of command latency buckets
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// Snapshot returns a snapshot of all metrics
+func (m *Metrics) Snapshot() Snapshot {
+	s := Snapshot{
+		Pools:        make(map[string]red.PoolStats),
+		Commands:     make(map[string]CommandStats),
+		Errors:       make(map[string]int64),
+		Buckets:      m.buckets,
+		BytesRead:    atomic.LoadInt64(&m.bytesRead),
+		BytesWritten: atomic.LoadInt64(&m.bytesWritten),
+	}
+	m.mu.RLock()
+	pools := make(map[string]*red.Pool, len(m.pools))
+	for name, pool := range m.pools {
+		pools[name] = pool
+	}
+	for name, h := range m.commands {
+		stats := CommandStats{
+			Count:   atomic.LoadInt64(&h.count),
+			Latency: time.Duration(atomic.LoadInt64(&h.sum)),
+			Buckets: make([]int64, len(h.counts)),
+		}
+		for i := range h.counts {
+			stats.Buckets[i] = atomic.LoadInt64(&h.counts[i])
+		}
+		s.Commands[name] = stats
+	}
+	for prefix, n := range m.errors {
+		s.Errors[prefix] = atomic.LoadInt64(n)
+	}
+	m.mu.RUnlock()
+	// Pool stats lock the pool so they are read outside our lock
+	for name, pool := range pools {
+		s.Pools[name] = pool.Stats()
+	}
+	return s
+}
@@ -0,0 +1,176 @@
+package metrics_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/metrics"
+	"github.com/alxarch/red/resp"
+)
+
+func TestErrorPrefix(t *testing.T) {
+	for err, prefix := range map[error]string{
+		resp.Error("WRONGTYPE Operation against a key"): "WRONGTYPE",
+		resp.Error("MOVED 3999 127.0.0.1:6381"):         "MOVED",
+		resp.Error("NOSCRIPT"):                          "NOSCRIPT",
+		errors.New("connection reset"):                  metrics.NetworkError,
+	} {
+		if p := metrics.ErrorPrefix(err); p != prefix {
+			t.Errorf("Invalid prefix of %q: %q != %q", err, p, prefix)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New(time.Millisecond, 10*time.Millisecond)
+	m.ObserveCommand("get", 500*time.Microsecond, nil)
+	m.ObserveCommand("GET", 5*time.Millisecond, nil)
+	m.ObserveCommand("GET", time.Second, nil)
+	m.ObserveCommand("INCR", time.Millisecond, resp.Error("WRONGTYPE Operation against a key"))
+	m.ObserveBytes(10, 0)
+	m.ObserveBytes(0, 20)
+	pool := red.Pool{}
+	m.RegisterPool("main", &pool)
+
+	s := m.Snapshot()
+	get := s.Commands["GET"]
+	if get.Count != 3 || get.Latency != 1005500*time.Microsecond || len(get.Buckets) != 3 {
+		t.Errorf("Invalid GET stats %v", get)
+	}
+	for i, n := range get.Buckets {
+		if n != 1 {
+			t.Errorf("Invalid GET bucket %d: %d", i, n)
+		}
+	}
+	if s.Errors["WRONGTYPE"] != 1 || s.BytesRead != 10 || s.BytesWritten != 20 {
+		t.Errorf("Invalid snapshot %v", s)
+	}
+	if _, ok := s.Pools["main"]; !ok {
+		t.Errorf("Missing pool stats")
+	}
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal([]byte(m.String()), &vars); err != nil {
+		t.Fatalf("Invalid expvar JSON %s", err)
+	}
+	for _, key := range []string{"Pools", "Commands", "Errors", "BytesRead", "BytesWritten"} {
+		if _, ok := vars[key]; !ok {
+			t.Errorf("Missing expvar key %q", key)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.PrometheusContentType {
+		t.Errorf("Invalid content type %q", ct)
+	}
+	text := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE redis_command_duration_seconds histogram",
+		`redis_command_duration_seconds_bucket{command="GET",le="0.001"} 1`,
+		`redis_command_duration_seconds_bucket{command="GET",le="0.01"} 2`,
+		`redis_command_duration_seconds_bucket{command="GET",le="+Inf"} 3`,
+		`redis_command_duration_seconds_sum{command="GET"} 1.0055`,
+		`redis_command_duration_seconds_count{command="GET"} 3`,
+		`redis_errors_total{prefix="WRONGTYPE"} 1`,
+		`redis_pool_hits_total{pool="main"} 0`,
+		`redis_pool_wait_seconds_count{pool="main"} 0`,
+		"redis_read_bytes_total 10",
+		"redis_written_bytes_total 20",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Missing line %q", line)
+		}
+	}
+	if t.Failed() {
+		t.Log(text)
+	}
+}
+
+func TestMetrics_SharedConnections(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed %s", err)
+	}
+	defer ln.Close()
+	go serve(ln)
+
+	m := metrics.New()
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(ln.Addr().String(), &red.ConnOptions{Observer: m})
+		},
+		SharedConnections: 1,
+	}
+	defer pool.Close()
+
+	var reply string
+	if err := pool.DoCommand(&reply, "GET", red.Key("foo")); err != nil || reply != "bar" {
+		t.Errorf("Invalid GET reply %q %v", reply, err)
+	}
+	var n int64
+	if err := pool.DoCommand(&n, "INCR", red.Key("foo")); err == nil {
+		t.Errorf("INCR did not fail")
+	}
+
+	s := m.Snapshot()
+	if get := s.Commands["GET"]; get.Count != 1 {
+		t.Errorf("Invalid GET stats %v", get)
+	}
+	if incr := s.Commands["INCR"]; incr.Count != 1 {
+		t.Errorf("Invalid INCR stats %v", incr)
+	}
+	if s.Errors["WRONGTYPE"] != 1 || s.BytesRead == 0 || s.BytesWritten == 0 {
+		t.Errorf("Invalid snapshot %v", s)
+	}
+}
+
+// serve replies to GET with "bar" and to INCR with a WRONGTYPE error
+func serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			skip := false
+			for {
+				cmd, err := resp.ReadAny(r)
+				if err != nil {
+					return
+				}
+				var args []string
+				if err := cmd.Decode(&args); err != nil || len(args) == 0 {
+					return
+				}
+				var reply resp.Any = resp.SimpleString("OK")
+				switch strings.ToUpper(args[0]) {
+				case "CLIENT":
+					// CLIENT REPLY SKIP
+					skip = true
+					continue
+				case "GET":
+					reply = &resp.BulkString{String: "bar", Valid: true}
+				case "INCR":
+					reply = resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+				}
+				if skip {
+					skip = false
+					continue
+				}
+				if _, err := conn.Write(reply.AppendRESP(nil)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,85 @@
+package red
+
+import (
+	"net"
+	"time"
+
+	"github.com/alxarch/red/internal/pipeline"
+	"github.com/alxarch/red/resp"
+)
+
+// Observer observes the commands and network traffic of connections
+//
+// An Observer is usually shared by all connections of a pool so it must be safe for concurrent use.
+type Observer interface {
+	// ObserveCommand is called when the reply of a command is read.
+	// The latency is measured from the time the command was written to the pipeline.
+	// If the reply is an error, err is a resp.Error.
+	// If the reply could not be read, err is the network error.
+	ObserveCommand(name string, latency time.Duration, err error)
+	// ObserveBytes is called after each read from or write to the network connection
+	ObserveBytes(read, written int)
+}
+
+// scanObserved scans a reply reporting it to obs
+func (conn *Conn) scanObserved(obs Observer, dest interface{}, entry pipeline.Entry) error {
+	err := conn.decodeObserved(obs, dest, entry)
+	if err != nil && !isDecodeError(err) {
+		conn.closeConn()
+	}
+	return err
+}
+
+// decodeObserved decodes a reply reporting it to obs
+//
+// Unlike scanObserved it does not close the connection on network errors.
+func (conn *Conn) decodeObserved(obs Observer, dest interface{}, entry pipeline.Entry) error {
+	reply := observedReply{dest: dest}
+	err := conn.r.Decode(&reply)
+	switch {
+	case err == nil:
+		obs.ObserveCommand(entry.Name(), time.Since(entry.Start()), reply.err)
+		return nil
+	case isDecodeError(err):
+		obs.ObserveCommand(entry.Name(), time.Since(entry.Start()), reply.err)
+		if e := err.(*resp.DecodeError); e.Dest == &reply {
+			e.Dest = dest
+		}
+		return err
+	default:
+		obs.ObserveCommand(entry.Name(), time.Since(entry.Start()), err)
+		return err
+	}
+}
+
+// observedReply decodes a reply into dest keeping error replies
+type observedReply struct {
+	dest interface{}
+	err  error
+}
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (r *observedReply) UnmarshalRESP(v resp.Value) error {
+	var e resp.Error
+	if e.UnmarshalRESP(v) == nil {
+		r.err = e
+	}
+	if r.dest == nil {
+		return nil
+	}
+	return v.Decode(r.dest)
+}
+
+// observedReader reports bytes read from a network connection
+type observedReader struct {
+	net.Conn
+	obs Observer
+}
+
+func (r observedReader) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if n > 0 {
+		r.obs.ObserveBytes(n, 0)
+	}
+	return n, err
+}
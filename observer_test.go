@@ -0,0 +1,88 @@
+package red_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+type testObserver struct {
+	mu       sync.Mutex
+	commands []string
+	errors   []error
+
+	read, written int64
+}
+
+func (o *testObserver) ObserveCommand(name string, latency time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.commands = append(o.commands, name)
+	o.errors = append(o.errors, err)
+}
+
+func (o *testObserver) ObserveBytes(read, written int) {
+	atomic.AddInt64(&o.read, int64(read))
+	atomic.AddInt64(&o.written, int64(written))
+}
+
+func TestConn_Observer(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			return bulk("bar")
+		case "INCR":
+			return resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+	obs := testObserver{}
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		Observer: &obs,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+
+	var value string
+	if err := conn.DoCommand(&value, "GET", red.Key("foo")); err != nil || value != "bar" {
+		t.Errorf("Invalid GET reply %q %v", value, err)
+	}
+	var n int64
+	if err := conn.DoCommand(&n, "INCR", red.Key("foo")); err == nil {
+		t.Errorf("INCR should fail")
+	}
+	b := new(red.Batch)
+	b.Set("foo", "bar", 0)
+	b.Get("foo")
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	if expect := []string{"GET", "INCR", "SET", "GET"}; !reflect.DeepEqual(obs.commands, expect) {
+		t.Errorf("Invalid observed commands %v", obs.commands)
+	}
+	var e resp.Error
+	if !errors.As(obs.errors[1], &e) || !strings.HasPrefix(string(e), "WRONGTYPE") {
+		t.Errorf("Invalid observed error %v", obs.errors[1])
+	}
+	for i, err := range obs.errors {
+		if i != 1 && err != nil {
+			t.Errorf("Unexpected observed error %d %v", i, err)
+		}
+	}
+	if atomic.LoadInt64(&obs.read) == 0 || atomic.LoadInt64(&obs.written) == 0 {
+		t.Errorf("Bytes were not observed %d %d", obs.read, obs.written)
+	}
+}
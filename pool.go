@@ -1,10 +1,16 @@
 package red
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"net"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/alxarch/red/resp"
 )
 
 // Pool is a pool of redis connections
@@ -15,7 +21,28 @@ type Pool struct {
 	MaxConnections int                   // Maximum number of connection to open on demand (defaults to 1)
 	MinConnections int                   // Minimum number of connections to keep open once dialed (defaults to 1)
 	MaxIdleTime    time.Duration         // Max time a connection will be left idling (0 => no limit)
-	ClockInterval  time.Duration         // Minimum unit of time for timeouts and intervals (defaults to 50ms)
+	// Interval of the background clock that drives health checks (defaults to 100ms or HealthCheckInterval if less)
+	ClockInterval time.Duration
+	// If > 0 DoCommand pipelines commands from concurrent goroutines on this many shared connections.
+	// Shared connections are dialed in addition to MaxConnections.
+	// Blocking commands, transactions and subscriptions always use a connection from the pool.
+	SharedConnections int
+	// Max time since a connection was dialed before it is closed (0 => no limit)
+	MaxConnLifetime time.Duration
+	// If > 0 connections idle for longer are checked with PING before Get returns them
+	TestOnBorrow time.Duration
+	// If > 0 connections idle for longer are checked with PING in the background.
+	// A few connections are checked on each clock tick, the rest remain available to Get.
+	HealthCheckInterval time.Duration
+	// If set, MinConnections are dialed in the background on first use (see Pool.Warm)
+	WarmUp bool
+	// If > 0 limits the number of connections being dialed at the same time
+	MaxConcurrentDials int
+	// If > 0 dials are skipped for this long after a failed dial, doubling with each consecutive failure.
+	// While dials are skipped, Get fails fast with the last dial error unless a connection is available.
+	DialBackoff time.Duration
+	// Max delay between dials after consecutive failures (defaults to 30s)
+	MaxDialBackoff time.Duration
 
 	once      sync.Once
 	closeChan chan struct{}
@@ -24,17 +51,35 @@ type Pool struct {
 	mu     sync.Mutex
 	open   int
 	closed bool
-	wall   time.Time
+	epoch  uint64 // Incremented on Drain
 	idle   []*Conn
 	queue  []*Conn
+	// Goroutines waiting for a connection in FIFO order
+	waiters []*poolWaiter
+	// Connections being dialed
+	dialing int
+	// Dial failure state
+	dialFailures int
+	dialErr      error
+	dialRetryAt  time.Time
+	// Idle connections out of the pool for a health check
+	checking int
 
 	// queueLock sync.Mutex
 
 	// activeLock  sync.RWMutex
-	connections map[*Conn]struct{}
+	// Network connections are kept so that Shutdown can close active connections
+	connections map[*Conn]net.Conn
+
+	shared     []*sharedConn
+	sharedNext uint32
+	sharedDial sync.Mutex
 
 	stats struct {
-		dials, hits, misses, timeouts int64
+		dials, hits, misses, timeouts, dialErrors int64
+
+		waits, waitTime int64
+		waitBuckets     [len(PoolWaitBuckets) + 1]int64
 	}
 	// clients sync.Pool // local pool of clients
 }
@@ -43,6 +88,32 @@ type Pool struct {
 type PoolStats struct {
 	Hits, Misses, Timeouts, Dials int64
 	Idle, Active                  int
+	DialErrors                    int64     // Total failed dials
+	DialFailures                  int       // Consecutive failed dials since the last successful dial
+	LastDialError                 error     // Error of the last failed dial if DialFailures > 0
+	DialRetryAt                   time.Time // Get fails fast until this time if DialBackoff is set
+
+	Waiting  int           // Goroutines waiting for a connection
+	Waits    int64         // Total times Get waited for a connection
+	WaitTime time.Duration // Total time Get waited for a connection
+	// Wait counts by duration.
+	// WaitBuckets[i] counts waits up to PoolWaitBuckets[i] and the last bucket counts longer waits.
+	WaitBuckets []int64
+}
+
+// PoolWaitBuckets are the upper bounds of the wait time histogram in PoolStats
+var PoolWaitBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
 }
 
 // Stats returns current pool statistics
@@ -52,11 +123,24 @@ func (p *Pool) Stats() PoolStats {
 		Misses:   atomic.LoadInt64(&p.stats.misses),
 		Timeouts: atomic.LoadInt64(&p.stats.timeouts),
 		Dials:    atomic.LoadInt64(&p.stats.dials),
+
+		DialErrors: atomic.LoadInt64(&p.stats.dialErrors),
+
+		Waits:       atomic.LoadInt64(&p.stats.waits),
+		WaitTime:    time.Duration(atomic.LoadInt64(&p.stats.waitTime)),
+		WaitBuckets: make([]int64, len(p.stats.waitBuckets)),
+	}
+	for i := range p.stats.waitBuckets {
+		stats.WaitBuckets[i] = atomic.LoadInt64(&p.stats.waitBuckets[i])
 	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	stats.Idle = len(p.idle) + len(p.queue)
+	stats.DialFailures = p.dialFailures
+	stats.LastDialError = p.dialErr
+	stats.DialRetryAt = p.dialRetryAt
+	stats.Idle = len(p.idle) + len(p.queue) + p.checking
 	stats.Active = len(p.connections)
+	stats.Waiting = len(p.waiters)
 	return stats
 }
 
@@ -92,37 +176,125 @@ func (p *Pool) Get() (*Conn, error) {
 	return p.GetDeadline(time.Time{})
 }
 
-// Close closes a pool and all it's connections
-// TODO: [pool] Implement grace period for when closing a pool
+// GetContext waits until ctx is done to get a connection from the pool
+//
+// To release the connection back to the pool use `Pool.Put(*Conn)`
+func (p *Pool) GetContext(ctx context.Context) (*Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.get(ctx, time.Time{})
+}
+
+// Close closes a pool and all it's idle connections
+//
+// Active connections are discarded once they are released.
+// Use Shutdown to wait for active connections to be released.
 func (p *Pool) Close() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	return p.closeLocked()
+}
+
+func (p *Pool) closeLocked() error {
 	if p.closed {
 		return errPoolClosed
 	}
 	p.closed = true
 	var idle []*Conn
 	idle, p.idle = p.idle, nil
+	idle = append(idle, p.queue...)
+	p.queue = nil
 	for _, conn := range idle {
 		conn.pool = nil
+		if _, ok := p.connections[conn]; ok {
+			delete(p.connections, conn)
+			p.open--
+		}
 		_ = conn.Close()
 	}
-	idle, p.queue = p.queue, nil
-	for _, conn := range idle {
-		conn.pool = nil
-		_ = conn.Close()
-	}
+	p.closeSharedLocked()
 	if ch := p.closeChan; ch != nil {
 		p.closeChan = nil
 		close(ch)
 	}
 	// Notify all goroutines waiting on pool.get()
-	p.cond.Broadcast()
+	p.wakeAllLocked(poolWake{err: errPoolClosed})
 	return nil
 }
 
+// Shutdown closes a pool gracefully
+//
+// It stops handing out connections, closes idle connections and waits until all active connections
+// are released. Released connections read their pending replies (see Conn.Reset) before they are closed.
+// If ctx is done before all connections are released, the remaining connections are closed forcibly.
+// It returns the number of connections that were closed forcibly and ctx.Err() if there were any.
+func (p *Pool) Shutdown(ctx context.Context) (int, error) {
+	p.once.Do(p.init)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.closeLocked(); err != nil {
+		return 0, err
+	}
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				// Wake up Shutdown so it can check ctx
+				p.mu.Lock()
+				p.cond.Broadcast()
+				p.mu.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+	for len(p.connections) > 0 || p.dialing > 0 {
+		if err := ctx.Err(); err != nil {
+			// Owners of the connections discard them once they fail
+			for _, nc := range p.connections {
+				_ = nc.Close()
+			}
+			return len(p.connections), err
+		}
+		p.cond.Wait()
+	}
+	return 0, nil
+}
+
+// Drain closes all idle connections and discards active connections once they are released
+//
+// New connections are dialed on demand.
+func (p *Pool) Drain() {
+	p.once.Do(p.init)
+	p.mu.Lock()
+	p.epoch++
+	idle := make([]*Conn, 0, len(p.idle)+len(p.queue))
+	idle = append(idle, p.idle...)
+	idle = append(idle, p.queue...)
+	for i := range p.idle {
+		p.idle[i] = nil
+	}
+	for i := range p.queue {
+		p.queue[i] = nil
+	}
+	p.idle, p.queue = p.idle[:0], p.queue[:0]
+	p.closeSharedLocked()
+	p.mu.Unlock()
+	// Waiters can dial new connections
+	for _, c := range idle {
+		p.discard(c)
+	}
+}
+
 // DoCommand executes cmd on a new connection
+//
+// If SharedConnections is set, cmd is pipelined on a shared connection when possible.
 func (p *Pool) DoCommand(dest interface{}, cmd string, args ...Arg) error {
+	if p.SharedConnections > 0 && sharedCommand(cmd, args) {
+		return p.doShared(dest, cmd, args)
+	}
 	conn, err := p.Get()
 	if err != nil {
 		return err
@@ -142,6 +314,26 @@ func (p *Pool) DoBatch(b *Batch) error {
 
 }
 
+// DoCommandContext executes cmd on a new connection using ctx for deadlines and cancellation
+func (p *Pool) DoCommandContext(ctx context.Context, dest interface{}, cmd string, args ...Arg) error {
+	conn, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.DoCommandContext(ctx, dest, cmd, args...)
+}
+
+// DoBatchContext executes a batch on a pool connection using ctx for deadlines and cancellation
+func (p *Pool) DoBatchContext(ctx context.Context, b *Batch) error {
+	conn, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.DoBatchContext(ctx, b)
+}
+
 var (
 	errPoolClosed       = errors.New("Pool closed")
 	errDeadlineExceeded = errors.New("Deadline exceeded")
@@ -154,6 +346,13 @@ func (p *Pool) discard(c *Conn) {
 	defer p.mu.Unlock()
 	delete(p.connections, c)
 	p.open--
+	if p.closed {
+		// Shutdown waits for all connections to be discarded
+		p.cond.Broadcast()
+		return
+	}
+	// The first waiter can dial a replacement
+	p.wakeLocked(poolWake{})
 }
 
 func (p *Pool) put(c *Conn) error {
@@ -171,6 +370,12 @@ func (p *Pool) put(c *Conn) error {
 		p.discard(c)
 		return err
 	}
+	now := time.Now()
+	if p.expired(c, now) {
+		p.discard(c)
+		return nil
+	}
+	c.lastUsedAt = now
 	p.once.Do(p.init)
 	max := p.minConnections()
 	p.mu.Lock()
@@ -179,10 +384,15 @@ func (p *Pool) put(c *Conn) error {
 		p.discard(c)
 		return errPoolClosed
 	}
-	if len(p.idle) < max {
-		p.queue = append(p.queue, c)
+	if c.poolEpoch != p.epoch {
+		// Connection was dialed before the pool was drained
+		p.mu.Unlock()
+		p.discard(c)
+		return nil
+	}
+	if len(p.waiters) > 0 || len(p.idle) < max {
+		p.releaseLocked(c)
 		p.mu.Unlock()
-		p.cond.Signal()
 		return nil
 	}
 	p.mu.Unlock()
@@ -226,19 +436,11 @@ func (p *Pool) init() {
 	}
 	p.closeChan = make(chan struct{})
 	p.cond.L = &p.mu
-	p.connections = make(map[*Conn]struct{})
-	go p.run()
+	p.connections = make(map[*Conn]net.Conn)
+	go p.run(p.closeChan)
 }
 
-const defaultClockInterval = 100 * time.Millisecond
-
-func (p *Pool) run() {
-	clockInterval := defaultClockInterval
-	if p.ClockInterval > 0 {
-		clockInterval = p.ClockInterval
-	}
-	clock := time.NewTicker(clockInterval)
-	defer clock.Stop()
+func (p *Pool) run(done <-chan struct{}) {
 	var cleanInterval <-chan time.Time
 	interval := p.MaxIdleTime
 	if interval > 0 {
@@ -246,21 +448,40 @@ func (p *Pool) run() {
 		defer tick.Stop()
 		cleanInterval = tick.C
 	}
+	if p.WarmUp {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			_ = p.Warm(ctx)
+		}()
+	}
+	var clock <-chan time.Time
+	if interval := p.HealthCheckInterval; interval > 0 {
+		clockInterval := defaultClockInterval
+		if p.ClockInterval > 0 {
+			clockInterval = p.ClockInterval
+		} else if interval < clockInterval {
+			clockInterval = interval
+		}
+		tick := time.NewTicker(clockInterval)
+		defer tick.Stop()
+		clock = tick.C
+	}
 	for {
 		select {
-		case t := <-clock.C:
-			p.mu.Lock()
-			p.wall = t
-			p.mu.Unlock()
-			// pool.cond.Broadcast()
-		case <-p.closeChan:
+		case <-done:
 			return
 		case t := <-cleanInterval:
 			p.cleanup(t)
+		case t := <-clock:
+			// PINGs do not block the clock
+			go p.healthCheck(t)
 		}
 	}
 }
 
+const defaultClockInterval = 100 * time.Millisecond
+
 func (p *Pool) cleanup(now time.Time) (int, error) {
 	maxAge := p.MaxIdleTime
 	if maxAge <= 0 {
@@ -278,7 +499,6 @@ func (p *Pool) cleanup(now time.Time) (int, error) {
 		}
 	}()
 	idle := make([]*Conn, 0, size)
-	defer p.cond.Signal()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.closed {
@@ -295,28 +515,294 @@ func (p *Pool) cleanup(now time.Time) (int, error) {
 	return len(del), nil
 }
 
-// TODO: Add option to limit concurrent dials
+// expired checks if a connection exceeded MaxConnLifetime
+func (p *Pool) expired(c *Conn, now time.Time) bool {
+	return p.MaxConnLifetime > 0 && now.Sub(c.createdAt) > p.MaxConnLifetime
+}
+
+// checkConn checks if an idle connection can be used
+//
+// Connections are checked with PING if ping is set or if they were idle longer than TestOnBorrow.
+// PINGs fail if there is no reply within HealthCheckInterval or 1s.
+func (p *Pool) checkConn(c *Conn, now time.Time, ping bool) bool {
+	if c.Err() != nil || p.expired(c, now) {
+		return false
+	}
+	if ping || (p.TestOnBorrow > 0 && now.Sub(c.lastUsedAt) > p.TestOnBorrow) {
+		ctx, cancel := context.WithTimeout(context.Background(), p.pingTimeout())
+		defer cancel()
+		var pong resp.SimpleString
+		if err := c.DoCommandContext(ctx, &pong, "PING"); err != nil {
+			return false
+		}
+		c.lastUsedAt = now
+	}
+	return true
+}
+
+const (
+	defaultPingTimeout = time.Second
+	// Max idle connections checked on each clock tick
+	healthCheckBatch = 4
+)
+
+func (p *Pool) pingTimeout() time.Duration {
+	if d := p.HealthCheckInterval; 0 < d && d < defaultPingTimeout {
+		return d
+	}
+	return defaultPingTimeout
+}
+
+// healthCheck checks a few connections idle longer than HealthCheckInterval with PING
+//
+// Connections that fail are discarded and the rest are released back to the pool.
+// It skips the check if a previous check is still running.
+func (p *Pool) healthCheck(now time.Time) {
+	minT := now.Add(-p.HealthCheckInterval)
+	p.mu.Lock()
+	if p.closed || p.checking > 0 {
+		p.mu.Unlock()
+		return
+	}
+	epoch := p.epoch
+	check := make([]*Conn, 0, healthCheckBatch)
+	p.idle, check = takeStale(p.idle, check, minT)
+	p.queue, check = takeStale(p.queue, check, minT)
+	p.checking = len(check)
+	p.mu.Unlock()
+	if len(check) == 0 {
+		return
+	}
+
+	healthy := check[:0]
+	for _, c := range check {
+		if p.checkConn(c, now, true) {
+			healthy = append(healthy, c)
+		} else {
+			p.discard(c)
+		}
+	}
+	p.mu.Lock()
+	p.checking = 0
+	if p.closed || p.epoch != epoch {
+		// Pool was closed or drained while checking
+		p.mu.Unlock()
+		for _, c := range healthy {
+			p.discard(c)
+		}
+		return
+	}
+	for _, c := range healthy {
+		p.releaseLocked(c)
+	}
+	p.mu.Unlock()
+}
+
+// takeStale moves connections last used before minT from conns to stale up to healthCheckBatch
+func takeStale(conns, stale []*Conn, minT time.Time) ([]*Conn, []*Conn) {
+	keep := conns[:0]
+	for _, c := range conns {
+		if len(stale) < healthCheckBatch && c.lastUsedAt.Before(minT) {
+			stale = append(stale, c)
+		} else {
+			keep = append(keep, c)
+		}
+	}
+	for i := len(keep); i < len(conns); i++ {
+		conns[i] = nil
+	}
+	return keep, stale
+}
+
+// reserveDialLocked reserves a dial slot if a new connection can be dialed
+//
+// It fails fast with the last dial error while dials are backing off.
+func (p *Pool) reserveDialLocked(max int) (bool, error) {
+	if p.open >= max {
+		return false, nil
+	}
+	if p.dialFailures > 0 && time.Now().Before(p.dialRetryAt) {
+		return false, fmt.Errorf("Dial backoff after %d failures: %w", p.dialFailures, p.dialErr)
+	}
+	if p.MaxConcurrentDials > 0 && p.dialing >= p.MaxConcurrentDials {
+		return false, nil
+	}
+	p.open++
+	p.dialing++
+	return true, nil
+}
+
 func (p *Pool) dial() (*Conn, error) {
+	for {
+		conn, err := p.dialEpoch()
+		if err != errConnStale {
+			return conn, err
+		}
+	}
+}
+
+// dialDeadline dials a connection waiting until deadline or until ctx is done
+//
+// If the wait is over before the dial completes the connection is released to the pool once dialed.
+func (p *Pool) dialDeadline(ctx context.Context, deadline time.Time) (*Conn, error) {
+	if deadline.IsZero() && ctx.Done() == nil {
+		return p.dial()
+	}
+	type dialResult struct {
+		conn *Conn
+		err  error
+	}
+	done := make(chan dialResult)
+	abandon := make(chan struct{})
+	go func() {
+		conn, err := p.dial()
+		select {
+		case done <- dialResult{conn, err}:
+		case <-abandon:
+			if conn != nil {
+				_ = p.put(conn)
+			}
+		}
+	}()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-timeout:
+		err = errDeadlineExceeded
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	close(abandon)
+	atomic.AddInt64(&p.stats.timeouts, 1)
+	return nil, err
+}
+
+// errConnStale is returned by dialEpoch if the pool was drained while dialing
+var errConnStale = errors.New("Pool drained while dialing")
+
+// dialEpoch dials a connection for the current epoch
+//
+// Connections dialed while the pool is drained are closed as they might connect to an old master.
+func (p *Pool) dialEpoch() (*Conn, error) {
+	p.mu.Lock()
+	epoch := p.epoch
+	p.mu.Unlock()
 	atomic.AddInt64(&p.stats.dials, 1)
 	conn, err := p.Dial()
 	if err != nil {
-		defer p.cond.Signal()
+		atomic.AddInt64(&p.stats.dialErrors, 1)
 		p.mu.Lock()
 		// Unreserve dial slot
 		p.open--
+		p.dialing--
+		p.dialFailures++
+		p.dialErr = err
+		p.dialRetryAt = time.Now().Add(p.dialBackoff(p.dialFailures))
+		if p.closed {
+			// Shutdown waits for all dials to finish
+			p.cond.Broadcast()
+		} else {
+			// Waiters might be waiting for a dial slot or need to fail fast
+			p.wakeAllLocked(poolWake{})
+		}
 		p.mu.Unlock()
 		return nil, err
 	}
+	p.mu.Lock()
+	if !p.closed && p.epoch != epoch {
+		// Keep the dial slot for the next dial
+		p.mu.Unlock()
+		_ = conn.Close()
+		return nil, errConnStale
+	}
 	// Link connection to pool
 	conn.pool = p
 
 	// Register connection
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.connections[conn] = struct{}{}
+	p.dialing--
+	p.dialFailures, p.dialErr, p.dialRetryAt = 0, nil, time.Time{}
+	if p.closed {
+		// Pool was closed while dialing
+		p.open--
+		p.cond.Broadcast()
+		p.mu.Unlock()
+		conn.pool = nil
+		_ = conn.Close()
+		return nil, errPoolClosed
+	}
+	conn.poolEpoch = epoch
+	p.connections[conn] = conn.conn
+	if p.MaxConcurrentDials > 0 {
+		// The first waiter can use the dial slot
+		p.wakeLocked(poolWake{})
+	}
+	p.mu.Unlock()
 	return conn, nil
 }
 
+const defaultMaxDialBackoff = 30 * time.Second
+
+// dialBackoff returns the delay before the next dial after n consecutive failures
+//
+// The delay is randomized between half and the full exponential delay so that pools do not dial in sync.
+func (p *Pool) dialBackoff(n int) time.Duration {
+	base := p.DialBackoff
+	if base <= 0 || n <= 0 {
+		return 0
+	}
+	max := p.MaxDialBackoff
+	if max <= 0 {
+		max = defaultMaxDialBackoff
+	}
+	d := base
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// Warm dials connections until MinConnections are open
+//
+// It blocks until all connections are dialed or ctx is done.
+func (p *Pool) Warm(ctx context.Context) error {
+	min := p.minConnections()
+	if max := p.maxConnections(); min > max {
+		min = max
+	}
+	var conns []*Conn
+	defer func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}()
+	for {
+		p.once.Do(p.init)
+		p.mu.Lock()
+		open := p.open
+		p.mu.Unlock()
+		if open >= min {
+			return nil
+		}
+		// Idle connections are held until the end so that new connections are dialed
+		c, err := p.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, c)
+	}
+}
+
 func (p *Pool) popLocked() (conn *Conn) {
 	if i := len(p.idle) - 1; 0 <= i && i < len(p.idle) {
 		// Elide bounds check by keeping everything in one statement
@@ -336,56 +822,186 @@ func (p *Pool) popLocked() (conn *Conn) {
 
 // GetDeadline waits until deadline for a connection
 func (p *Pool) GetDeadline(deadline time.Time) (c *Conn, err error) {
+	return p.get(context.Background(), deadline)
+}
+
+// get acquires a connection discarding unhealthy idle connections
+func (p *Pool) get(ctx context.Context, deadline time.Time) (*Conn, error) {
+	for {
+		c, err := p.acquire(ctx, deadline)
+		if err != nil {
+			return nil, err
+		}
+		if p.checkConn(c, time.Now(), false) {
+			return c, nil
+		}
+		p.discard(c)
+	}
+}
+
+// acquire pops an idle connection or dials a new one, waiting in queue if none is available
+func (p *Pool) acquire(ctx context.Context, deadline time.Time) (*Conn, error) {
 	max := p.maxConnections()
-	isTimeout := !deadline.IsZero()
 	p.once.Do(p.init)
 	p.mu.Lock()
-	if p.closed {
-		p.mu.Unlock()
-		return nil, errPoolClosed
+	if len(p.waiters) == 0 || p.closed {
+		c, dial, err := p.tryAcquireLocked(max)
+		if c != nil || dial || err != nil {
+			p.mu.Unlock()
+			return p.acquired(ctx, deadline, c, dial, err, false)
+		}
 	}
-	if c = p.popLocked(); c != nil {
+	// Wait in queue behind earlier waiters
+	w := &poolWaiter{wake: make(chan poolWake, 1)}
+	p.waiters = append(p.waiters, w)
+	p.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		p.observeWait(time.Since(start))
+	}()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		var wake poolWake
+		select {
+		case wake = <-w.wake:
+		case <-timeout:
+			return nil, p.cancelWait(w, errDeadlineExceeded)
+		case <-ctx.Done():
+			return nil, p.cancelWait(w, ctx.Err())
+		}
+		if wake.conn != nil || wake.err != nil {
+			return p.acquired(ctx, deadline, wake.conn, false, wake.err, true)
+		}
+		// Woken up to retry
+		p.mu.Lock()
+		c, dial, err := p.tryAcquireLocked(max)
+		if c != nil || dial || err != nil {
+			p.mu.Unlock()
+			return p.acquired(ctx, deadline, c, dial, err, true)
+		}
+		// Keep our place at the front of the queue
+		p.waiters = append(p.waiters, nil)
+		copy(p.waiters[1:], p.waiters)
+		p.waiters[0] = w
 		p.mu.Unlock()
+	}
+}
+
+// acquired updates stats and dials a new connection if a dial slot was reserved
+func (p *Pool) acquired(ctx context.Context, deadline time.Time, c *Conn, dial bool, err error, waited bool) (*Conn, error) {
+	switch {
+	case err != nil:
+		return nil, err
+	case dial:
+		return p.dialDeadline(ctx, deadline)
+	case waited:
+		atomic.AddInt64(&p.stats.misses, 1)
+	default:
 		atomic.AddInt64(&p.stats.hits, 1)
-		return
 	}
-	if p.open < max {
-		p.open++
-		p.mu.Unlock()
-		p.cond.Signal()
-		return p.dial()
+	return c, nil
+}
+
+// tryAcquireLocked pops an idle connection or reserves a dial slot
+func (p *Pool) tryAcquireLocked(max int) (c *Conn, dial bool, err error) {
+	if p.closed {
+		return nil, false, errPoolClosed
 	}
+	if c = p.popLocked(); c != nil {
+		return c, false, nil
+	}
+	dial, err = p.reserveDialLocked(max)
+	return nil, dial, err
+}
 
-	for c == nil {
-		// Block waiting for broadcast
-		p.cond.Wait()
-		// This happens after cond locks again
-		if p.closed {
-			p.mu.Unlock()
-			return nil, errPoolClosed
-		}
-		if isTimeout && p.wall.After(deadline) {
-			p.mu.Unlock()
-			// There might be available connections for others
-			p.cond.Signal()
-			// Update stats
-			atomic.AddInt64(&p.stats.timeouts, 1)
-			return nil, errDeadlineExceeded
-		}
-		if p.open < max {
-			p.open++
+// poolWaiter is a goroutine waiting for a connection
+type poolWaiter struct {
+	// Waiters are removed from the queue when woken so the buffer never fills
+	wake chan poolWake
+}
+
+// poolWake wakes up a waiter with a connection or an error
+//
+// If both are nil the waiter retries to pop an idle connection or dial a new one.
+type poolWake struct {
+	conn *Conn
+	err  error
+}
+
+// wakeLocked wakes the first waiter in queue
+func (p *Pool) wakeLocked(wake poolWake) bool {
+	if len(p.waiters) == 0 {
+		return false
+	}
+	w := p.waiters[0]
+	n := copy(p.waiters, p.waiters[1:])
+	p.waiters[n] = nil
+	p.waiters = p.waiters[:n]
+	w.wake <- wake
+	return true
+}
+
+// wakeAllLocked wakes all waiters in queue
+func (p *Pool) wakeAllLocked(wake poolWake) {
+	for i, w := range p.waiters {
+		w.wake <- wake
+		p.waiters[i] = nil
+	}
+	p.waiters = p.waiters[:0]
+}
+
+// releaseLocked hands an idle connection to the first waiter or adds it to the queue
+func (p *Pool) releaseLocked(c *Conn) {
+	if !p.wakeLocked(poolWake{conn: c}) {
+		p.queue = append(p.queue, c)
+	}
+}
+
+// cancelWait removes a waiter that gave up from the queue
+//
+// If the waiter was woken concurrently the wake is passed on to the next waiter.
+func (p *Pool) cancelWait(w *poolWaiter, err error) error {
+	atomic.AddInt64(&p.stats.timeouts, 1)
+	p.mu.Lock()
+	for i, q := range p.waiters {
+		if q == w {
+			n := copy(p.waiters[i:], p.waiters[i+1:])
+			p.waiters[i+n] = nil
+			p.waiters = p.waiters[:i+n]
 			p.mu.Unlock()
-			p.cond.Signal()
-			return p.dial()
+			return err
 		}
-		c = p.popLocked()
+	}
+	wake := <-w.wake
+	switch {
+	case wake.err != nil:
+		err = wake.err
+	case wake.conn != nil && p.closed:
+		p.mu.Unlock()
+		p.discard(wake.conn)
+		return err
+	case wake.conn != nil:
+		p.releaseLocked(wake.conn)
+	default:
+		p.wakeLocked(wake)
 	}
 	p.mu.Unlock()
+	return err
+}
 
-	// Update stats
-	atomic.AddInt64(&p.stats.misses, 1)
-	if c == nil {
-		panic("Nil connection")
+// observeWait records the time a goroutine waited in queue
+func (p *Pool) observeWait(d time.Duration) {
+	atomic.AddInt64(&p.stats.waits, 1)
+	atomic.AddInt64(&p.stats.waitTime, int64(d))
+	i := 0
+	for i < len(PoolWaitBuckets) && d > PoolWaitBuckets[i] {
+		i++
 	}
-	return
+	atomic.AddInt64(&p.stats.waitBuckets[i], 1)
 }
@@ -0,0 +1,291 @@
+package red
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alxarch/red/internal/pipeline"
+)
+
+// sharedConn is a connection pipelining commands from concurrent goroutines
+//
+// Commands are written to the pipeline buffer as they arrive and flushed in
+// batches by a background goroutine. Replies are matched to commands in FIFO order.
+type sharedConn struct {
+	conn  *Conn
+	mu    sync.Mutex // Guards conn writes and pipeline state
+	cond  sync.Cond
+	calls []*sharedCall
+	err   error // Sticky error, no more commands are accepted
+	drain bool  // No more commands are accepted, close after pending replies
+	flush chan struct{}
+	done  chan struct{}
+}
+
+type sharedCall struct {
+	dest interface{}
+	err  error
+	done chan struct{}
+}
+
+var errSharedConnClosed = errors.New("Shared connection closed")
+
+func newSharedConn(conn *Conn) *sharedConn {
+	sc := sharedConn{
+		conn:  conn,
+		flush: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	sc.cond.L = &sc.mu
+	go sc.readReplies(conn.conn)
+	go sc.flushCommands()
+	return &sc
+}
+
+// do writes a command to the pipeline and waits for the reply
+//
+// It returns errSharedConnClosed if the command was not written.
+func (sc *sharedConn) do(dest interface{}, name string, args []Arg) error {
+	call := sharedCall{
+		dest: dest,
+		done: make(chan struct{}),
+	}
+	sc.mu.Lock()
+	if sc.err != nil || sc.drain {
+		sc.mu.Unlock()
+		return errSharedConnClosed
+	}
+	if err := sc.conn.WriteCommand(name, args...); err != nil {
+		if sc.conn.Err() != nil {
+			sc.failLocked(err)
+		}
+		sc.mu.Unlock()
+		return err
+	}
+	sc.calls = append(sc.calls, &call)
+	sc.cond.Signal()
+	sc.mu.Unlock()
+	// Wake the flusher if it is not already pending
+	select {
+	case sc.flush <- struct{}{}:
+	default:
+	}
+	<-call.done
+	return call.err
+}
+
+func (sc *sharedConn) alive() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.err == nil && !sc.drain
+}
+
+// close stops accepting commands and closes the connection once all pending replies are read
+func (sc *sharedConn) close() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.drain = true
+	sc.cond.Broadcast()
+}
+
+// failLocked closes the connection and fails all pending calls
+func (sc *sharedConn) failLocked(err error) {
+	if sc.err != nil {
+		return
+	}
+	sc.err = err
+	sc.conn.closeConn()
+	close(sc.done)
+	for i, call := range sc.calls {
+		call.err = err
+		close(call.done)
+		sc.calls[i] = nil
+	}
+	sc.calls = nil
+	sc.cond.Broadcast()
+}
+
+// flushCommands flushes the pipeline buffer whenever commands are written
+//
+// Commands written while a flush is in progress are sent in the next batch.
+func (sc *sharedConn) flushCommands() {
+	for {
+		select {
+		case <-sc.done:
+			return
+		case <-sc.flush:
+			sc.mu.Lock()
+			if sc.err == nil {
+				if err := sc.conn.w.Flush(); err != nil {
+					sc.failLocked(err)
+				}
+			}
+			sc.mu.Unlock()
+		}
+	}
+}
+
+// popLocked pops the next call and its pipeline entry
+func (sc *sharedConn) popLocked() (*sharedCall, pipeline.Entry, error) {
+	for len(sc.calls) == 0 {
+		if sc.err != nil {
+			return nil, pipeline.Entry{}, sc.err
+		}
+		if sc.drain {
+			return nil, pipeline.Entry{}, errSharedConnClosed
+		}
+		sc.cond.Wait()
+	}
+	call := sc.calls[0]
+	sc.calls[0] = nil
+	sc.calls = sc.calls[1:]
+	for {
+		entry, ok := sc.conn.state.Pop()
+		if !ok {
+			return call, entry, ErrNoReplies
+		}
+		if !entry.Skip() {
+			return call, entry, nil
+		}
+	}
+}
+
+// readReplies reads replies in the order commands were written
+//
+// The network connection is passed so that reads do not race with writers closing the connection.
+func (sc *sharedConn) readReplies(netConn net.Conn) {
+	timeout := sc.conn.options.ReadTimeout
+	obs := sc.conn.options.Observer
+	for {
+		sc.mu.Lock()
+		call, entry, err := sc.popLocked()
+		if err != nil {
+			if call != nil {
+				call.err = err
+				close(call.done)
+			}
+			sc.failLocked(err)
+			sc.mu.Unlock()
+			return
+		}
+		sc.mu.Unlock()
+		if timeout > 0 {
+			err = netConn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		if err == nil {
+			if obs != nil {
+				err = sc.conn.decodeObserved(obs, call.dest, entry)
+			} else {
+				err = sc.conn.r.Decode(call.dest)
+			}
+		}
+		call.err = err
+		close(call.done)
+		if err != nil && !isDecodeError(err) {
+			sc.mu.Lock()
+			sc.failLocked(err)
+			sc.mu.Unlock()
+			return
+		}
+	}
+}
+
+// sharedCommand checks if a command can be pipelined on a shared connection
+//
+// Blocking commands, transactions, subscriptions and commands changing the connection state are excluded.
+func sharedCommand(name string, args []Arg) bool {
+	switch strings.ToUpper(name) {
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BZPOPMIN", "BZPOPMAX", "BLMOVE", "BLMPOP", "BZMPOP", "WAIT",
+		"MULTI", "EXEC", "DISCARD", "WATCH", "UNWATCH",
+		"SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE", "MONITOR",
+		"SELECT", "AUTH", "HELLO", "RESET", "QUIT":
+		return false
+	case "CLIENT":
+		// Only subcommands that do not change the connection state
+		// A Key subcommand is matched without the KeyPrefix of pool connections
+		switch clientSubcommand(args, "") {
+		case "LIST", "INFO", "KILL", "ID", "GETNAME":
+			return true
+		}
+		return false
+	case "XREAD", "XREADGROUP":
+		for i := range args {
+			if s, ok := args[i].Value().(string); ok && strings.ToUpper(s) == "BLOCK" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sharedConn returns a shared connection dialing a new one if needed
+func (p *Pool) sharedConn() (*sharedConn, error) {
+	p.once.Do(p.init)
+	n := p.SharedConnections
+	i := int(atomic.AddUint32(&p.sharedNext, 1) % uint32(n))
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errPoolClosed
+	}
+	if p.shared == nil {
+		p.shared = make([]*sharedConn, n)
+	}
+	if sc := p.shared[i]; sc != nil && sc.alive() {
+		p.mu.Unlock()
+		return sc, nil
+	}
+	p.mu.Unlock()
+
+	// Only one goroutine dials shared connections
+	p.sharedDial.Lock()
+	defer p.sharedDial.Unlock()
+	p.mu.Lock()
+	if sc := p.shared[i]; sc != nil && sc.alive() {
+		p.mu.Unlock()
+		return sc, nil
+	}
+	p.mu.Unlock()
+	atomic.AddInt64(&p.stats.dials, 1)
+	conn, err := p.Dial()
+	if err != nil {
+		return nil, err
+	}
+	sc := newSharedConn(conn)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		sc.close()
+		return nil, errPoolClosed
+	}
+	p.shared[i] = sc
+	return sc, nil
+}
+
+// doShared executes a command on a shared connection
+func (p *Pool) doShared(dest interface{}, cmd string, args []Arg) error {
+	for {
+		sc, err := p.sharedConn()
+		if err != nil {
+			return err
+		}
+		if err := sc.do(dest, cmd, args); err != errSharedConnClosed {
+			return err
+		}
+		// The connection was drained before the command was written
+	}
+}
+
+// closeSharedLocked closes all shared connections once their pending replies are read
+func (p *Pool) closeSharedLocked() {
+	for i, sc := range p.shared {
+		if sc != nil {
+			sc.close()
+		}
+		p.shared[i] = nil
+	}
+}
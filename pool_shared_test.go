@@ -0,0 +1,94 @@
+package red_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestPool_SharedConnections(t *testing.T) {
+	var mu sync.Mutex
+	var blocking int
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		switch strings.ToUpper(args[0]) {
+		case "ECHO":
+			return bulk(args[1])
+		case "BLPOP":
+			mu.Lock()
+			blocking++
+			mu.Unlock()
+			return resp.Array{bulk(args[1]), bulk("value")}
+		default:
+			return resp.Error("ERR unknown command")
+		}
+	})
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), nil)
+		},
+		SharedConnections: 2,
+	}
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				msg := fmt.Sprintf("%d:%d", i, j)
+				var reply string
+				if err := pool.DoCommand(&reply, "ECHO", red.String(msg)); err != nil {
+					errs <- err
+					return
+				}
+				if reply != msg {
+					errs <- fmt.Errorf("Invalid reply %q != %q", reply, msg)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if stats := pool.Stats(); stats.Dials != 2 || stats.Active != 0 {
+		t.Errorf("Invalid stats %v", stats)
+	}
+
+	// Error replies do not break the pipeline
+	var reply string
+	if err := pool.DoCommand(&reply, "FOO"); err == nil {
+		t.Errorf("Error reply not returned")
+	}
+	if err := pool.DoCommand(&reply, "ECHO", red.String("foo")); err != nil || reply != "foo" {
+		t.Errorf("Invalid reply after error %q %v", reply, err)
+	}
+
+	// Blocking commands use a pool connection
+	var pop []string
+	if err := pool.DoCommand(&pop, "BLPOP", red.Key("list"), red.Int(1)); err != nil {
+		t.Fatalf("BLPOP failed %s", err)
+	}
+	mu.Lock()
+	if stats := pool.Stats(); stats.Dials != 3 || blocking != 1 {
+		t.Errorf("Blocking command pipelined %v", stats)
+	}
+	mu.Unlock()
+
+	// Drained shared connections are replaced
+	pool.Drain()
+	if err := pool.DoCommand(&reply, "ECHO", red.String("bar")); err != nil || reply != "bar" {
+		t.Errorf("Invalid reply after drain %q %v", reply, err)
+	}
+	if stats := pool.Stats(); stats.Dials != 4 {
+		t.Errorf("Shared connection not replaced %v", stats)
+	}
+}
@@ -1,6 +1,8 @@
 package red_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
@@ -99,3 +101,479 @@ func Test_Pool(t *testing.T) {
 		t.Errorf("Invalid stats %#v", stats)
 	}
 }
+
+func TestPool_HealthChecks(t *testing.T) {
+	var pings int64
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		switch args[0] {
+		case "PING":
+			atomic.AddInt64(&pings, 1)
+			return resp.SimpleString("PONG")
+		case "ECHO":
+			return bulk(args[1])
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+	dial := func() (*red.Conn, error) {
+		return red.Dial(srv.Addr(), &red.ConnOptions{
+			ReadTimeout: time.Second,
+		})
+	}
+	echo := func(pool *red.Pool) {
+		t.Helper()
+		var reply string
+		if err := pool.DoCommand(&reply, "ECHO", red.String("foo")); err != nil {
+			t.Fatalf("ECHO failed %s", err)
+		}
+	}
+
+	t.Run("TestOnBorrow", func(t *testing.T) {
+		pool := red.Pool{
+			Dial:         dial,
+			TestOnBorrow: time.Millisecond,
+		}
+		defer pool.Close()
+		echo(&pool)
+		srv.Disconnect()
+		time.Sleep(5 * time.Millisecond)
+		// Dead connection is discarded before it is returned
+		echo(&pool)
+		if stats := pool.Stats(); stats.Dials != 2 {
+			t.Errorf("Invalid dials %d", stats.Dials)
+		}
+	})
+	t.Run("MaxConnLifetime", func(t *testing.T) {
+		pool, err := red.ParseURL("redis://" + srv.Addr() + "?max-conn-lifetime=10ms")
+		if err != nil {
+			t.Fatalf("ParseURL failed %s", err)
+		}
+		defer pool.Close()
+		if pool.MaxConnLifetime != 10*time.Millisecond {
+			t.Fatalf("Invalid max connection lifetime %s", pool.MaxConnLifetime)
+		}
+		echo(pool)
+		echo(pool)
+		time.Sleep(20 * time.Millisecond)
+		echo(pool)
+		if stats := pool.Stats(); stats.Dials != 2 {
+			t.Errorf("Invalid dials %d", stats.Dials)
+		}
+	})
+	t.Run("HealthCheckInterval", func(t *testing.T) {
+		pool := red.Pool{
+			Dial:                dial,
+			HealthCheckInterval: 10 * time.Millisecond,
+		}
+		defer pool.Close()
+		echo(&pool)
+		before := atomic.LoadInt64(&pings)
+		time.Sleep(50 * time.Millisecond)
+		if atomic.LoadInt64(&pings) == before {
+			t.Errorf("Idle connections were not checked")
+		}
+		if stats := pool.Stats(); stats.Idle != 1 {
+			t.Errorf("Invalid idle connections %v", stats)
+		}
+		srv.Disconnect()
+		time.Sleep(50 * time.Millisecond)
+		if stats := pool.Stats(); stats.Idle != 0 || stats.Active != 0 {
+			t.Errorf("Dead connections were not discarded %v", stats)
+		}
+		echo(&pool)
+	})
+	t.Run("HungPing", func(t *testing.T) {
+		hang := make(chan struct{})
+		defer close(hang)
+		var hung int64
+		srv := newFakeServer(t, func(args []string) resp.Any {
+			if args[0] == "PING" && atomic.LoadInt64(&hung) == 1 {
+				<-hang
+			}
+			return resp.SimpleString("OK")
+		})
+		pool := red.Pool{
+			Dial: func() (*red.Conn, error) {
+				// No ReadTimeout so only the health check deadline stops a hung PING
+				return red.Dial(srv.Addr(), nil)
+			},
+			MaxConnections:      8,
+			HealthCheckInterval: 20 * time.Millisecond,
+		}
+		defer pool.Close()
+		conns := make([]*red.Conn, 8)
+		for i := range conns {
+			conn, err := pool.Get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			conns[i] = conn
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+		atomic.StoreInt64(&hung, 1)
+		time.Sleep(30 * time.Millisecond)
+		// Connections not under check are available
+		conn, err := pool.GetTimeout(5 * time.Millisecond)
+		if err != nil {
+			t.Fatalf("Get starved during health check %s", err)
+		}
+		conn.Close()
+		time.Sleep(100 * time.Millisecond)
+		// Hung connections are discarded after the PING deadline
+		if stats := pool.Stats(); stats.Active == 8 || stats.Dials != 8 {
+			t.Errorf("Hung connections were not discarded %v", stats)
+		}
+	})
+}
+
+func TestPool_Warm(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), nil)
+		},
+		MinConnections: 3,
+		MaxConnections: 5,
+	}
+	defer pool.Close()
+	if err := pool.Warm(context.Background()); err != nil {
+		t.Fatalf("Warm failed %s", err)
+	}
+	if stats := pool.Stats(); stats.Dials != 3 || stats.Idle != 3 {
+		t.Errorf("Invalid stats after warm up %v", stats)
+	}
+
+	eager := red.Pool{
+		Dial:           pool.Dial,
+		MinConnections: 2,
+		MaxConnections: 5,
+		WarmUp:         true,
+	}
+	defer eager.Close()
+	if err := eager.DoCommand(nil, "PING"); err != nil {
+		t.Fatalf("PING failed %s", err)
+	}
+	for i := 0; eager.Stats().Dials < 2; i++ {
+		if i > 100 {
+			t.Fatalf("Pool was not warmed up %v", eager.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool_MaxConcurrentDials(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	var dialing, maxDialing int64
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			n := atomic.AddInt64(&dialing, 1)
+			defer atomic.AddInt64(&dialing, -1)
+			for {
+				max := atomic.LoadInt64(&maxDialing)
+				if n <= max || atomic.CompareAndSwapInt64(&maxDialing, max, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return red.Dial(srv.Addr(), nil)
+		},
+		MaxConnections:     10,
+		MaxConcurrentDials: 2,
+	}
+	defer pool.Close()
+	var wg sync.WaitGroup
+	conns := make(chan *red.Conn, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := pool.Get()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conns <- conn
+		}()
+	}
+	wg.Wait()
+	close(conns)
+	for conn := range conns {
+		conn.Close()
+	}
+	if max := atomic.LoadInt64(&maxDialing); max > 2 {
+		t.Errorf("Too many concurrent dials %d", max)
+	}
+	if stats := pool.Stats(); stats.Dials != 10 {
+		t.Errorf("Invalid dials %d", stats.Dials)
+	}
+}
+
+func TestPool_DialBackoff(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	errDial := errors.New("Dial failed")
+	var fail int32 = 1
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			if atomic.LoadInt32(&fail) == 1 {
+				return nil, errDial
+			}
+			return red.Dial(srv.Addr(), nil)
+		},
+		DialBackoff: 50 * time.Millisecond,
+	}
+	defer pool.Close()
+	if _, err := pool.Get(); err != errDial {
+		t.Fatalf("Invalid dial error %v", err)
+	}
+	// Dials are skipped during backoff
+	if _, err := pool.Get(); !errors.Is(err, errDial) {
+		t.Fatalf("Invalid backoff error %v", err)
+	}
+	stats := pool.Stats()
+	if stats.Dials != 1 || stats.DialErrors != 1 || stats.DialFailures != 1 || stats.LastDialError != errDial {
+		t.Errorf("Invalid stats %v", stats)
+	}
+	if wait := time.Until(stats.DialRetryAt); wait <= 0 || wait > 50*time.Millisecond {
+		t.Errorf("Invalid retry time %s", wait)
+	}
+	atomic.StoreInt32(&fail, 0)
+	time.Sleep(time.Until(stats.DialRetryAt))
+	conn, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed after backoff %s", err)
+	}
+	conn.Close()
+	if stats := pool.Stats(); stats.Dials != 2 || stats.DialFailures != 0 || stats.LastDialError != nil {
+		t.Errorf("Invalid stats after backoff %v", stats)
+	}
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), nil)
+		},
+		MaxConnections: 3,
+	}
+	idle, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stuck, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle.Close()
+	if err := pending.WriteCommand("PING"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	type result struct {
+		forced int
+		err    error
+	}
+	done := make(chan result)
+	go func() {
+		forced, err := pool.Shutdown(ctx)
+		done <- result{forced, err}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := pool.GetTimeout(time.Millisecond); err == nil {
+		t.Errorf("Get should fail during shutdown")
+	}
+	if stats := pool.Stats(); stats.Idle != 0 || stats.Active != 2 {
+		t.Errorf("Invalid stats during shutdown %v", stats)
+	}
+	// Pending replies are read before the connection is closed
+	pending.Close()
+	if stats := pool.Stats(); stats.Active != 1 {
+		t.Errorf("Released connection was not closed %v", stats)
+	}
+	res := <-done
+	if res.forced != 1 || res.err != context.DeadlineExceeded {
+		t.Errorf("Invalid shutdown result %d %v", res.forced, res.err)
+	}
+	if err := stuck.DoCommand(nil, "PING"); err == nil {
+		t.Errorf("Connection was not closed forcibly")
+	}
+	stuck.Close()
+	if stats := pool.Stats(); stats.Active != 0 {
+		t.Errorf("Invalid stats after shutdown %v", stats)
+	}
+	if _, err := pool.Shutdown(context.Background()); err == nil {
+		t.Errorf("Shutdown should fail on a closed pool")
+	}
+
+	// All connections are released in time
+	released := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), nil)
+		},
+		MaxConnections: 2,
+	}
+	conn, err := released.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		conn.Close()
+	}()
+	if forced, err := released.Shutdown(context.Background()); forced != 0 || err != nil {
+		t.Errorf("Invalid shutdown result %d %v", forced, err)
+	}
+}
+
+func TestPool_WaitQueue(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), nil)
+		},
+		MaxConnections: 1,
+	}
+	defer pool.Close()
+	conn, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := pool.GetTimeout(30 * time.Millisecond); err == nil {
+		t.Errorf("Get should time out")
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond || elapsed > 80*time.Millisecond {
+		t.Errorf("Imprecise deadline %s", elapsed)
+	}
+
+	// Waiters get connections in the order they arrived
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := pool.Get()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			conn.Close()
+		}(i)
+		for pool.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	conn.Close()
+	wg.Wait()
+	if !reflect.DeepEqual(order, []int{0, 1, 2}) {
+		t.Errorf("Unfair wait order %v", order)
+	}
+
+	stats := pool.Stats()
+	if stats.Waiting != 0 || stats.Waits != 4 || stats.Misses != 3 || stats.Timeouts != 1 {
+		t.Errorf("Invalid stats %v", stats)
+	}
+	var waits int64
+	for _, n := range stats.WaitBuckets {
+		waits += n
+	}
+	if len(stats.WaitBuckets) != len(red.PoolWaitBuckets)+1 || waits != stats.Waits {
+		t.Errorf("Invalid wait buckets %v", stats.WaitBuckets)
+	}
+	if stats.WaitTime < 40*time.Millisecond {
+		t.Errorf("Invalid wait time %s", stats.WaitTime)
+	}
+}
+
+func TestPool_DrainWhileDialing(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	var pool red.Pool
+	var dialed []*red.Conn
+	pool.Dial = func() (*red.Conn, error) {
+		conn, err := red.Dial(srv.Addr(), nil)
+		if err == nil && len(dialed) == 0 {
+			// Failover happens while dialing the old master
+			pool.Drain()
+		}
+		dialed = append(dialed, conn)
+		return conn, err
+	}
+	defer pool.Close()
+	conn, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if len(dialed) != 2 || conn != dialed[1] {
+		t.Fatalf("Connection dialed during drain was not replaced")
+	}
+	if dialed[0].Err() == nil {
+		t.Errorf("Stale connection was not closed")
+	}
+	if stats := pool.Stats(); stats.Active != 1 {
+		t.Errorf("Invalid stats %v", stats)
+	}
+}
+
+func TestPool_DialDeadline(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	unblock := make(chan struct{})
+	pool := red.Pool{MaxConnections: 2}
+	pool.Dial = func() (*red.Conn, error) {
+		<-unblock
+		return red.Dial(srv.Addr(), nil)
+	}
+	defer pool.Close()
+	start := time.Now()
+	if _, err := pool.GetTimeout(10 * time.Millisecond); err == nil {
+		t.Fatalf("Get should fail while dial hangs")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Get did not return on deadline")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Invalid error %v", err)
+	}
+	close(unblock)
+	// Connections dialed after the wait is over are released to the pool
+	for i := 0; i < 100; i++ {
+		if stats := pool.Stats(); stats.Idle == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats := pool.Stats(); stats.Idle != 2 || stats.Active != 2 || stats.Timeouts != 2 {
+		t.Errorf("Invalid stats %v", stats)
+	}
+}
@@ -63,6 +63,7 @@ type Subscriber struct {
 type PubSubMessage struct {
 	Channel string
 	Payload string
+	Values  []string // Array payload of client tracking invalidations (nil for all keys)
 }
 
 func (sub *Subscriber) isClosed() bool {
@@ -118,6 +119,10 @@ func (sub *Subscriber) PSubscribe(patterns ...string) error {
 func (sub *Subscriber) do(cmd string, args ...string) error {
 	sub.writeLock.Lock()
 	defer sub.writeLock.Unlock()
+	if sub.conn.Conn == nil {
+		// Connection was released after a failed write
+		return errSubscriberClosed
+	}
 	sub.args.Reset()
 	sub.args.Strings(args...)
 	sub.pending += len(args)
@@ -269,10 +274,12 @@ func (sub *Subscriber) listenPubSub(messages chan<- *PubSubMessage) {
 		case pubsub.KindMessage:
 			payload, _ := msg.Payload()
 			channel, _ := msg.Channel()
+			values, _ := msg.Values()
 			select {
 			case messages <- (&PubSubMessage{
 				Channel: channel,
 				Payload: payload,
+				Values:  values,
 			}):
 			case <-sub.closeCh:
 			}
@@ -30,17 +30,21 @@ func (*noCopy) Lock()   {}
 func (*noCopy) Unlock() {}
 
 // Mode defines command modes NX/XX
+//
+// Modes are distinct bits and can be combined, ie NX|CH.
 type Mode uint
 
 // Execution modes
 const (
-	_ Mode = iota << 1
+	_ Mode = 1 << iota
 	NX
 	XX
 	CH
 	INCR
 	EX
 	PX
+	GT
+	LT
 	// MK
 )
 
@@ -73,6 +77,16 @@ func (m Mode) EX() bool {
 func (m Mode) PX() bool {
 	return m&PX == PX
 }
+
+// GT checks if mode is GT
+func (m Mode) GT() bool {
+	return m&GT == GT
+}
+
+// LT checks if mode is LT
+func (m Mode) LT() bool {
+	return m&LT == LT
+}
 func (m Mode) String() string {
 	switch m {
 	case NX:
@@ -83,6 +97,10 @@ func (m Mode) String() string {
 		return "CH"
 	case INCR:
 		return "INCR"
+	case GT:
+		return "GT"
+	case LT:
+		return "LT"
 	default:
 		return ""
 
@@ -0,0 +1,58 @@
+package red_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+func TestMode(t *testing.T) {
+	modes := []red.Mode{red.NX, red.XX, red.CH, red.INCR, red.EX, red.PX, red.GT, red.LT}
+	var all red.Mode
+	for _, mode := range modes {
+		if mode == 0 || mode&(mode-1) != 0 {
+			t.Errorf("Mode %d is not a single bit", mode)
+		}
+		if all&mode != 0 {
+			t.Errorf("Mode %d overlaps other modes", mode)
+		}
+		all |= mode
+	}
+	// CH used to equal NX|XX
+	if red.CH.NX() || red.CH.XX() || (red.NX | red.XX).CH() {
+		t.Errorf("CH overlaps NX and XX")
+	}
+
+	var mu sync.Mutex
+	var commands [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args)
+		return resp.Integer(1)
+	})
+	conn, err := red.Dial(srv.Addr(), nil)
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	b := new(red.Batch)
+	b.ZAdd("foo", red.CH, red.Z("bar", 1))
+	b.ZAdd("foo", red.NX|red.XX, red.Z("bar", 1))
+	if err := conn.DoBatch(b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	expectCommands := [][]string{
+		{"SELECT", "0"},
+		{"ZADD", "foo", "CH", "1", "bar"},
+		{"ZADD", "foo", "NX", "1", "bar"},
+	}
+	if !reflect.DeepEqual(commands, expectCommands) {
+		t.Errorf("Invalid commands\n%q\n%q", commands, expectCommands)
+	}
+}
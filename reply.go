@@ -34,6 +34,17 @@ func (r *ReplyInteger) Reply() (int64, error) {
 	return int64(r.n), r.err
 }
 
+// nullInteger is an integer reply that fails with resp.ErrNull on null replies
+type nullInteger resp.Integer
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (n *nullInteger) UnmarshalRESP(v resp.Value) error {
+	if v.Null() {
+		return resp.ErrNull
+	}
+	return (*resp.Integer)(n).UnmarshalRESP(v)
+}
+
 // ReplySimpleString is a redis status reply
 type ReplySimpleString struct {
 	status resp.SimpleString
@@ -73,6 +84,48 @@ func (r *ReplyBulkStringArray) Reply() ([]string, error) {
 	return r.values, r.err
 }
 
+// ReplyBoolArray is a redis array reply with integer elements with values 1 or 0
+type ReplyBoolArray struct {
+	values []int64
+	batchReply
+}
+
+// Reply returns the boolean values
+func (r *ReplyBoolArray) Reply() ([]bool, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	values := make([]bool, len(r.values))
+	for i, n := range r.values {
+		values[i] = n == 1
+	}
+	return values, nil
+}
+
+// ReplyIntegerArray is a redis array reply with integer elements
+type ReplyIntegerArray struct {
+	values []int64
+	batchReply
+}
+
+// Reply returns the integer values
+func (r *ReplyIntegerArray) Reply() ([]int64, error) {
+	return r.values, r.err
+}
+
+// ReplyFloatArray is a redis array reply with bulk string elements that are parsed as floats
+//
+// Null elements are NaN.
+type ReplyFloatArray struct {
+	values []float64
+	batchReply
+}
+
+// Reply returns the float values
+func (r *ReplyFloatArray) Reply() ([]float64, error) {
+	return r.values, r.err
+}
+
 // ReplyFloat is a redis bulk string reply that is parsed as a float
 type ReplyFloat struct {
 	f float64
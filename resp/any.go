@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"math/big"
 	"math/bits"
 	"reflect"
 	"strconv"
@@ -57,7 +58,7 @@ func (s *SimpleString) UnmarshalRESP(v Value) error {
 		case TypeSimpleString:
 			*s = SimpleString(v.msg.str(h))
 			return nil
-		case TypeError:
+		case TypeError, TypeBlobError:
 			return Error(v.msg.str(h))
 		default:
 			return fmt.Errorf("Invalid RESP value %s", h.typ)
@@ -76,6 +77,22 @@ func reflectAssign(dst, src interface{}) bool {
 	return false
 }
 
+// reflectZeroInt sets integer targets to zero, the way floats are set to NaN for null values
+func reflectZeroInt(dst interface{}) bool {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return false
+	}
+	dv = dv.Elem()
+	switch dv.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		dv.Set(reflect.Zero(dv.Type()))
+		return true
+	}
+	return false
+}
+
 // Decode implements Value interface
 func (s SimpleString) Decode(x interface{}) error {
 	switch dest := x.(type) {
@@ -115,7 +132,7 @@ var _ Unmarshaler = (*Error)(nil)
 func (e *Error) UnmarshalRESP(v Value) error {
 	if h := v.hint(); h != nil {
 		switch h.typ {
-		case TypeError:
+		case TypeError, TypeBlobError:
 			*e = Error(v.msg.str(h))
 			return nil
 		default:
@@ -183,7 +200,16 @@ func (s *BulkString) UnmarshalRESP(v Value) error {
 				}
 			}
 			return nil
-		case TypeError:
+		case TypeVerbatimString:
+			*s = BulkString{
+				String: verbatimString(v.msg.str(h)).String,
+				Valid:  true,
+			}
+			return nil
+		case TypeNull:
+			*s = BulkString{}
+			return nil
+		case TypeError, TypeBlobError:
 			return Error(v.msg.str(h))
 		default:
 			return fmt.Errorf("Invalid RESP value %s", h.typ)
@@ -360,6 +386,9 @@ func (s *BulkString) Decode(x interface{}) error {
 			return nil
 		}
 	}
+	if s.Null() && reflectZeroInt(x) {
+		return nil
+	}
 	return fmt.Errorf("Invalid bulk string target %v", x)
 }
 
@@ -388,7 +417,10 @@ func (i *Integer) UnmarshalRESP(v Value) error {
 		case TypeInteger:
 			*i = Integer(h.int())
 			return nil
-		case TypeError:
+		case TypeBoolean:
+			*i = Integer(h.size)
+			return nil
+		case TypeError, TypeBlobError:
 			return Error(v.msg.str(h))
 		default:
 			return fmt.Errorf("Invalid RESP value %s", h.typ)
@@ -473,6 +505,9 @@ func (a Array) Decode(x interface{}) error {
 	case *[]Any:
 		*dest = a
 		return nil
+	case *Array:
+		*dest = a
+		return nil
 	case *interface{}:
 		if a == nil {
 			*dest = nil
@@ -583,7 +618,7 @@ var _ Unmarshaler = (*Array)(nil)
 
 // UnmarshalRESP implements Unmarshaler interface
 func (a *Array) UnmarshalRESP(v Value) error {
-	if h := v.hint(); h != nil && h.typ == TypeArray {
+	if h := v.hint(); h != nil && (h.typ.Aggregate() || h.typ == TypeNull) {
 		if h.null {
 			*a = nil
 			return nil
@@ -658,19 +693,370 @@ func (a Array) BulkStringMap() (BulkStringMap, error) {
 	return m, nil
 }
 
+// Double is a RESP3 double value
+type Double float64
+
+var _ Any = Double(0)
+
+// Type implements Any interface
+func (Double) Type() Type { return TypeDouble }
+func (Double) resp()      {}
+
+// AppendRESP implements Appender interface
+func (d Double) AppendRESP(buf []byte) []byte {
+	buf = append(buf, byte(TypeDouble))
+	f := float64(d)
+	switch {
+	case math.IsInf(f, 1):
+		buf = append(buf, "inf"...)
+	case math.IsInf(f, -1):
+		buf = append(buf, "-inf"...)
+	case math.IsNaN(f):
+		buf = append(buf, "nan"...)
+	default:
+		buf = strconv.AppendFloat(buf, f, 'f', -1, 64)
+	}
+	return append(buf, CRLF...)
+}
+
+// Decode implements Any interface
+func (d Double) Decode(x interface{}) error {
+	switch x := x.(type) {
+	case *float64:
+		*x = float64(d)
+		return nil
+	case *float32:
+		*x = float32(d)
+		return nil
+	case *interface{}:
+		*x = float64(d)
+		return nil
+	case *string:
+		*x = strconv.FormatFloat(float64(d), 'f', -1, 64)
+		return nil
+	default:
+		if reflectAssign(x, float64(d)) {
+			return nil
+		}
+		return fmt.Errorf("Invalid Double target %v", x)
+	}
+}
+
+// Boolean is a RESP3 boolean value
+type Boolean bool
+
+var _ Any = Boolean(false)
+
+// Type implements Any interface
+func (Boolean) Type() Type { return TypeBoolean }
+func (Boolean) resp()      {}
+
+// AppendRESP implements Appender interface
+func (b Boolean) AppendRESP(buf []byte) []byte {
+	if b {
+		return append(buf, byte(TypeBoolean), 't', '\r', '\n')
+	}
+	return append(buf, byte(TypeBoolean), 'f', '\r', '\n')
+}
+
+// Decode implements Any interface
+func (b Boolean) Decode(x interface{}) error {
+	switch x := x.(type) {
+	case *bool:
+		*x = bool(b)
+		return nil
+	case *interface{}:
+		*x = bool(b)
+		return nil
+	case *int64:
+		if b {
+			*x = 1
+		} else {
+			*x = 0
+		}
+		return nil
+	default:
+		if reflectAssign(x, bool(b)) {
+			return nil
+		}
+		return fmt.Errorf("Invalid Boolean target %v", x)
+	}
+}
+
+// BigNumber is a RESP3 big number value
+type BigNumber string
+
+var _ Any = BigNumber("")
+
+// Type implements Any interface
+func (BigNumber) Type() Type { return TypeBigNumber }
+func (BigNumber) resp()      {}
+
+// AppendRESP implements Appender interface
+func (n BigNumber) AppendRESP(buf []byte) []byte {
+	buf = append(buf, byte(TypeBigNumber))
+	buf = append(buf, n...)
+	return append(buf, CRLF...)
+}
+
+// Int returns the big number as *big.Int
+func (n BigNumber) Int() (*big.Int, bool) {
+	return new(big.Int).SetString(string(n), 10)
+}
+
+// Decode implements Any interface
+func (n BigNumber) Decode(x interface{}) error {
+	switch x := x.(type) {
+	case *string:
+		*x = string(n)
+		return nil
+	case *big.Int:
+		if _, ok := x.SetString(string(n), 10); !ok {
+			return fmt.Errorf("Invalid big number %q", string(n))
+		}
+		return nil
+	case **big.Int:
+		i, ok := n.Int()
+		if !ok {
+			return fmt.Errorf("Invalid big number %q", string(n))
+		}
+		*x = i
+		return nil
+	case *interface{}:
+		i, ok := n.Int()
+		if !ok {
+			return fmt.Errorf("Invalid big number %q", string(n))
+		}
+		*x = i
+		return nil
+	case *int64:
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil {
+			return err
+		}
+		*x = i
+		return nil
+	case *uint64:
+		i, err := strconv.ParseUint(string(n), 10, 64)
+		if err != nil {
+			return err
+		}
+		*x = i
+		return nil
+	case *float64:
+		f, err := strconv.ParseFloat(string(n), 64)
+		if err != nil {
+			return err
+		}
+		*x = f
+		return nil
+	default:
+		if reflectAssign(x, string(n)) {
+			return nil
+		}
+		return fmt.Errorf("Invalid BigNumber target %v", x)
+	}
+}
+
+// Null is the RESP3 null value
+type Null struct{}
+
+var _ Any = Null{}
+
+// Type implements Any interface
+func (Null) Type() Type { return TypeNull }
+func (Null) resp()      {}
+
+// AppendRESP implements Appender interface
+func (Null) AppendRESP(buf []byte) []byte {
+	return append(buf, byte(TypeNull), '\r', '\n')
+}
+
+// Decode implements Any interface
+//
+// Null decodes like a null bulk string to scalar targets and like a null array to
+// slices, maps and pointers.
+func (Null) Decode(x interface{}) error {
+	switch x := x.(type) {
+	case *interface{}:
+		*x = nil
+		return nil
+	case *[]string:
+		*x = nil
+		return nil
+	case *map[string]string:
+		*x = nil
+		return nil
+	}
+	if v := reflect.ValueOf(x); v.Kind() == reflect.Ptr && !v.IsNil() {
+		switch el := v.Elem(); el.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface:
+			el.Set(reflect.Zero(el.Type()))
+			return nil
+		}
+	}
+	null := BulkString{}
+	return null.Decode(x)
+}
+
+// VerbatimString is a RESP3 verbatim string value
+type VerbatimString struct {
+	Format string // Three letter format (ie 'txt', 'mkd')
+	String string
+}
+
+var _ Any = VerbatimString{}
+
+func verbatimString(s string) VerbatimString {
+	if len(s) >= 4 && s[3] == ':' {
+		return VerbatimString{
+			Format: s[:3],
+			String: s[4:],
+		}
+	}
+	return VerbatimString{
+		String: s,
+	}
+}
+
+// Type implements Any interface
+func (VerbatimString) Type() Type { return TypeVerbatimString }
+func (VerbatimString) resp()      {}
+
+// AppendRESP implements Appender interface
+func (s VerbatimString) AppendRESP(buf []byte) []byte {
+	format := s.Format
+	if len(format) != 3 {
+		format = "txt"
+	}
+	buf = append(buf, byte(TypeVerbatimString))
+	buf = strconv.AppendInt(buf, int64(len(format)+1+len(s.String)), 10)
+	buf = append(buf, CRLF...)
+	buf = append(buf, format...)
+	buf = append(buf, ':')
+	buf = append(buf, s.String...)
+	return append(buf, CRLF...)
+}
+
+// Decode implements Any interface
+func (s VerbatimString) Decode(x interface{}) error {
+	bulk := BulkString{
+		String: s.String,
+		Valid:  true,
+	}
+	return bulk.Decode(x)
+}
+
+// Map is a RESP3 map value.
+//
+// Keys and values are stored in turn so a map decodes to the same targets
+// as the flat key/value array used by RESP2 replies.
+type Map []Any
+
+var _ Any = (Map)(nil)
+
+// Type implements Any interface
+func (Map) Type() Type { return TypeMap }
+func (Map) resp()      {}
+
+// AppendRESP implements Appender interface
+func (m Map) AppendRESP(buf []byte) []byte {
+	buf = appendAggregate(buf, TypeMap, int64(len(m)/2))
+	for _, v := range m {
+		buf = v.AppendRESP(buf)
+	}
+	return buf
+}
+
+// Decode implements Any interface
+func (m Map) Decode(x interface{}) error {
+	if m == nil {
+		m = Map{}
+	}
+	return Array(m).Decode(x)
+}
+
+// Get returns the value of the first key with a string value equal to key
+func (m Map) Get(key string) (Any, bool) {
+	for i := 0; i+1 < len(m); i += 2 {
+		var k string
+		if err := m[i].Decode(&k); err == nil && k == key {
+			return m[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// Set is a RESP3 set value
+type Set []Any
+
+var _ Any = (Set)(nil)
+
+// Type implements Any interface
+func (Set) Type() Type { return TypeSet }
+func (Set) resp()      {}
+
+// AppendRESP implements Appender interface
+func (s Set) AppendRESP(buf []byte) []byte {
+	buf = appendAggregate(buf, TypeSet, int64(len(s)))
+	for _, v := range s {
+		buf = v.AppendRESP(buf)
+	}
+	return buf
+}
+
+// Decode implements Any interface
+func (s Set) Decode(x interface{}) error {
+	if s == nil {
+		s = Set{}
+	}
+	return Array(s).Decode(x)
+}
+
+// Push is a RESP3 out of band push value
+type Push []Any
+
+var _ Any = (Push)(nil)
+
+// Type implements Any interface
+func (Push) Type() Type { return TypePush }
+func (Push) resp()      {}
+
+// AppendRESP implements Appender interface
+func (p Push) AppendRESP(buf []byte) []byte {
+	buf = appendAggregate(buf, TypePush, int64(len(p)))
+	for _, v := range p {
+		buf = v.AppendRESP(buf)
+	}
+	return buf
+}
+
+// Decode implements Any interface
+func (p Push) Decode(x interface{}) error {
+	if p == nil {
+		p = Push{}
+	}
+	return Array(p).Decode(x)
+}
+
 // ReadAny read a RESP Value from a buffered reader
+//
+// RESP3 attributes are discarded.
 func ReadAny(r *bufio.Reader) (Any, error) {
 	typ, line, err := readNext(r)
 	if err != nil {
 		return nil, err
 	}
 	switch typ {
-	case TypeBulkString:
+	case TypeBulkString, TypeVerbatimString, TypeBlobError:
 		n, ok := internal.ParseInt(line)
-		if !ok || n <= -1 {
+		if !ok || n < -1 {
 			return nil, errInvalidSize
 		}
 		if n == -1 {
+			if typ != TypeBulkString {
+				return nil, errInvalidSize
+			}
 			return &BulkString{}, nil
 		}
 		b := BulkString{Valid: true}
@@ -685,6 +1071,12 @@ func ReadAny(r *bufio.Reader) (Any, error) {
 		if _, err := r.Discard(len(CRLF)); err != nil {
 			return nil, err
 		}
+		switch typ {
+		case TypeVerbatimString:
+			return verbatimString(b.String), nil
+		case TypeBlobError:
+			return Error(b.String), nil
+		}
 		return &b, nil
 	case TypeInteger:
 		if n, ok := internal.ParseInt(line); ok {
@@ -695,14 +1087,37 @@ func ReadAny(r *bufio.Reader) (Any, error) {
 		return SimpleString(line), nil
 	case TypeError:
 		return Error(line), nil
-	case TypeArray:
+	case TypeDouble:
+		if f, ok := parseDouble(line); ok {
+			return Double(f), nil
+		}
+		return nil, errInvalidDouble
+	case TypeBoolean:
+		switch string(line) {
+		case "t":
+			return Boolean(true), nil
+		case "f":
+			return Boolean(false), nil
+		}
+		return nil, errInvalidBoolean
+	case TypeBigNumber:
+		return BigNumber(line), nil
+	case TypeNull:
+		return Null{}, nil
+	case TypeArray, TypeSet, TypePush, TypeMap, TypeAttribute:
 		n, ok := internal.ParseInt(line)
-		if !ok || n <= -1 {
+		if !ok || n < -1 {
 			return nil, errInvalidSize
 		}
 		if n == -1 {
+			if typ != TypeArray {
+				return nil, errInvalidSize
+			}
 			return Array(nil), nil
 		}
+		if typ.pairs() {
+			n *= 2
+		}
 		values := make([]Any, n)
 		for i := range values {
 			v, err := ReadAny(r)
@@ -711,6 +1126,16 @@ func ReadAny(r *bufio.Reader) (Any, error) {
 			}
 			values[i] = v
 		}
+		switch typ {
+		case TypeSet:
+			return Set(values), nil
+		case TypePush:
+			return Push(values), nil
+		case TypeMap:
+			return Map(values), nil
+		case TypeAttribute:
+			return ReadAny(r)
+		}
 		return Array(values), nil
 	default:
 		r.UnreadByte()
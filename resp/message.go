@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/alxarch/red/resp/internal"
@@ -15,21 +16,24 @@ import (
 type Message struct {
 	buffer string
 	hints  []hint
+	attrs  []attribute
 }
 
 // ReadFrom reads a reply from a redis stream.
 func (msg *Message) ReadFrom(r *bufio.Reader) (Value, error) {
 	p := parser{
 		hints: msg.hints[:0],
+		attrs: msg.attrs[:0],
 	}
 
 	p.reserve(1)
-	if err := p.parse(r, &p.hints[0]); err != nil {
+	if err := p.parse(r, 0); err != nil {
 		return Value{}, err
 	}
 	*msg = Message{
 		buffer: p.buffer.String(),
 		hints:  p.hints,
+		attrs:  p.attrs,
 	}
 	return Value{msg: msg}, nil
 }
@@ -52,6 +56,7 @@ func (msg *Message) ParseString(str string) (Value, error) {
 func (msg *Message) Reset() {
 	*msg = Message{
 		hints: msg.hints[:0],
+		attrs: msg.attrs[:0],
 	}
 }
 
@@ -77,6 +82,15 @@ func (msg *Message) str(h *hint) string {
 	return h.str(msg.buffer)
 }
 
+func (msg *Message) attributes(index uint32) (uint32, bool) {
+	for _, a := range msg.attrs {
+		if a.index == index {
+			return a.attr, true
+		}
+	}
+	return 0, false
+}
+
 func zeroSlize(v reflect.Value) {
 	zero := reflect.Zero(v.Type().Elem())
 	for i := 0; i < v.Len(); i++ {
@@ -183,9 +197,7 @@ func (msg *Message) decodeBulkStringSlice(a *[]string, h *hint) error {
 		if err := s.UnmarshalRESP(node); err != nil {
 			return fmt.Errorf("Invalid element %d: %s", i, err)
 		}
-		if s.Null() {
-			return fmt.Errorf("Invalid element %d: %s", i, ErrNull)
-		}
+		// Null elements decode to the zero value
 		arr[i] = s.String
 		node.index++
 	}
@@ -220,6 +232,13 @@ func (msg *Message) decodeBulkStringMap(h *hint) (map[string]string, error) {
 type parser struct {
 	buffer strings.Builder
 	hints  []hint
+	attrs  []attribute
+}
+
+// attribute links a value to the attributes sent before it
+type attribute struct {
+	index uint32
+	attr  uint32
 }
 
 type hint struct {
@@ -257,7 +276,7 @@ func (p *parser) copy(line []byte) (offset uint32) {
 	return
 }
 
-func (p *parser) parse(r *bufio.Reader, h *hint) (err error) {
+func (p *parser) parse(r *bufio.Reader, index uint32) (err error) {
 	line, isPrefix, err := r.ReadLine()
 	if err != nil {
 		return
@@ -271,8 +290,9 @@ func (p *parser) parse(r *bufio.Reader, h *hint) (err error) {
 	if len(line) > 0 {
 		typ, line = Type(line[0]), line[1:]
 	}
+	h := &p.hints[index]
 	switch typ {
-	case TypeSimpleString, TypeError:
+	case TypeSimpleString, TypeError, TypeBigNumber:
 		n := len(line)
 		if n == 0 {
 			*h = hint{typ: typ}
@@ -286,24 +306,46 @@ func (p *parser) parse(r *bufio.Reader, h *hint) (err error) {
 		return
 	case TypeInteger:
 		if n, ok := internal.ParseInt(line); ok {
-			u := uint64(n)
-			*h = hint{
-				typ:    TypeInteger,
-				offset: uint32(u >> 32),
-				size:   uint32(u),
-			}
+			*h = int64Hint(TypeInteger, n)
 			return
 		}
 		return errInvalidInteger
-	case TypeBulkString:
+	case TypeDouble:
+		if f, ok := parseDouble(line); ok {
+			*h = int64Hint(TypeDouble, int64(math.Float64bits(f)))
+			return
+		}
+		return errInvalidDouble
+	case TypeBoolean:
+		if len(line) == 1 {
+			switch line[0] {
+			case 't':
+				*h = hint{typ: TypeBoolean, size: 1}
+				return
+			case 'f':
+				*h = hint{typ: TypeBoolean}
+				return
+			}
+		}
+		return errInvalidBoolean
+	case TypeNull:
+		if len(line) == 0 {
+			*h = hint{typ: TypeNull, null: true}
+			return
+		}
+		return errInvalidNull
+	case TypeBulkString, TypeVerbatimString, TypeBlobError:
 		if n, ok := internal.ParseInt(line); ok {
 			if 0 < n && n <= math.MaxUint32 {
+				if typ == TypeVerbatimString && n < int64(len("txt:")) {
+					return errInvalidFormat
+				}
 				if buffered := r.Buffered(); int64(buffered) < n {
 					// String is longer than buffered data
 					var offset uint32
 					offset, err = p.copyBulkString(r, n)
 					*h = hint{
-						typ:    TypeBulkString,
+						typ:    typ,
 						offset: offset,
 						size:   uint32(n),
 					}
@@ -311,53 +353,45 @@ func (p *parser) parse(r *bufio.Reader, h *hint) (err error) {
 				}
 				// Read from buffered data
 				peek, _ := r.Peek(int(n))
-				// if len(peek) <= maxTinyStringSize {
-				// 	*h = tinyString(TypeBulkString, peek)
-				// } else {
 				*h = hint{
-					typ:    TypeBulkString,
+					typ:    typ,
 					offset: p.copy(peek),
 					size:   uint32(len(peek)),
 				}
-				// }
 				_, err = r.Discard(len(peek) + len(CRLF))
 				return
 			}
-			if n == -1 {
+			if n == -1 && typ == TypeBulkString {
 				*h = hint{
-					typ:  TypeBulkString,
+					typ:  typ,
 					null: true,
 				}
 				return
 			}
-			if n == 0 {
+			if n == 0 && typ != TypeVerbatimString {
 				*h = hint{
-					typ: TypeBulkString,
+					typ: typ,
 				}
 				_, err = r.Discard(len(CRLF))
 				return
 			}
 		}
 		return errInvalidSize
-	case TypeArray:
+	case TypeArray, TypeSet, TypePush, TypeMap:
 		if n, ok := internal.ParseInt(line); ok {
+			if typ.pairs() {
+				n *= 2
+			}
 			if 0 < n && n < math.MaxUint32 {
-
-				// WARNING: order of statements is important
-				offset := uint32(len(p.hints))
-				// Assign to h BEFORE reserve() so the pointer is still valid
-				*h = hint{
-					typ:    TypeArray,
+				// NOTE: reserve() may reallocate hints so h is no longer valid
+				offset := p.reserve(n)
+				p.hints[index] = hint{
+					typ:    typ,
 					offset: offset,
 					size:   uint32(n),
 				}
-				// Order is important see above
-				p.reserve(n)
-
-				// WARNING END: order of statements is important
-
 				for n > 0 {
-					if err = p.parse(r, &p.hints[offset]); err != nil {
+					if err = p.parse(r, offset); err != nil {
 						return
 					}
 					offset++
@@ -365,26 +399,75 @@ func (p *parser) parse(r *bufio.Reader, h *hint) (err error) {
 				}
 				return
 			}
-			if n == -1 {
+			if n == -1 && typ == TypeArray {
 				*h = hint{
-					typ:  TypeArray,
+					typ:  typ,
 					null: true,
 				}
 				return
 			}
 			if n == 0 {
 				*h = hint{
-					typ: TypeArray,
+					typ: typ,
 				}
 				return
 			}
 		}
 		return errInvalidSize
+	case TypeAttribute:
+		// Attributes are parsed to a separate hint and the actual value is parsed at index.
+		if n, ok := internal.ParseInt(line); ok && 0 <= n && n < math.MaxUint32/2 {
+			n *= 2
+			attr := p.reserve(1)
+			offset := p.reserve(n)
+			p.hints[attr] = hint{
+				typ:    TypeAttribute,
+				offset: offset,
+				size:   uint32(n),
+			}
+			for ; n > 0; n-- {
+				if err = p.parse(r, offset); err != nil {
+					return
+				}
+				offset++
+			}
+			if err = p.parse(r, index); err != nil {
+				return
+			}
+			p.attrs = append(p.attrs, attribute{
+				index: index,
+				attr:  attr,
+			})
+			return
+		}
+		return errInvalidSize
 	default:
 		return errInvalidType
 	}
 }
 
+func int64Hint(typ Type, n int64) hint {
+	u := uint64(n)
+	return hint{
+		typ:    typ,
+		offset: uint32(u >> 32),
+		size:   uint32(u),
+	}
+}
+
+func parseDouble(line []byte) (float64, bool) {
+	switch string(line) {
+	case "inf":
+		return math.Inf(1), true
+	case "-inf":
+		return math.Inf(-1), true
+	case "nan":
+		return math.NaN(), true
+	}
+	f, err := strconv.ParseFloat(string(line), 64)
+	return f, err == nil
+}
+
 func (p *parser) reserve(n int64) (index uint32) {
 	size := n + int64(len(p.hints))
 	if 0 <= size && size < int64(cap(p.hints)) {
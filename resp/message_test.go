@@ -3,6 +3,7 @@ package resp
 import (
 	"bufio"
 	"bytes"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -62,6 +63,39 @@ func TestReplyReadFrom(t *testing.T) {
 		},
 			[...]interface{}{"FOO", "BAR"},
 		},
+		{",3.14\r\n", Double(3.14), 3.14},
+		{",-inf\r\n", Double(math.Inf(-1)), math.Inf(-1)},
+		{"#t\r\n", Boolean(true), true},
+		{"#f\r\n", Boolean(false), false},
+		{"(3492890328409238509324850943850943825024385\r\n", BigNumber("3492890328409238509324850943850943825024385"), "3492890328409238509324850943850943825024385"},
+		{"_\r\n", Null{}, nil},
+		{"_\r\n", Null{}, ([]string)(nil)},
+		{"=15\r\ntxt:Some string\r\n", VerbatimString{Format: "txt", String: "Some string"}, "Some string"},
+		{"%2\r\n+foo\r\n:1\r\n+bar\r\n:2\r\n", Map{
+			SimpleString("foo"), Integer(1),
+			SimpleString("bar"), Integer(2),
+		},
+			map[string]int64{"foo": 1, "bar": 2},
+		},
+		{"%1\r\n$3\r\nfoo\r\n$3\r\nbar\r\n", Map{
+			&BulkString{String: "foo", Valid: true},
+			&BulkString{String: "bar", Valid: true},
+		},
+			[]string{"foo", "bar"},
+		},
+		{"~2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n", Set{
+			&BulkString{String: "foo", Valid: true},
+			&BulkString{String: "bar", Valid: true},
+		},
+			[]interface{}{"foo", "bar"},
+		},
+		{">3\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\nfoo\r\n#t\r\n", Push{
+			&BulkString{String: "invalidate", Valid: true},
+			Array{&BulkString{String: "foo", Valid: true}},
+			Boolean(true),
+		},
+			[]interface{}{"invalidate", []interface{}{"foo"}, true},
+		},
 	} {
 		b.Reset()
 		b.WriteString(args.RESP)
@@ -137,3 +171,111 @@ func TestParseValue(t *testing.T) {
 	}
 
 }
+
+func TestReplyReadFromRESP3(t *testing.T) {
+	msg := Message{}
+	const attrRESP = "|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.1923\r\n*2\r\n:2039123\r\n:9543892\r\n"
+	v, err := msg.ParseString(attrRESP)
+	if err != nil {
+		t.Fatalf("Parse failed %s", err)
+	}
+	var values []int64
+	if err := v.Decode(&values); err != nil {
+		t.Errorf("Decode failed %s", err)
+	}
+	if !reflect.DeepEqual(values, []int64{2039123, 9543892}) {
+		t.Errorf("Invalid values %v", values)
+	}
+	attr := v.Attributes()
+	if typ := attr.Type(); typ != TypeAttribute {
+		t.Errorf("Invalid attributes type %s", typ)
+	}
+	var popularity map[string]map[string]float64
+	if err := attr.Decode(&popularity); err != nil {
+		t.Errorf("Decode attributes failed %s", err)
+	}
+	if !reflect.DeepEqual(popularity, map[string]map[string]float64{
+		"key-popularity": {"a": 0.1923},
+	}) {
+		t.Errorf("Invalid attributes %v", popularity)
+	}
+	if out := v.AppendRESP(nil); string(out) != attrRESP {
+		t.Errorf("Invalid RESP %q", out)
+	}
+
+	v, err = msg.ParseString("!21\r\nSYNTAX invalid syntax\r\n")
+	if err != nil {
+		t.Fatalf("Parse failed %s", err)
+	}
+	if err := v.Err(); err == nil || err.Error() != "SYNTAX invalid syntax" {
+		t.Errorf("Invalid blob error %v", err)
+	}
+	var s string
+	if err := v.Decode(&s); err == nil {
+		t.Errorf("Blob error decoded to string %q", s)
+	}
+
+	v, err = msg.ParseString("_\r\n")
+	if err != nil {
+		t.Fatalf("Parse failed %s", err)
+	}
+	if !v.NullBulkString() || !v.NullArray() {
+		t.Errorf("Null is not null")
+	}
+	for _, invalid := range []string{"#x\r\n", ",foo\r\n", "_foo\r\n", "=2\r\nab\r\n"} {
+		if _, err := msg.ParseString(invalid); err == nil {
+			t.Errorf("Parse %q did not fail", invalid)
+		}
+	}
+}
+
+func TestReadAnyRESP3(t *testing.T) {
+	const src = "|1\r\n+ttl\r\n:3600\r\n%1\r\n+foo\r\n~1\r\n,1.5\r\n"
+	any, err := ReadAny(bufio.NewReader(bytes.NewReader([]byte(src))))
+	if err != nil {
+		t.Fatalf("ReadAny failed %s", err)
+	}
+	expect := Map{SimpleString("foo"), Set{Double(1.5)}}
+	if !reflect.DeepEqual(any, expect) {
+		t.Errorf("Invalid value %#v", any)
+	}
+	if err := discardNext(bufio.NewReader(bytes.NewReader([]byte(src + "+OK\r\n")))); err != nil {
+		t.Errorf("Discard failed %s", err)
+	}
+}
+
+func TestDecodeNullElementsRESP3(t *testing.T) {
+	msg := Message{}
+	v, err := msg.ParseString("*2\r\n:1\r\n_\r\n")
+	if err != nil {
+		t.Fatalf("Parse failed %s", err)
+	}
+	var ints []int64
+	if err := v.Decode(&ints); err != nil {
+		t.Errorf("Decode []int64 failed %s", err)
+	} else if !reflect.DeepEqual(ints, []int64{1, 0}) {
+		t.Errorf("Invalid []int64 %v", ints)
+	}
+
+	v, err = msg.ParseString("*2\r\n,1.5\r\n_\r\n")
+	if err != nil {
+		t.Fatalf("Parse failed %s", err)
+	}
+	var floats []float64
+	if err := v.Decode(&floats); err != nil {
+		t.Errorf("Decode []float64 failed %s", err)
+	} else if len(floats) != 2 || floats[0] != 1.5 || !math.IsNaN(floats[1]) {
+		t.Errorf("Invalid []float64 %v", floats)
+	}
+
+	v, err = msg.ParseString("*2\r\n$3\r\nfoo\r\n_\r\n")
+	if err != nil {
+		t.Fatalf("Parse failed %s", err)
+	}
+	var strs []string
+	if err := v.Decode(&strs); err != nil {
+		t.Errorf("Decode []string failed %s", err)
+	} else if !reflect.DeepEqual(strs, []string{"foo", ""}) {
+		t.Errorf("Invalid []string %q", strs)
+	}
+}
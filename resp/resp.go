@@ -19,6 +19,18 @@ const (
 	TypeInteger      Type = ':'
 	TypeBulkString   Type = '$'
 	TypeArray        Type = '*'
+
+	// RESP3 types
+	TypeDouble         Type = ','
+	TypeBoolean        Type = '#'
+	TypeBigNumber      Type = '('
+	TypeNull           Type = '_'
+	TypeVerbatimString Type = '='
+	TypeBlobError      Type = '!'
+	TypeMap            Type = '%'
+	TypeSet            Type = '~'
+	TypeAttribute      Type = '|'
+	TypePush           Type = '>'
 )
 
 func (t Type) String() string {
@@ -33,11 +45,51 @@ func (t Type) String() string {
 		return "BulkString"
 	case TypeArray:
 		return "Array"
+	case TypeDouble:
+		return "Double"
+	case TypeBoolean:
+		return "Boolean"
+	case TypeBigNumber:
+		return "BigNumber"
+	case TypeNull:
+		return "Null"
+	case TypeVerbatimString:
+		return "VerbatimString"
+	case TypeBlobError:
+		return "BlobError"
+	case TypeMap:
+		return "Map"
+	case TypeSet:
+		return "Set"
+	case TypeAttribute:
+		return "Attribute"
+	case TypePush:
+		return "Push"
 	default:
 		return fmt.Sprintf("InvalidType %c", t)
 	}
 }
 
+// Aggregate checks if a type is an aggregate of other RESP values
+func (t Type) Aggregate() bool {
+	switch t {
+	case TypeArray, TypeMap, TypeSet, TypeAttribute, TypePush:
+		return true
+	default:
+		return false
+	}
+}
+
+// pairs checks if a type is an aggregate of key/value pairs
+func (t Type) pairs() bool {
+	return t == TypeMap || t == TypeAttribute
+}
+
+// isError checks if a type is an error type
+func (t Type) isError() bool {
+	return t == TypeError || t == TypeBlobError
+}
+
 // ProtocolError is a RESP protocol error
 type ProtocolError struct {
 	Message string
@@ -55,6 +107,10 @@ var (
 	errInvalidInteger = &ProtocolError{"Invalid integer"}
 	errInvalidType    = &ProtocolError{"Invalid RESP type"}
 	errInvalidSize    = &ProtocolError{Message: "Invalid size"}
+	errInvalidDouble  = &ProtocolError{"Invalid double"}
+	errInvalidBoolean = &ProtocolError{"Invalid boolean"}
+	errInvalidNull    = &ProtocolError{"Invalid null"}
+	errInvalidFormat  = &ProtocolError{"Invalid verbatim string format"}
 )
 
 // func AppendIntArray(buf []byte, values ...int64) []byte {
@@ -13,6 +13,7 @@ type Stream struct {
 	r     *bufio.Reader
 	reply Message
 	err   error
+	push  func(v Value) bool
 }
 
 func NewStream(r io.Reader) *Stream {
@@ -133,8 +134,20 @@ func (s *Stream) discardN(n int64) error {
 // 	return v, nil
 // }
 
+// OnPush sets a handler for RESP3 push values
+//
+// Push values read before a reply are passed to fn.
+// If fn returns false the push value is decoded as the reply.
+// The value passed to fn is only valid until fn returns.
+func (s *Stream) OnPush(fn func(v Value) bool) {
+	s.push = fn
+}
+
 func (s *Stream) Decode(x interface{}) error {
 	s.reply.Reset()
+	if s.push != nil {
+		return s.decodePush(x)
+	}
 	if x == nil {
 		if err := discardNext(s.r); err != nil {
 			s.err = err
@@ -147,6 +160,28 @@ func (s *Stream) Decode(x interface{}) error {
 		s.err = err
 		return err
 	}
+	return decodeValue(v, x)
+}
+
+// decodePush decodes the next value that is not handled by the push handler
+func (s *Stream) decodePush(x interface{}) error {
+	for {
+		v, err := s.reply.ReadFrom(s.r)
+		if err != nil {
+			s.err = err
+			return err
+		}
+		if v.Type() == TypePush && s.push(v) {
+			continue
+		}
+		if x == nil {
+			return nil
+		}
+		return decodeValue(v, x)
+	}
+}
+
+func decodeValue(v Value, x interface{}) error {
 	if err := v.Decode(x); err != nil {
 		return &DecodeError{
 			Reason: err,
@@ -164,9 +199,9 @@ func discardNext(r *bufio.Reader) (err error) {
 		return
 	}
 	switch typ {
-	case TypeSimpleString, TypeError, TypeInteger:
+	case TypeSimpleString, TypeError, TypeInteger, TypeDouble, TypeBoolean, TypeBigNumber, TypeNull:
 		return
-	case TypeBulkString:
+	case TypeBulkString, TypeVerbatimString, TypeBlobError:
 		if n, ok := internal.ParseInt(line); ok {
 			if n >= 0 {
 				_, err = r.Discard(int(n + 2))
@@ -176,13 +211,20 @@ func discardNext(r *bufio.Reader) (err error) {
 			}
 		}
 		return errInvalidSize
-	case TypeArray:
+	case TypeArray, TypeSet, TypePush, TypeMap, TypeAttribute:
 		if n, ok := internal.ParseInt(line); ok && n >= -1 {
+			if typ.pairs() {
+				n *= 2
+			}
 			for ; n > 0; n-- {
 				if err = discardNext(r); err != nil {
 					return
 				}
 			}
+			if typ == TypeAttribute {
+				// Discard the value the attributes refer to
+				return discardNext(r)
+			}
 			return nil
 		}
 		return errInvalidSize
@@ -24,9 +24,9 @@ var _ Unmarshaler = (*BulkStringArray)(nil)
 func (a *BulkStringArray) UnmarshalRESP(v Value) error {
 	if h := v.hint(); h != nil {
 		switch h.typ {
-		case TypeArray:
+		case TypeArray, TypeSet, TypeMap, TypePush, TypeNull:
 			return v.msg.decodeBulkStringSlice((*[]string)(a), h)
-		case TypeError:
+		case TypeError, TypeBlobError:
 			return Error(v.msg.str(h))
 		default:
 			return fmt.Errorf("Invalid RESP value %s", h.typ)
@@ -108,14 +108,14 @@ var _ Unmarshaler = (*BulkStringMap)(nil)
 func (m *BulkStringMap) UnmarshalRESP(v Value) error {
 	if h := v.hint(); h != nil {
 		switch h.typ {
-		case TypeArray:
+		case TypeArray, TypeMap, TypeNull:
 			values, err := v.msg.decodeBulkStringMap(h)
 			if err != nil {
 				return err
 			}
 			*m = values
 			return nil
-		case TypeError:
+		case TypeError, TypeBlobError:
 			return Error(v.msg.str(h))
 		default:
 			return fmt.Errorf("Invalid RESP value %s", h.typ)
@@ -154,7 +154,13 @@ func (raw *BulkStringBytes) UnmarshalRESP(v Value) error {
 				*raw = append((*raw)[:0], v.msg.str(h)...)
 			}
 			return nil
-		case TypeError:
+		case TypeVerbatimString:
+			*raw = append((*raw)[:0], verbatimString(v.msg.str(h)).String...)
+			return nil
+		case TypeNull:
+			*raw = nil
+			return nil
+		case TypeError, TypeBlobError:
 			return Error(v.msg.str(h))
 		default:
 			return fmt.Errorf("Invalid RESP value %s", h.typ)
@@ -236,6 +242,12 @@ type SimpleStringRecord map[string]Any
 
 func (m *SimpleStringRecord) UnmarshalRESP(v Value) error {
 	any := v.Any()
+	switch a := any.(type) {
+	case Map:
+		any = Array(a)
+	case Null:
+		any = Array(nil)
+	}
 	switch any := any.(type) {
 	case Array:
 		if any == nil {
@@ -249,11 +261,17 @@ func (m *SimpleStringRecord) UnmarshalRESP(v Value) error {
 		var k, v Any
 		for len(any) >= 2 {
 			k, v, any = any[0], any[1], any[2:]
-			key, ok := k.(SimpleString)
-			if !ok {
+			switch key := k.(type) {
+			case SimpleString:
+				values[string(key)] = v
+			case *BulkString:
+				if !key.Valid {
+					return fmt.Errorf("Unexpected key value %v", k)
+				}
+				values[key.String] = v
+			default:
 				return fmt.Errorf("Unexpected key value %v", k)
 			}
-			values[string(key)] = v
 		}
 		*m = values
 		return nil
@@ -3,6 +3,7 @@ package resp
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 
 	"github.com/alxarch/red/resp/internal"
@@ -56,10 +57,20 @@ func (v Value) Decode(x interface{}) error {
 		return SimpleString(s).Decode(x)
 	case TypeInteger:
 		return Integer(h.int()).Decode(x)
-	case TypeError:
+	case TypeError, TypeBlobError:
 		s := v.msg.str(h)
 		return Error(s).Decode(x)
-	case TypeArray:
+	case TypeDouble:
+		return Double(math.Float64frombits(uint64(h.int()))).Decode(x)
+	case TypeBoolean:
+		return Boolean(h.size == 1).Decode(x)
+	case TypeBigNumber:
+		return BigNumber(v.msg.str(h)).Decode(x)
+	case TypeNull:
+		return Null{}.Decode(x)
+	case TypeVerbatimString:
+		return verbatimString(v.msg.str(h)).Decode(x)
+	case TypeArray, TypeMap, TypeSet, TypePush, TypeAttribute:
 		switch dest := x.(type) {
 		case *[]string:
 			return v.msg.decodeBulkStringSlice(dest, h)
@@ -134,7 +145,7 @@ func (v Value) hint() *hint {
 
 // Err returns an error if the value is a RESP error value.
 func (v Value) Err() error {
-	if h := v.hint(); h != nil && h.typ == TypeError {
+	if h := v.hint(); h != nil && h.typ.isError() {
 		return errors.New(v.msg.str(h))
 	}
 	return nil
@@ -148,6 +159,34 @@ func (v Value) Integer() (int64, bool) {
 	return 0, false
 }
 
+// Double returns the reply as float64.
+func (v Value) Double() (float64, bool) {
+	if h := v.hint(); h != nil && h.typ == TypeDouble {
+		return math.Float64frombits(uint64(h.int())), true
+	}
+	return 0, false
+}
+
+// Boolean returns the reply as bool.
+func (v Value) Boolean() (bool, bool) {
+	if h := v.hint(); h != nil && h.typ == TypeBoolean {
+		return h.size == 1, true
+	}
+	return false, false
+}
+
+// Attributes returns the RESP3 attributes sent along with a value.
+//
+// If the value has no attributes the zero Value is returned
+func (v Value) Attributes() Value {
+	if v.msg != nil {
+		if attr, ok := v.msg.attributes(v.index); ok {
+			return Value{msg: v.msg, index: attr}
+		}
+	}
+	return Value{}
+}
+
 // SimpleString returns a RESP simple string value
 func (v Value) SimpleString() (string, bool) {
 	if h := v.hint(); h != nil && h.typ == TypeSimpleString {
@@ -179,7 +218,7 @@ func (v Value) IsZero() bool {
 func (v Value) Null() bool {
 	if h := v.hint(); h != nil {
 		switch h.typ {
-		case TypeBulkString, TypeArray:
+		case TypeBulkString, TypeArray, TypeNull:
 			return h.null
 		}
 	}
@@ -187,28 +226,36 @@ func (v Value) Null() bool {
 }
 
 // NullArray checks if a value is a null array
+//
+// The RESP3 null value is also considered a null array.
 func (v Value) NullArray() bool {
 	h := v.hint()
-	return h != nil && h.typ == TypeArray && h.null
+	return h != nil && (h.typ == TypeArray || h.typ == TypeNull) && h.null
 }
 
 // NullBulkString checks if a value is a null bulk string
+//
+// The RESP3 null value is also considered a null bulk string.
 func (v Value) NullBulkString() bool {
 	h := v.hint()
-	return h != nil && h.typ == TypeBulkString && h.null
+	return h != nil && (h.typ == TypeBulkString || h.typ == TypeNull) && h.null
 }
 
-// Len returns the number of an array value's elements.
+// Len returns the number of an aggregate value's elements.
+//
+// For maps and attributes both keys and values are counted.
 func (v Value) Len() int64 {
-	if h := v.hint(); h != nil && h.typ == TypeArray {
+	if h := v.hint(); h != nil && h.typ.Aggregate() {
 		return int64(h.size)
 	}
 	return -1
 }
 
 // Iter returns an iterator over RESP values
+//
+// Iterating over maps and attributes yields keys and values in turn.
 func (v Value) Iter() Iter {
-	if h := v.hint(); h != nil && h.typ == TypeArray && !h.null {
+	if h := v.hint(); h != nil && h.typ.Aggregate() && !h.null {
 		return Iter{
 			offset: h.offset,
 			n:      h.size,
@@ -232,61 +279,78 @@ func (v Value) Any() Any {
 			}
 		case TypeSimpleString:
 			return SimpleString(v.msg.str(h))
-		case TypeError:
+		case TypeError, TypeBlobError:
 			return Error(v.msg.str(h))
 		case TypeInteger:
 			return Integer(h.int())
+		case TypeDouble:
+			return Double(math.Float64frombits(uint64(h.int())))
+		case TypeBoolean:
+			return Boolean(h.size == 1)
+		case TypeBigNumber:
+			return BigNumber(v.msg.str(h))
+		case TypeNull:
+			return Null{}
+		case TypeVerbatimString:
+			return verbatimString(v.msg.str(h))
 		case TypeArray:
 			if h.null {
 				return Array(nil)
 			}
-			arr := Array(make([]Any, h.size))
-			if h.size > 0 {
-				v.index = h.offset
-				for i := range arr {
-					arr[i] = v.Any()
-					v.index++
-				}
-			}
-			return arr
+			return Array(v.values(h))
+		case TypeMap, TypeAttribute:
+			return Map(v.values(h))
+		case TypeSet:
+			return Set(v.values(h))
+		case TypePush:
+			return Push(v.values(h))
 		}
 	}
 	return nil
 }
 
+func (v Value) values(h *hint) []Any {
+	values := make([]Any, h.size)
+	if h.size > 0 {
+		v.index = h.offset
+		for i := range values {
+			values[i] = v.Any()
+			v.index++
+		}
+	}
+	return values
+}
+
 // AppendRESP implements Appender interface
 func (v Value) AppendRESP(buf []byte) []byte {
+	if attr := v.Attributes(); !attr.IsZero() {
+		buf = attr.AppendRESP(buf)
+	}
 	if h := v.hint(); h != nil {
 		switch h.typ {
-		case TypeBulkString:
-			if h.null {
-				s := BulkString{}
-				return s.AppendRESP(buf)
-			}
-			s := BulkString{
-				String: v.msg.str(h),
-				Valid:  true,
-			}
-			return s.AppendRESP(buf)
-		case TypeSimpleString:
-			return SimpleString(v.msg.str(h)).AppendRESP(buf)
-		case TypeError:
-			return Error(v.msg.str(h)).AppendRESP(buf)
-		case TypeInteger:
-			return Integer(h.int()).AppendRESP(buf)
 		case TypeArray:
 			if h.null {
 				return Array(nil).AppendRESP(buf)
 			}
 			buf = appendArray(buf, int64(h.size))
-			if h.size > 0 {
-				end := h.offset + h.size
-				for v.index = h.offset; v.index < end; v.index++ {
-					buf = v.AppendRESP(buf)
-				}
+		case TypeSet, TypePush:
+			buf = appendAggregate(buf, h.typ, int64(h.size))
+		case TypeMap, TypeAttribute:
+			buf = appendAggregate(buf, h.typ, int64(h.size/2))
+		case TypeBlobError:
+			return appendBlob(buf, TypeBlobError, v.msg.str(h))
+		default:
+			if any := v.Any(); any != nil {
+				return any.AppendRESP(buf)
 			}
 			return buf
 		}
+		if h.size > 0 {
+			end := h.offset + h.size
+			for v.index = h.offset; v.index < end; v.index++ {
+				buf = v.AppendRESP(buf)
+			}
+		}
 	}
 	return buf
 }
@@ -294,14 +358,17 @@ func (v Value) AppendRESP(buf []byte) []byte {
 func (v Value) nonNullArray() (offset, size uint32, err error) {
 	if h := v.hint(); h != nil {
 		switch h.typ {
-		case TypeArray:
+		case TypeArray, TypeMap, TypeSet, TypePush:
 			if h.null {
 				err = ErrNull
 			} else {
 				offset, size = h.offset, h.size
 			}
 			return
-		case TypeError:
+		case TypeNull:
+			err = ErrNull
+			return
+		case TypeError, TypeBlobError:
 			err = Error(v.msg.str(h))
 			return
 		}
@@ -364,6 +431,29 @@ func (v Value) EachKV(fn func(k, v string) error) error {
 	return nil
 }
 
+// EachPair calls fn for each key/value pair in a map or an array of consecutive key/value pairs
+func (v Value) EachPair(fn func(k string, v Value) error) error {
+	offset, size, err := v.nonNullArray()
+	if err != nil {
+		return err
+	}
+	if size%2 != 0 {
+		return fmt.Errorf("Invalid array size %d", size)
+	}
+	end := offset + size
+	var key string
+	for v.index = offset; v.index < end; v.index++ {
+		if err := v.Decode(&key); err != nil {
+			return err
+		}
+		v.index++
+		if err := fn(key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Iter iterates over an array of RESP values
 type Iter struct {
 	offset uint32
@@ -335,3 +335,17 @@ func appendArray(buf []byte, n int64) []byte {
 	buf = strconv.AppendInt(buf, n, 10)
 	return append(buf, CRLF...)
 }
+
+func appendAggregate(buf []byte, typ Type, n int64) []byte {
+	buf = append(buf, byte(typ))
+	buf = strconv.AppendInt(buf, n, 10)
+	return append(buf, CRLF...)
+}
+
+func appendBlob(buf []byte, typ Type, s string) []byte {
+	buf = append(buf, byte(typ))
+	buf = strconv.AppendInt(buf, int64(len(s)), 10)
+	buf = append(buf, CRLF...)
+	buf = append(buf, s...)
+	return append(buf, CRLF...)
+}
@@ -0,0 +1,297 @@
+package red
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alxarch/red/resp"
+)
+
+// ScanOptions are the options of SCAN family commands
+type ScanOptions struct {
+	Match string // Glob-style pattern to filter the results
+	Count int64  // Hint for the amount of work done on each call
+	Type  string // Filter keys by type (SCAN only, available since 6.0.0)
+}
+
+// Scan iterates the keyspace starting at cursor
+//
+// The KeyPrefix is escaped and applied to the MATCH pattern so only prefixed keys are returned.
+func (b *batchAPI) Scan(cursor uint64, options ScanOptions) *ReplyScan {
+	b.args.Arg(Uint64(cursor))
+	scanArgs(&b.args, options, true)
+	return b.doScan("SCAN")
+}
+
+// HScan iterates the fields and values of the hash stored at key starting at cursor
+func (b *batchAPI) HScan(key string, cursor uint64, options ScanOptions) *ReplyScan {
+	b.args.Key(key)
+	b.args.Arg(Uint64(cursor))
+	scanArgs(&b.args, options, false)
+	return b.doScan("HSCAN")
+}
+
+// SScan iterates the members of the set stored at key starting at cursor
+func (b *batchAPI) SScan(key string, cursor uint64, options ScanOptions) *ReplyScan {
+	b.args.Key(key)
+	b.args.Arg(Uint64(cursor))
+	scanArgs(&b.args, options, false)
+	return b.doScan("SSCAN")
+}
+
+// ZScan iterates the members and scores of the sorted set stored at key starting at cursor
+func (b *batchAPI) ZScan(key string, cursor uint64, options ScanOptions) *ReplyScan {
+	b.args.Key(key)
+	b.args.Arg(Uint64(cursor))
+	scanArgs(&b.args, options, false)
+	return b.doScan("ZSCAN")
+}
+
+// scanArgs adds the options of a SCAN family command
+func scanArgs(args *ArgBuilder, options ScanOptions, keys bool) {
+	switch {
+	case keys:
+		match := options.Match
+		if match == "" {
+			match = "*"
+		}
+		args.String("MATCH")
+		args.Arg(matchPattern(match))
+	case options.Match != "":
+		args.String("MATCH")
+		args.String(options.Match)
+	}
+	if options.Count > 0 {
+		args.String("COUNT")
+		args.Int(options.Count)
+	}
+	if keys && options.Type != "" {
+		args.String("TYPE")
+		args.String(options.Type)
+	}
+}
+
+func (b *batchAPI) doScan(cmd string) *ReplyScan {
+	reply := ReplyScan{}
+	reply.Bind(&reply.page)
+	b.do(cmd, &reply.batchReply)
+	return &reply
+}
+
+// ReplyScan is the reply of a SCAN family command
+type ReplyScan struct {
+	page scanPage
+	batchReply
+}
+
+// Reply returns the next cursor and the values of a SCAN family reply
+//
+// HSCAN and ZSCAN values are field/value and member/score pairs.
+// Iteration is complete when the cursor is 0.
+func (r *ReplyScan) Reply() (uint64, []string, error) {
+	return r.page.cursor, r.page.values, r.err
+}
+
+type scanPage struct {
+	cursor uint64
+	values []string
+}
+
+func (p *scanPage) UnmarshalRESP(v resp.Value) error {
+	var cursor string
+	p.values = p.values[:0]
+	if err := v.Decode([]interface{}{&cursor, &p.values}); err != nil {
+		return err
+	}
+	n, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid SCAN cursor %q", cursor)
+	}
+	p.cursor = n
+	return nil
+}
+
+// Scanner walks a SCAN family cursor to completion
+//
+// Values returned more than once during the iteration are skipped.
+// To detect duplicates the Scanner keeps every value it returned until it is closed,
+// so memory grows with the number of values iterated (ie all keys for ScanKeys).
+// Keys returned by SCAN have the KeyPrefix removed.
+type Scanner struct {
+	conn    *Conn
+	pool    *Pool
+	cmd     string
+	key     string
+	options ScanOptions
+
+	cursor   uint64
+	done     bool
+	page     []string
+	seen     map[string]struct{}
+	item     string
+	value    string
+	err      error
+	pairs    bool
+	keyspace bool
+}
+
+func newScanner(cmd, key string, options ScanOptions) *Scanner {
+	return &Scanner{
+		cmd:      cmd,
+		key:      key,
+		options:  options,
+		seen:     make(map[string]struct{}),
+		pairs:    cmd == "HSCAN" || cmd == "ZSCAN",
+		keyspace: cmd == "SCAN",
+	}
+}
+
+// Next advances the iteration
+//
+// It returns false when the iteration is complete, stopped or failed.
+func (s *Scanner) Next() bool {
+	step := 1
+	if s.pairs {
+		step = 2
+	}
+	for s.err == nil {
+		for len(s.page) >= step {
+			item := s.page[0]
+			if s.pairs {
+				s.value = s.page[1]
+			}
+			s.page = s.page[step:]
+			if _, duplicate := s.seen[item]; duplicate {
+				continue
+			}
+			s.seen[item] = struct{}{}
+			s.item = item
+			return true
+		}
+		if s.done {
+			return false
+		}
+		s.fetch()
+	}
+	return false
+}
+
+// Item returns the current key, field or member
+func (s *Scanner) Item() string {
+	return s.item
+}
+
+// Value returns the value of the current field for HSCAN or the score of the current member for ZSCAN
+func (s *Scanner) Value() string {
+	return s.value
+}
+
+// Err returns the error that stopped the iteration
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Close stops the iteration early
+func (s *Scanner) Close() {
+	s.done = true
+	s.page = nil
+	s.seen = nil
+}
+
+func (s *Scanner) fetch() {
+	conn := s.conn
+	if conn == nil {
+		var err error
+		if conn, err = s.pool.Get(); err != nil {
+			s.err = err
+			return
+		}
+		defer conn.Close()
+	}
+	args := ArgBuilder{}
+	if !s.keyspace {
+		args.Key(s.key)
+	}
+	args.Arg(Uint64(s.cursor))
+	scanArgs(&args, s.options, s.keyspace)
+	page := scanPage{}
+	if err := conn.DoCommand(&page, s.cmd, args.Args()...); err != nil {
+		s.err = unwrapDecodeError(err)
+		return
+	}
+	if s.keyspace {
+		if prefix := conn.options.KeyPrefix; prefix != "" {
+			for i, key := range page.values {
+				page.values[i] = strings.TrimPrefix(key, prefix)
+			}
+		}
+	}
+	s.cursor = page.cursor
+	s.page = page.values
+	s.done = s.cursor == 0
+}
+
+// ScanKeys iterates over all keys using SCAN
+func (conn *Conn) ScanKeys(options ScanOptions) *Scanner {
+	s := newScanner("SCAN", "", options)
+	s.conn = conn
+	return s
+}
+
+// HScan iterates over the fields of the hash stored at key using HSCAN
+func (conn *Conn) HScan(key string, options ScanOptions) *Scanner {
+	s := newScanner("HSCAN", key, options)
+	s.conn = conn
+	return s
+}
+
+// SScan iterates over the members of the set stored at key using SSCAN
+func (conn *Conn) SScan(key string, options ScanOptions) *Scanner {
+	s := newScanner("SSCAN", key, options)
+	s.conn = conn
+	return s
+}
+
+// ZScan iterates over the members of the sorted set stored at key using ZSCAN
+func (conn *Conn) ZScan(key string, options ScanOptions) *Scanner {
+	s := newScanner("ZSCAN", key, options)
+	s.conn = conn
+	return s
+}
+
+// ScanKeys iterates over all keys using SCAN
+//
+// Each page is fetched on a pool connection.
+func (p *Pool) ScanKeys(options ScanOptions) *Scanner {
+	s := newScanner("SCAN", "", options)
+	s.pool = p
+	return s
+}
+
+// HScan iterates over the fields of the hash stored at key using HSCAN
+//
+// Each page is fetched on a pool connection.
+func (p *Pool) HScan(key string, options ScanOptions) *Scanner {
+	s := newScanner("HSCAN", key, options)
+	s.pool = p
+	return s
+}
+
+// SScan iterates over the members of the set stored at key using SSCAN
+//
+// Each page is fetched on a pool connection.
+func (p *Pool) SScan(key string, options ScanOptions) *Scanner {
+	s := newScanner("SSCAN", key, options)
+	s.pool = p
+	return s
+}
+
+// ZScan iterates over the members of the sorted set stored at key using ZSCAN
+//
+// Each page is fetched on a pool connection.
+func (p *Pool) ZScan(key string, options ScanOptions) *Scanner {
+	s := newScanner("ZSCAN", key, options)
+	s.pool = p
+	return s
+}
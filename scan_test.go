@@ -0,0 +1,165 @@
+package red_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+// newScanServer creates a fake server replying to SCAN family commands with fixed pages
+func newScanServer(t *testing.T, pages map[string][]string) (*fakeServer, func() [][]string) {
+	var mu sync.Mutex
+	var commands [][]string
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		cmd := strings.ToUpper(args[0])
+		cursor := args[1]
+		switch cmd {
+		case "SCAN":
+		case "HSCAN", "SSCAN", "ZSCAN":
+			cursor = args[2]
+		default:
+			return resp.SimpleString("OK")
+		}
+		commands = append(commands, args)
+		page, ok := pages[cursor]
+		if !ok {
+			return resp.Error("ERR invalid cursor")
+		}
+		var values resp.Array
+		for _, v := range page[1:] {
+			values = append(values, bulk(v))
+		}
+		return resp.Array{bulk(page[0]), values}
+	})
+	return srv, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return commands
+	}
+}
+
+func TestConn_ScanKeys(t *testing.T) {
+	srv, commands := newScanServer(t, map[string][]string{
+		"0": {"1", "app:a", "app:b"},
+		"1": {"2", "app:b", "app:c"},
+		"2": {"0", "app:d"},
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		KeyPrefix: "app:",
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	var keys []string
+	scan := conn.ScanKeys(red.ScanOptions{Count: 2, Type: "string"})
+	for scan.Next() {
+		keys = append(keys, scan.Item())
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatalf("Scan failed %s", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Invalid keys %v", keys)
+	}
+	cmds := commands()
+	if len(cmds) != 3 {
+		t.Fatalf("Invalid commands %v", cmds)
+	}
+	if expect := []string{"SCAN", "1", "MATCH", "app:*", "COUNT", "2", "TYPE", "string"}; !reflect.DeepEqual(cmds[1], expect) {
+		t.Errorf("Invalid command %v", cmds[1])
+	}
+
+	// Stop early
+	scan = conn.ScanKeys(red.ScanOptions{Match: "a*"})
+	if !scan.Next() || scan.Item() != "a" {
+		t.Errorf("Invalid first key %q", scan.Item())
+	}
+	scan.Close()
+	if scan.Next() {
+		t.Errorf("Scan not stopped")
+	}
+	cmds = commands()
+	if len(cmds) != 4 {
+		t.Errorf("Invalid commands after close %v", cmds)
+	}
+	if expect := []string{"SCAN", "0", "MATCH", "app:a*"}; !reflect.DeepEqual(cmds[3], expect) {
+		t.Errorf("Invalid command %v", cmds[3])
+	}
+}
+
+func TestConn_ScanKeysEscapePrefix(t *testing.T) {
+	srv, commands := newScanServer(t, map[string][]string{
+		"0": {"0", "user[1]:a"},
+	})
+	conn, err := red.Dial(srv.Addr(), &red.ConnOptions{
+		KeyPrefix: `user[1]:*?\`,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed %s", err)
+	}
+	defer conn.Close()
+	scan := conn.ScanKeys(red.ScanOptions{Match: "a*"})
+	for scan.Next() {
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatalf("Scan failed %s", err)
+	}
+	cmds := commands()
+	if len(cmds) != 1 {
+		t.Fatalf("Invalid commands %v", cmds)
+	}
+	if expect := []string{"SCAN", "0", "MATCH", `user\[1\]:\*\?\\a*`}; !reflect.DeepEqual(cmds[0], expect) {
+		t.Errorf("Invalid command %q", cmds[0])
+	}
+}
+
+func TestPool_HScan(t *testing.T) {
+	srv, commands := newScanServer(t, map[string][]string{
+		"0": {"7", "foo", "1", "bar", "2"},
+		"7": {"0", "bar", "2", "baz", "3"},
+	})
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), &red.ConnOptions{
+				KeyPrefix: "app:",
+			})
+		},
+	}
+	defer pool.Close()
+	fields := make(map[string]string)
+	var order []string
+	scan := pool.HScan("hash", red.ScanOptions{Match: "b*"})
+	for scan.Next() {
+		fields[scan.Item()] = scan.Value()
+		order = append(order, scan.Item())
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatalf("HScan failed %s", err)
+	}
+	if !reflect.DeepEqual(order, []string{"foo", "bar", "baz"}) {
+		t.Errorf("Invalid fields %v", order)
+	}
+	if !reflect.DeepEqual(fields, map[string]string{"foo": "1", "bar": "2", "baz": "3"}) {
+		t.Errorf("Invalid values %v", fields)
+	}
+	if expect := []string{"HSCAN", "app:hash", "7", "MATCH", "b*"}; !reflect.DeepEqual(commands()[1], expect) {
+		t.Errorf("Invalid command %v", commands()[1])
+	}
+
+	b := red.Batch{}
+	reply := b.SScan("set", 0, red.ScanOptions{})
+	if err := pool.DoBatch(&b); err != nil {
+		t.Fatalf("DoBatch failed %s", err)
+	}
+	cursor, values, err := reply.Reply()
+	if err != nil || cursor != 7 || !reflect.DeepEqual(values, []string{"foo", "1", "bar", "2"}) {
+		t.Errorf("Invalid SSCAN reply %d %v %v", cursor, values, err)
+	}
+}
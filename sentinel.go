@@ -0,0 +1,302 @@
+package red
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alxarch/red/resp"
+)
+
+// Sentinel provides pools of connections to a master monitored by Redis Sentinel
+//
+// The master address is discovered with SENTINEL get-master-addr-by-name.
+// Sentinel subscribes to +switch-master events and drains all pools when the master changes.
+type Sentinel struct {
+	noCopy noCopy //nolint:unused,structcheck
+
+	Addrs           []string      // Sentinel addresses (required)
+	MasterName      string        // Name of the monitored master (required)
+	Options         ConnOptions   // Options for master and replica connections
+	SentinelOptions ConnOptions   // Options for sentinel connections
+	MaxConnections  int           // Maximum number of connections per pool (defaults to 1)
+	MinConnections  int           // Minimum number of connections per pool (defaults to 1)
+	MaxIdleTime     time.Duration // Max time a connection will be left idling (0 => no limit)
+	ReadReplicas    bool          // If true the Replica pool dials replicas from SENTINEL replicas
+	RetryInterval   time.Duration // Interval to retry subscribing to sentinels (defaults to 1s)
+
+	once    sync.Once
+	master  Pool
+	replica Pool
+
+	mu      sync.Mutex
+	addr    string // Current master address
+	closed  bool
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+var errSentinelClosed = errors.New("Sentinel closed")
+
+func (s *Sentinel) init() {
+	s.closeCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	s.master = Pool{
+		Dial:           s.dialMaster,
+		MaxConnections: s.MaxConnections,
+		MinConnections: s.MinConnections,
+		MaxIdleTime:    s.MaxIdleTime,
+	}
+	s.replica = Pool{
+		Dial:           s.dialReplica,
+		MaxConnections: s.MaxConnections,
+		MinConnections: s.MinConnections,
+		MaxIdleTime:    s.MaxIdleTime,
+	}
+	go s.watch()
+}
+
+// Master returns the pool of connections to the current master
+func (s *Sentinel) Master() *Pool {
+	s.once.Do(s.init)
+	return &s.master
+}
+
+// Replica returns a pool of connections for read-only commands
+//
+// If ReadReplicas is false or there are no healthy replicas it dials the master.
+func (s *Sentinel) Replica() *Pool {
+	s.once.Do(s.init)
+	return &s.replica
+}
+
+// Addr returns the last known master address
+func (s *Sentinel) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// Close stops watching for failovers and closes all pools
+func (s *Sentinel) Close() error {
+	s.once.Do(s.init)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errSentinelClosed
+	}
+	s.closed = true
+	close(s.closeCh)
+	s.mu.Unlock()
+	<-s.doneCh
+	_ = s.replica.Close()
+	return s.master.Close()
+}
+
+// setAddr updates the master address draining all pools if it changed
+func (s *Sentinel) setAddr(addr string) {
+	s.mu.Lock()
+	prev := s.addr
+	s.addr = addr
+	s.mu.Unlock()
+	if prev != "" && prev != addr {
+		s.master.Drain()
+		s.replica.Drain()
+	}
+}
+
+func (s *Sentinel) sentinelOptions() *ConnOptions {
+	options := s.SentinelOptions
+	// Sentinels do not support SELECT
+	options.DB = -1
+	options.KeyPrefix = ""
+	return &options
+}
+
+// sentinelDo executes a command on the first sentinel that replies
+func (s *Sentinel) sentinelDo(dest interface{}, args ...Arg) error {
+	if len(s.Addrs) == 0 {
+		return fmt.Errorf("No sentinel addresses")
+	}
+	var err error
+	for _, addr := range s.Addrs {
+		var conn *Conn
+		conn, err = Dial(addr, s.sentinelOptions())
+		if err != nil {
+			continue
+		}
+		err = conn.DoCommand(dest, "SENTINEL", args...)
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+		err = unwrapDecodeError(err)
+	}
+	return err
+}
+
+// MasterAddr queries the sentinels for the master address
+func (s *Sentinel) MasterAddr() (string, error) {
+	var reply []string
+	if err := s.sentinelDo(&reply, String("get-master-addr-by-name"), String(s.MasterName)); err != nil {
+		return "", err
+	}
+	if len(reply) != 2 {
+		return "", fmt.Errorf("Master %q not found", s.MasterName)
+	}
+	return net.JoinHostPort(reply[0], reply[1]), nil
+}
+
+// ReplicaAddrs queries the sentinels for the addresses of healthy replicas
+func (s *Sentinel) ReplicaAddrs() ([]string, error) {
+	var reply []map[string]string
+	if err := s.sentinelDo(&reply, String("replicas"), String(s.MasterName)); err != nil {
+		// Redis < 5.0
+		if err := s.sentinelDo(&reply, String("slaves"), String(s.MasterName)); err != nil {
+			return nil, err
+		}
+	}
+	addrs := make([]string, 0, len(reply))
+	for _, replica := range reply {
+		if !replicaHealthy(replica["flags"]) {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(replica["ip"], replica["port"]))
+	}
+	return addrs, nil
+}
+
+func replicaHealthy(flags string) bool {
+	for _, flag := range strings.Split(flags, ",") {
+		switch flag {
+		case "s_down", "o_down", "disconnected":
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Sentinel) dialMaster() (*Conn, error) {
+	if addr := s.Addr(); addr != "" {
+		if conn, err := Dial(addr, &s.Options); err == nil {
+			// A demoted master might be back as a replica if a +switch-master event was missed
+			if isMaster(conn) {
+				return conn, nil
+			}
+			conn.Close()
+		}
+	}
+	// Master might have changed while we were not subscribed
+	addr, err := s.MasterAddr()
+	if err != nil {
+		return nil, err
+	}
+	s.setAddr(addr)
+	return Dial(addr, &s.Options)
+}
+
+// isMaster checks the ROLE of a connection
+func isMaster(conn *Conn) bool {
+	var role roleReply
+	if err := conn.DoCommand(&role, "ROLE"); err != nil {
+		return false
+	}
+	return role == "master"
+}
+
+// roleReply decodes the role name of a ROLE reply
+type roleReply string
+
+// UnmarshalRESP implements resp.Unmarshaler interface
+func (r *roleReply) UnmarshalRESP(v resp.Value) error {
+	iter := v.Iter()
+	defer iter.Close()
+	var role string
+	if err := iter.Value().Decode(&role); err != nil {
+		return err
+	}
+	*r = roleReply(role)
+	return nil
+}
+
+func (s *Sentinel) dialReplica() (*Conn, error) {
+	if s.ReadReplicas {
+		if addrs, err := s.ReplicaAddrs(); err == nil && len(addrs) > 0 {
+			addr := addrs[rand.Intn(len(addrs))]
+			if conn, err := Dial(addr, &s.Options); err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return s.dialMaster()
+}
+
+func (s *Sentinel) retryInterval() time.Duration {
+	if s.RetryInterval > 0 {
+		return s.RetryInterval
+	}
+	return time.Second
+}
+
+// watch subscribes to +switch-master on a sentinel until closed
+func (s *Sentinel) watch() {
+	defer close(s.doneCh)
+	for i := 0; ; i++ {
+		if len(s.Addrs) > 0 {
+			s.subscribe(s.Addrs[i%len(s.Addrs)])
+		}
+		select {
+		case <-s.closeCh:
+			return
+		case <-time.After(s.retryInterval()):
+		}
+	}
+}
+
+// subscribe listens for +switch-master events on a sentinel
+//
+// It returns when the subscription fails or the Sentinel is closed.
+func (s *Sentinel) subscribe(addr string) {
+	conn, err := Dial(addr, s.sentinelOptions())
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	// The subscriber reads conn.conn so it is closed directly
+	netConn := conn.conn
+	sub, err := conn.Subscriber(0)
+	if err != nil {
+		return
+	}
+	defer func() {
+		// Unblock the subscriber if the sentinel does not reply
+		_ = netConn.Close()
+		_ = sub.Close()
+	}()
+	if err := sub.Subscribe("+switch-master"); err != nil {
+		return
+	}
+	// Check for failovers missed while we were not subscribed
+	if addr, err := s.MasterAddr(); err == nil {
+		s.setAddr(addr)
+	}
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return
+			}
+			// <master name> <old ip> <old port> <new ip> <new port>
+			parts := strings.Fields(msg.Payload)
+			if len(parts) == 5 && parts[0] == s.MasterName {
+				s.setAddr(net.JoinHostPort(parts[3], parts[4]))
+			}
+		}
+	}
+}
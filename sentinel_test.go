@@ -0,0 +1,217 @@
+package red_test
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alxarch/red"
+	"github.com/alxarch/red/resp"
+)
+
+// fakeNode is a fake redis server that replies to the fake NODENAME command with its name
+func fakeNode(t *testing.T, name string, role func() string) *fakeServer {
+	return newFakeServer(t, func(args []string) resp.Any {
+		switch strings.ToUpper(args[0]) {
+		case "NODENAME":
+			return bulk(name)
+		case "ROLE":
+			return resp.Array{bulk(role()), resp.Integer(0), resp.Array{}}
+		default:
+			return resp.SimpleString("OK")
+		}
+	})
+}
+
+func hostPort(addr string) (string, string) {
+	host, port, _ := net.SplitHostPort(addr)
+	return host, port
+}
+
+func TestSentinel(t *testing.T) {
+	var mu sync.Mutex
+	demoted := false
+	role := func(master bool) func() string {
+		return func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			if master && !demoted {
+				return "master"
+			}
+			return "slave"
+		}
+	}
+	master1 := fakeNode(t, "master1", role(true))
+	master2 := fakeNode(t, "master2", role(true))
+	replica := fakeNode(t, "replica", role(false))
+	master := master1.Addr()
+	sentinel := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.ToUpper(args[0]) != "SENTINEL" || len(args) != 3 || args[2] != "mymaster" {
+			return resp.Error("ERR unknown command")
+		}
+		switch strings.ToLower(args[1]) {
+		case "get-master-addr-by-name":
+			host, port := hostPort(master)
+			return resp.Array{bulk(host), bulk(port)}
+		case "replicas":
+			host, port := hostPort(replica.Addr())
+			return resp.Array{
+				resp.Array{
+					bulk("name"), bulk(replica.Addr()),
+					bulk("ip"), bulk(host),
+					bulk("port"), bulk(port),
+					bulk("flags"), bulk("slave"),
+				},
+				resp.Array{
+					bulk("name"), bulk("127.0.0.1:1"),
+					bulk("ip"), bulk("127.0.0.1"),
+					bulk("port"), bulk("1"),
+					bulk("flags"), bulk("slave,s_down,disconnected"),
+				},
+			}
+		default:
+			return resp.Error("ERR unknown subcommand")
+		}
+	})
+	s := red.Sentinel{
+		Addrs:         []string{sentinel.Addr()},
+		MasterName:    "mymaster",
+		ReadReplicas:  true,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	defer s.Close()
+
+	addrs, err := s.ReplicaAddrs()
+	if err != nil {
+		t.Fatalf("ReplicaAddrs failed %s", err)
+	}
+	if len(addrs) != 1 || addrs[0] != replica.Addr() {
+		t.Errorf("Invalid replica addrs %v", addrs)
+	}
+
+	nodeName := func(pool *red.Pool) string {
+		var name string
+		if err := pool.DoCommand(&name, "NODENAME"); err != nil {
+			t.Fatalf("NODENAME failed %s", err)
+		}
+		return name
+	}
+	if name := nodeName(s.Master()); name != "master1" {
+		t.Errorf("Invalid master %q", name)
+	}
+	if name := nodeName(s.Replica()); name != "replica" {
+		t.Errorf("Invalid replica %q", name)
+	}
+
+	// Failover
+	mu.Lock()
+	master = master2.Addr()
+	mu.Unlock()
+	h1, p1 := hostPort(master1.Addr())
+	h2, p2 := hostPort(master2.Addr())
+	payload := strings.Join([]string{"mymaster", h1, p1, h2, p2}, " ")
+	deadline := time.Now().Add(time.Second)
+	for s.Addr() != master2.Addr() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Master switch not detected")
+		}
+		sentinel.Publish("+switch-master", payload)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if name := nodeName(s.Master()); name != "master2" {
+		t.Errorf("Invalid master after failover %q", name)
+	}
+}
+
+func TestSentinel_MissedSwitch(t *testing.T) {
+	var mu sync.Mutex
+	roles := map[string]string{"node1": "master", "node2": "slave"}
+	role := func(name string) func() string {
+		return func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			return roles[name]
+		}
+	}
+	node1 := fakeNode(t, "node1", role("node1"))
+	node2 := fakeNode(t, "node2", role("node2"))
+	master := node1.Addr()
+	sentinel := newFakeServer(t, func(args []string) resp.Any {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.ToUpper(args[0]) == "SENTINEL" && strings.ToLower(args[1]) == "get-master-addr-by-name" {
+			host, port := hostPort(master)
+			return resp.Array{bulk(host), bulk(port)}
+		}
+		return resp.Error("ERR unknown command")
+	})
+	s := red.Sentinel{
+		Addrs:         []string{sentinel.Addr()},
+		MasterName:    "mymaster",
+		RetryInterval: time.Hour,
+	}
+	defer s.Close()
+	nodeName := func() string {
+		var name string
+		if err := s.Master().DoCommand(&name, "NODENAME"); err != nil {
+			t.Fatalf("NODENAME failed %s", err)
+		}
+		return name
+	}
+	if name := nodeName(); name != "node1" {
+		t.Fatalf("Invalid master %q", name)
+	}
+
+	// Failover without a +switch-master event
+	mu.Lock()
+	roles["node1"], roles["node2"] = "slave", "master"
+	master = node2.Addr()
+	mu.Unlock()
+	s.Master().Drain()
+	if name := nodeName(); name != "node2" {
+		t.Errorf("Demoted master was dialed %q", name)
+	}
+	if addr := s.Addr(); addr != node2.Addr() {
+		t.Errorf("Master address not updated %q", addr)
+	}
+}
+
+func TestPool_Drain(t *testing.T) {
+	srv := newFakeServer(t, func(args []string) resp.Any {
+		return resp.SimpleString("OK")
+	})
+	pool := red.Pool{
+		Dial: func() (*red.Conn, error) {
+			return red.Dial(srv.Addr(), nil)
+		},
+		MaxConnections: 2,
+	}
+	defer pool.Close()
+	c1, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.Close()
+	pool.Drain()
+	if stats := pool.Stats(); stats.Idle != 0 || stats.Active != 1 {
+		t.Errorf("Invalid stats after drain %v", stats)
+	}
+	c2.Close()
+	if stats := pool.Stats(); stats.Idle != 0 || stats.Active != 0 {
+		t.Errorf("Active connection not discarded %v", stats)
+	}
+	if err := pool.DoCommand(nil, "PING"); err != nil {
+		t.Errorf("PING failed %s", err)
+	}
+	if stats := pool.Stats(); stats.Dials != 3 {
+		t.Errorf("Invalid dials %d", stats.Dials)
+	}
+}